@@ -12,7 +12,7 @@ import (
 	"os/exec"
 	"perfolizer/assets/icons"
 	"perfolizer/pkg/core"
-	_ "perfolizer/pkg/elements"
+	"perfolizer/pkg/elements"
 	"runtime"
 	"sort"
 	"strconv"
@@ -28,10 +28,14 @@ const maxRestartPayloadBytes = 8 << 10
 
 var ErrAlreadyRunning = errors.New("test is already running")
 
+// ErrStopping is returned by Start while a stopped test still runs its teardown groups.
+var ErrStopping = errors.New("previous test is still stopping")
+
 type Server struct {
 	mu sync.RWMutex
 
 	running         bool
+	stopping        bool // Stop was called; running stays set until teardown finishes
 	cancel          context.CancelFunc
 	stats           *core.StatsRunner
 	currentPlanName string
@@ -76,6 +80,12 @@ func (s *Server) Start(plan core.TestElement) error {
 	if err := core.ValidateTestPlan(plan); err != nil {
 		return &core.ValidationError{Err: err}
 	}
+	if err := elements.ValidateResolvedModules(plan); err != nil {
+		return err
+	}
+	if err := elements.ValidateBundledDataFiles(plan); err != nil {
+		return err
+	}
 
 	planName := strings.TrimSpace(plan.Name())
 	if planName == "" {
@@ -84,8 +94,12 @@ func (s *Server) Start(plan core.TestElement) error {
 
 	s.mu.Lock()
 	if s.running {
+		err := ErrAlreadyRunning
+		if s.stopping {
+			err = ErrStopping
+		}
 		s.mu.Unlock()
-		return ErrAlreadyRunning
+		return err
 	}
 
 	baseCtx, cancel := context.WithCancel(context.Background())
@@ -108,14 +122,14 @@ func (s *Server) Start(plan core.TestElement) error {
 	return nil
 }
 
+// Stop cancels the running test. The server keeps reporting it as running until its
+// teardown groups have finished.
 func (s *Server) Stop() (bool, string) {
 	s.mu.Lock()
 	wasRunning := s.running
 	planName := s.currentPlanName
 	cancel := s.cancel
-	s.running = false
-	s.cancel = nil
-	s.currentPlanName = ""
+	s.stopping = wasRunning
 	s.mu.Unlock()
 
 	if cancel != nil {
@@ -136,12 +150,26 @@ func (s *Server) Snapshot() (bool, map[string]core.Metric) {
 	return running, stats.Snapshot()
 }
 
+// ActiveUsers returns the virtual users each thread group of the current or last run
+// runs, by thread group ID.
+func (s *Server) ActiveUsers() map[string]int {
+	s.mu.RLock()
+	stats := s.stats
+	s.mu.RUnlock()
+
+	if stats == nil {
+		return map[string]int{}
+	}
+	return stats.ActiveUsers()
+}
+
 func (s *Server) setStopped(stats *core.StatsRunner) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	if s.stats == stats {
 		planName := s.currentPlanName
 		s.running = false
+		s.stopping = false
 		s.cancel = nil
 		s.currentPlanName = ""
 		if planName != "" {
@@ -165,8 +193,12 @@ func runPlan(ctx context.Context, plan core.TestElement, runner core.Runner) {
 	// Yes, GetProps includes "Parameters", so toDTO/fromDTO will handle them via GetParameters.
 	// So explicit passing here might NOT be needed if they are part of the ThreadGroup's properties.
 
-	var wg sync.WaitGroup
+	ctx, stop := context.WithCancel(ctx)
+	defer stop()
+	ctx = core.WithTestStopper(ctx, stop)
+	ctx = core.WithRunStore(ctx, core.NewRunStore())
 
+	phases := make(map[string][]core.ThreadGroup)
 	for _, child := range plan.GetChildren() {
 		if !child.Enabled() {
 			continue
@@ -175,13 +207,47 @@ func runPlan(ctx context.Context, plan core.TestElement, runner core.Runner) {
 		if !ok {
 			continue
 		}
+		phase := core.ThreadGroupRunPhase(tg)
+		phases[phase] = append(phases[phase], tg)
+	}
+
+	// Every thread of the run reads and writes the same global variables. Setup
+	// groups finish before anything else; the variables their threads set, but not
+	// the threads' own run state, are exported read-only to the main and teardown
+	// groups, so teardown still sees what setup created.
+	ctx = core.WithGlobals(ctx, core.NewGlobals(nil))
+	sequential := core.RunsThreadGroupsSequentially(plan)
+	exports := core.NewContext(ctx, 0)
+	startThreadGroups(core.WithVariableExports(ctx, exports), phases[core.RunPhaseSetup], runner, sequential)
+	ctx = core.WithSetupExports(ctx, core.NewGlobals(exports.UserVariables()))
+
+	if ctx.Err() == nil {
+		startThreadGroups(ctx, phases[core.RunPhaseMain], runner, sequential)
+	}
+
+	// Teardown groups detach from the run's cancellation with their own timeout,
+	// so they also run after /stop.
+	startThreadGroups(ctx, phases[core.RunPhaseTeardown], runner, sequential)
+}
+
+// startThreadGroups runs groups and waits for all of them, either concurrently or,
+// when sequential is set, one after another in tree order.
+func startThreadGroups(ctx context.Context, groups []core.ThreadGroup, runner core.Runner, sequential bool) {
+	if sequential {
+		for _, tg := range groups {
+			tg.Start(ctx, runner)
+		}
+		return
+	}
+
+	var wg sync.WaitGroup
+	for _, tg := range groups {
 		wg.Add(1)
 		go func(group core.ThreadGroup) {
 			defer wg.Done()
 			group.Start(ctx, runner)
 		}(tg)
 	}
-
 	wg.Wait()
 }
 
@@ -212,7 +278,7 @@ func (s *Server) handleRun(w http.ResponseWriter, r *http.Request) {
 			http.Error(w, fmt.Sprintf("invalid test plan: %v", validationErr), http.StatusBadRequest)
 			return
 		}
-		if errors.Is(err, ErrAlreadyRunning) {
+		if errors.Is(err, ErrAlreadyRunning) || errors.Is(err, ErrStopping) {
 			log.Printf("run rejected: already running (from=%s plan=%q)", r.RemoteAddr, planName)
 			http.Error(w, err.Error(), http.StatusConflict)
 			return
@@ -257,7 +323,7 @@ func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
 	if s.hostStats != nil {
 		hostMetrics = s.hostStats.collect()
 	}
-	metrics := renderPrometheusMetrics(running, snapshot, hostMetrics)
+	metrics := renderPrometheusMetrics(running, snapshot, s.ActiveUsers(), hostMetrics)
 
 	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
 	_, _ = io.WriteString(w, metrics)
@@ -490,7 +556,7 @@ func trimBody(body string, maxLen int) bodySlice {
 	}
 }
 
-func renderPrometheusMetrics(running bool, snapshot map[string]core.Metric, host hostMetricsSnapshot) string {
+func renderPrometheusMetrics(running bool, snapshot map[string]core.Metric, activeUsers map[string]int, host hostMetricsSnapshot) string {
 	var b strings.Builder
 
 	b.WriteString("# HELP perfolizer_test_running Test running state (1=running, 0=idle).\n")
@@ -505,12 +571,18 @@ func renderPrometheusMetrics(running bool, snapshot map[string]core.Metric, host
 	b.WriteString("# TYPE perfolizer_rps gauge\n")
 	b.WriteString("# HELP perfolizer_avg_response_time_ms Average response time in milliseconds in the latest stats window.\n")
 	b.WriteString("# TYPE perfolizer_avg_response_time_ms gauge\n")
+	b.WriteString("# HELP perfolizer_p95_response_time_ms 95th percentile response time in milliseconds in the latest stats window.\n")
+	b.WriteString("# TYPE perfolizer_p95_response_time_ms gauge\n")
 	b.WriteString("# HELP perfolizer_errors Errors in the latest stats window.\n")
 	b.WriteString("# TYPE perfolizer_errors gauge\n")
 	b.WriteString("# HELP perfolizer_requests_total Total request count since test start.\n")
 	b.WriteString("# TYPE perfolizer_requests_total counter\n")
 	b.WriteString("# HELP perfolizer_errors_total Total error count since test start.\n")
 	b.WriteString("# TYPE perfolizer_errors_total counter\n")
+	b.WriteString("# HELP perfolizer_active_users Virtual users currently running per thread group ID and in Total.\n")
+	b.WriteString("# TYPE perfolizer_active_users gauge\n")
+	b.WriteString("# HELP perfolizer_sustainable_rps Highest rate a capacity thread group found sustainable within its SLOs.\n")
+	b.WriteString("# TYPE perfolizer_sustainable_rps gauge\n")
 
 	samplers := make([]string, 0, len(snapshot))
 	for sampler := range snapshot {
@@ -524,11 +596,26 @@ func renderPrometheusMetrics(running bool, snapshot map[string]core.Metric, host
 
 		fmt.Fprintf(&b, "perfolizer_rps{sampler=%s} %.6f\n", label, metric.RPS)
 		fmt.Fprintf(&b, "perfolizer_avg_response_time_ms{sampler=%s} %.6f\n", label, metric.AvgLatency)
+		fmt.Fprintf(&b, "perfolizer_p95_response_time_ms{sampler=%s} %.6f\n", label, metric.P95Latency)
 		fmt.Fprintf(&b, "perfolizer_errors{sampler=%s} %d\n", label, metric.Errors)
 		fmt.Fprintf(&b, "perfolizer_requests_total{sampler=%s} %d\n", label, metric.TotalRequests)
 		fmt.Fprintf(&b, "perfolizer_errors_total{sampler=%s} %d\n", label, metric.TotalErrors)
 	}
 
+	groups := make([]string, 0, len(activeUsers))
+	totalUsers := 0
+	for group, users := range activeUsers {
+		groups = append(groups, group)
+		totalUsers += users
+	}
+	sort.Strings(groups)
+
+	for _, group := range groups {
+		fmt.Fprintf(&b, "perfolizer_active_users{thread_group=%s} %d\n", strconv.Quote(group), activeUsers[group])
+	}
+	fmt.Fprintf(&b, "perfolizer_active_users{thread_group=%s} %d\n", strconv.Quote("Total"), totalUsers)
+	fmt.Fprintf(&b, "perfolizer_sustainable_rps{sampler=%s} %.6f\n", strconv.Quote("Total"), snapshot["Total"].SustainableRPS)
+
 	appendHostMetrics(&b, host)
 
 	return b.String()
@@ -2,6 +2,7 @@ package ai
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/url"
 	"sort"
@@ -9,9 +10,16 @@ import (
 	"strings"
 
 	"perfolizer/pkg/core"
+	"perfolizer/pkg/elements"
 )
 
 func ValidateDraft(draft PlanDraft) (core.TestElement, error) {
+	return ValidateDraftInProject(draft, nil, "")
+}
+
+// ValidateDraftInProject validates draft as a new plan of project, whose relative
+// module files are resolved against baseDir.
+func ValidateDraftInProject(draft PlanDraft, project *core.Project, baseDir string) (core.TestElement, error) {
 	dto, err := normalizeDTO(draft.Root)
 	if err != nil {
 		return nil, fmt.Errorf("normalize draft root: %w", err)
@@ -23,10 +31,19 @@ func ValidateDraft(draft PlanDraft) (core.TestElement, error) {
 	if err := core.ValidateTestPlan(root); err != nil {
 		return nil, err
 	}
+	if err := validateModules(root, nil, project, baseDir); err != nil {
+		return nil, err
+	}
 	return root, nil
 }
 
 func ApplyPatch(root core.TestElement, patch PlanPatch) (core.TestElement, error) {
+	return ApplyPatchInProject(root, patch, nil, "")
+}
+
+// ApplyPatchInProject applies patch to root, a plan of project, and validates the
+// result in place of root. Relative module files are resolved against baseDir.
+func ApplyPatchInProject(root core.TestElement, patch PlanPatch, project *core.Project, baseDir string) (core.TestElement, error) {
 	if root == nil {
 		return nil, fmt.Errorf("test plan is required")
 	}
@@ -54,9 +71,39 @@ func ApplyPatch(root core.TestElement, patch PlanPatch) (core.TestElement, error
 	if err := core.ValidateTestPlan(currentRoot); err != nil {
 		return nil, err
 	}
+	if err := validateModules(currentRoot, root, project, baseDir); err != nil {
+		return nil, err
+	}
 	return currentRoot, nil
 }
 
+// validateModules checks the module references of plan as a plan of project, where
+// it replaces previous or, when previous is not there, is added. Without a project
+// only plan itself can be looked up, so only reference cycles are reported.
+func validateModules(plan, previous core.TestElement, project *core.Project, baseDir string) error {
+	scope := core.NewProject("")
+	if project != nil {
+		scope.Name = project.Name
+		scope.Plans = append(scope.Plans, project.Plans...)
+	}
+	replaced := false
+	for i := range scope.Plans {
+		if previous != nil && scope.Plans[i].Root == previous {
+			scope.Plans[i].Root = plan
+			replaced = true
+		}
+	}
+	if !replaced {
+		scope.AddPlan(plan.Name(), plan)
+	}
+
+	err := elements.ValidateModuleReferences(plan, scope, baseDir)
+	if err != nil && project == nil && !errors.Is(err, elements.ErrModuleCycle) {
+		return nil
+	}
+	return err
+}
+
 func SummarizePatch(patch PlanPatch) []string {
 	lines := make([]string, 0, len(patch.Operations)+1)
 	if patch.Rationale != "" {
@@ -306,6 +353,18 @@ func canonicalizeSimpleThreadGroupProps(props map[string]interface{}) map[string
 	if iterations, ok := firstIntProp(out, "Iterations", "iterations", "loops", "Loops"); ok {
 		out["Iterations"] = iterations
 	}
+	if rampUpMS, ok := firstDurationMSProp(out, []string{"RampUpMS", "RampUpMs"}, []string{"RampUpSeconds"}); ok {
+		out["RampUpMS"] = rampUpMS
+	}
+	if holdMS, ok := firstDurationMSProp(out,
+		[]string{"HoldMS", "HoldMs", "DurationMS", "DurationMs"},
+		[]string{"HoldSeconds", "DurationSeconds"},
+	); ok {
+		out["HoldMS"] = holdMS
+	}
+	if rampDownMS, ok := firstDurationMSProp(out, []string{"RampDownMS", "RampDownMs"}, []string{"RampDownSeconds"}); ok {
+		out["RampDownMS"] = rampDownMS
+	}
 	if timeoutMS, ok := firstDurationMSProp(out,
 		[]string{"HTTPRequestTimeoutMS", "HTTPRequestTimeoutMs", "RequestTimeoutMS", "TimeoutMS"},
 		[]string{"HTTPRequestTimeoutSeconds", "RequestTimeoutSeconds", "TimeoutSeconds"},
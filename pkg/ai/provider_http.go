@@ -459,11 +459,12 @@ Rules:
 - Use HttpSampler for HTTP requests.
 - Use the exact persisted prop names that Perfolizer supports:
   - HttpSampler props: Url, Method, TargetRPS, Body, ExtractVars
-  - SimpleThreadGroup props: Users, Iterations, HTTPRequestTimeoutMS, HTTPKeepAlive
+  - SimpleThreadGroup props: Users, Iterations, RampUpMS, HoldMS, RampDownMS, HTTPRequestTimeoutMS, HTTPKeepAlive
   - RPSThreadGroup props: Users, RPS, ProfileBlocks, GracefulShutdownMS, HTTPRequestTimeoutMS, HTTPKeepAlive
   - ProfileBlocks items: RampUpMS, StepDurationMS, ProfilePercent
   - PauseController props: DurationMS
   - LoopController props: Loops
+  - IfController props: Expression (e.g. ${status} == "200" && lastSampleOk)
 - Encode query parameters directly into HttpSampler.Url. Do not emit QueryParams.
 - Do not use unsupported prop names such as URL, Threads, TargetRPS for thread groups, DurationSeconds, or RampUpSeconds.
 - If the goal or request stats describe multiple request shapes, create separate samplers instead of collapsing the prose into one URL.
@@ -52,7 +52,7 @@ func TryGenerateRuleBasedDraft(brief WorkloadBrief) (PlanDraft, bool, error) {
 		return PlanDraft{}, false, nil
 	}
 
-	root := core.NewBaseElement("Test Plan")
+	root := core.NewTestPlan("Test Plan")
 	var threadGroup core.TestElement
 	rationaleParts := make([]string, 0, 2)
 	warnings := make([]string, 0)
@@ -101,7 +101,7 @@ func TryGenerateRuleBasedDraft(brief WorkloadBrief) (PlanDraft, bool, error) {
 	rationaleParts = append(rationaleParts, fmt.Sprintf("Added %d HTTP sampler(s) from the provided URL and request stats.", len(requests)))
 
 	return PlanDraft{
-		Root:      core.TestElementToDTO(&root),
+		Root:      core.TestElementToDTO(root),
 		Rationale: strings.Join(rationaleParts, " "),
 		Warnings:  warnings,
 		Source:    "rules",
@@ -248,7 +248,7 @@ func ExplainSelection(root core.TestElement, selected core.TestElement, intent s
 	case *elements.PauseController:
 		recommendation = "Pause Controller adds think time between requests."
 	case *elements.IfController:
-		recommendation = "If Controller is useful only for conditional branches. Keep it minimal because persisted conditions are currently limited."
+		recommendation = "If Controller runs its child nodes only when its condition expression is true, for example lastSampleOk or ${status} == \"200\"."
 	default:
 		if root != nil && root.ID() == selected.ID() {
 			recommendation = "Top-level test plans should usually contain one or more thread groups."
@@ -590,7 +590,7 @@ func describeSelection(selected core.TestElement) string {
 	case *elements.PauseController:
 		return fmt.Sprintf("Pause Controller %q waits for %s.", current.Name(), current.Duration)
 	case *elements.IfController:
-		return fmt.Sprintf("If Controller %q conditionally runs its child nodes.", current.Name())
+		return fmt.Sprintf("If Controller %q runs its child nodes when %s.", current.Name(), current.Expression)
 	default:
 		return fmt.Sprintf("Selected node: %s.", strings.TrimSpace(selected.Name()))
 	}
@@ -3,6 +3,7 @@ package core
 import (
 	"context"
 	"fmt"
+	"strings"
 	"sync"
 	"time"
 )
@@ -15,6 +16,12 @@ type Context struct {
 	ParameterDefinitions map[string]Parameter
 	ThreadID             int
 	Iteration            int
+	lastSample           *SampleResult
+	written              map[string]struct{} // Keys set since Fork, nil for regular contexts
+	state                map[string]struct{} // Keys set with SetStateVar
+	group                *Globals            // Thread group variables, checked after the thread's own
+	globals              *Globals            // Run-wide variables, checked after the group's
+	exports              *Globals            // Read-only setup group variables, checked last
 	mu                   sync.RWMutex
 }
 
@@ -24,6 +31,9 @@ func NewContext(parent context.Context, threadID int) *Context {
 		ThreadID:             threadID,
 		Variables:            make(map[string]interface{}),
 		ParameterDefinitions: make(map[string]Parameter),
+		group:                GroupVariablesFromContext(parent),
+		globals:              GlobalsFromContext(parent),
+		exports:              SetupExportsFromContext(parent),
 	}
 
 	// If parent is also a *Context, copy definitions
@@ -40,6 +50,12 @@ func NewContext(parent context.Context, threadID int) *Context {
 		for k, v := range pCtx.Variables {
 			c.Variables[k] = v
 		}
+		for k := range pCtx.state {
+			if c.state == nil {
+				c.state = make(map[string]struct{})
+			}
+			c.state[k] = struct{}{}
+		}
 		pCtx.mu.RUnlock()
 	}
 
@@ -50,12 +66,164 @@ func (c *Context) SetVar(key string, val interface{}) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 	c.Variables[key] = val
+	if c.written != nil {
+		c.written[key] = struct{}{}
+	}
+	delete(c.state, key)
+}
+
+// SetStateVar stores val like SetVar but marks key as the thread's own run state,
+// such as an element's counters or the reporter. State follows the thread through
+// Fork and Join but is left out of UserVariables.
+func (c *Context) SetStateVar(key string, val interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.Variables[key] = val
+	if c.written != nil {
+		c.written[key] = struct{}{}
+	}
+	if c.state == nil {
+		c.state = make(map[string]struct{})
+	}
+	c.state[key] = struct{}{}
+}
+
+// UserVariables returns a copy of the thread's own variables without those set with
+// SetStateVar.
+func (c *Context) UserVariables() map[string]interface{} {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	vars := make(map[string]interface{}, len(c.Variables))
+	for key, val := range c.Variables {
+		if _, ok := c.state[key]; !ok {
+			vars[key] = val
+		}
+	}
+	return vars
+}
+
+// SetScopedVar stores val in scope: VarScopeGlobal writes the run's global variables,
+// VarScopeGroup those of the thread group, and anything else the thread's own. A
+// thread's own variable of the same name still shadows a wider one. Without a store
+// for the scope, as outside a run or for a global exported by a setup thread group,
+// the value is kept by the thread.
+func (c *Context) SetScopedVar(scope, key string, val interface{}) {
+	if store := c.sharedStore(scope, key); store != nil {
+		store.Set(key, val)
+		return
+	}
+	c.SetVar(key, val)
+}
+
+// SetScopedVarIfAbsent stores val in scope like SetScopedVar unless the thread already
+// sees a value for key, and reports whether it stored it. Concurrent threads
+// initialising a shared variable store it once.
+func (c *Context) SetScopedVarIfAbsent(scope, key string, val interface{}) bool {
+	if _, ok := c.lookupVar(key); ok {
+		return false
+	}
+	if store := c.sharedStore(scope, key); store != nil {
+		return store.SetIfAbsent(key, val)
+	}
+	c.SetVar(key, val)
+	return true
+}
+
+func (c *Context) sharedStore(scope, key string) *Globals {
+	switch scope {
+	case VarScopeGlobal:
+		if _, exported := c.exports.Get(key); exported {
+			return nil
+		}
+		return c.globals
+	case VarScopeGroup:
+		return c.group
+	default:
+		return nil
+	}
+}
+
+// Fork returns a context for work that runs concurrently on behalf of the same thread.
+// It starts from a copy of c's variables and last sample; Join publishes its changes back.
+func (c *Context) Fork() *Context {
+	child := NewContext(c, c.ThreadID)
+	child.Iteration = c.Iteration
+	child.lastSample = c.LastSample()
+	child.written = make(map[string]struct{})
+	return child
+}
+
+// Join copies the variables set in a forked context back into c and adopts its
+// last sample when it is newer than c's.
+func (c *Context) Join(child *Context) {
+	child.mu.RLock()
+	changed := make(map[string]interface{}, len(child.written))
+	for key := range child.written {
+		changed[key] = child.Variables[key]
+	}
+	state := make(map[string]struct{}, len(child.state))
+	for key := range child.state {
+		state[key] = struct{}{}
+	}
+	last := child.lastSample
+	child.mu.RUnlock()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key, val := range changed {
+		c.Variables[key] = val
+		if c.written != nil {
+			c.written[key] = struct{}{}
+		}
+		if _, ok := state[key]; !ok {
+			delete(c.state, key)
+		} else if c.state == nil {
+			c.state = map[string]struct{}{key: {}}
+		} else {
+			c.state[key] = struct{}{}
+		}
+	}
+	if last != nil && (c.lastSample == nil || last.EndTime.After(c.lastSample.EndTime)) {
+		c.lastSample = last
+	}
 }
 
+// GetVar returns the variable key from the narrowest scope that has it: the thread's
+// own variables, then the thread group's, then the run's globals, then the variables
+// exported by its setup thread groups.
 func (c *Context) GetVar(key string) interface{} {
+	val, _ := c.lookupVar(key)
+	return val
+}
+
+func (c *Context) lookupVar(key string) (interface{}, bool) {
+	c.mu.RLock()
+	val, ok := c.Variables[key]
+	c.mu.RUnlock()
+	if ok {
+		return val, true
+	}
+	if val, ok := c.group.Get(key); ok {
+		return val, true
+	}
+	if val, ok := c.globals.Get(key); ok {
+		return val, true
+	}
+	return c.exports.Get(key)
+}
+
+// SetLastSample records the most recent sample produced by this thread.
+func (c *Context) SetLastSample(result *SampleResult) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.lastSample = result
+}
+
+// LastSample returns the most recent sample produced by this thread, or nil.
+func (c *Context) LastSample() *SampleResult {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
-	return c.Variables[key]
+	return c.lastSample
 }
 
 func (c *Context) GetParameterDefinition(name string) (Parameter, bool) {
@@ -65,7 +233,10 @@ func (c *Context) GetParameterDefinition(name string) (Parameter, bool) {
 	return p, ok
 }
 
-// Substitute replaces ${var} with values from the context.
+// Substitute replaces ${var} references with values found as GetVar finds them and
+// ${__name(args)} references with the result of the registered function. References
+// nest, so ${__base64(${user}:${pass})} and ${user_${__threadNum()}} work. Unknown
+// variables and functions, and failing calls, are left as written.
 func (c *Context) Substitute(text string) string {
 	if text == "" {
 		return ""
@@ -76,10 +247,7 @@ func (c *Context) Substitute(text string) string {
 		return text
 	}
 
-	c.mu.RLock()
-	defer c.mu.RUnlock()
-
-	return expandVariables(text, c.Variables)
+	return c.expand(text)
 }
 
 func containsVar(s string) bool {
@@ -91,34 +259,76 @@ func containsVar(s string) bool {
 	return false
 }
 
-func expandVariables(s string, vars map[string]interface{}) string {
-	// Simple implementation, can be optimized
-	// TODO: Use better parser if needed
-	var result []byte
+func (c *Context) expand(s string) string {
+	var result strings.Builder
 	i := 0
 	for i < len(s) {
 		if i < len(s)-3 && s[i] == '$' && s[i+1] == '{' {
-			end := -1
-			for j := i + 2; j < len(s); j++ {
-				if s[j] == '}' {
-					end = j
-					break
-				}
-			}
-			if end != -1 {
-				key := s[i+2 : end]
-				if val, ok := vars[key]; ok {
-					strVal := fmt.Sprintf("%v", val)
-					result = append(result, strVal...)
+			if end := closingBrace(s, i+2); end != -1 {
+				if val, ok := c.resolveReference(s[i+2 : end]); ok {
+					result.WriteString(val)
 					i = end + 1
 					continue
 				}
 			}
 		}
-		result = append(result, s[i])
+		result.WriteByte(s[i])
 		i++
 	}
-	return string(result)
+	return result.String()
+}
+
+// closingBrace returns the index of the '}' closing the reference whose body starts
+// at start, skipping nested references, or -1 when it is not closed.
+func closingBrace(s string, start int) int {
+	depth := 0
+	for j := start; j < len(s); j++ {
+		switch {
+		case s[j] == '$' && j+1 < len(s) && s[j+1] == '{':
+			depth++
+			j++
+		case s[j] == '}':
+			if depth == 0 {
+				return j
+			}
+			depth--
+		}
+	}
+	return -1
+}
+
+// resolveReference returns the value of the reference body ref, the text between
+// "${" and "}".
+func (c *Context) resolveReference(ref string) (string, bool) {
+	if strings.HasPrefix(ref, "__") && strings.HasSuffix(ref, ")") {
+		if open := strings.IndexByte(ref, '('); open != -1 {
+			return c.callFunction(ref[2:open], ref[open+1:len(ref)-1])
+		}
+	}
+	if containsVar(ref) {
+		ref = c.expand(ref)
+	}
+	val, ok := c.lookupVar(ref)
+	if !ok {
+		return "", false
+	}
+	return fmt.Sprintf("%v", val), true
+}
+
+func (c *Context) callFunction(name, rawArgs string) (string, bool) {
+	fn := GetFunction(name)
+	if fn == nil {
+		return "", false
+	}
+	args := splitFunctionArgs(rawArgs)
+	for i, arg := range args {
+		args[i] = c.Substitute(arg)
+	}
+	val, err := fn(c, args)
+	if err != nil {
+		return "", false
+	}
+	return val, true
 }
 
 // SampleResult holds the result of a sampler execution.
@@ -131,8 +341,28 @@ type SampleResult struct {
 	Success       bool
 	Error         error
 	BytesReceived int64
+	Kind          SampleKind
+	ThreadGroup   string // ID of the thread group whose thread produced the sample
 }
 
+// SampleKind tells what a sample stands for, which decides how it counts towards
+// the aggregate totals.
+type SampleKind int
+
+const (
+	// SampleRequest is a request made by a sampler. It is the zero value.
+	SampleRequest SampleKind = iota
+	// SampleTransaction times child samples that are also reported on their own, so
+	// it is left out of the totals to not count the same requests twice.
+	SampleTransaction
+	// SampleLockWait is the time spent waiting for a critical section. It is left out
+	// of the totals.
+	SampleLockWait
+	// SampleDroppedIteration is an iteration an arrival-rate thread group could not
+	// start for lack of free users.
+	SampleDroppedIteration
+)
+
 func (s *SampleResult) Duration() time.Duration {
 	return s.EndTime.Sub(s.StartTime)
 }
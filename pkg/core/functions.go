@@ -0,0 +1,206 @@
+package core
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"math"
+	mathrand "math/rand/v2"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Function computes the value of a ${__name(args)} reference. The arguments arrive
+// already substituted. An error leaves the reference in the text unchanged.
+type Function func(ctx *Context, args []string) (string, error)
+
+var functions = make(map[string]Function)
+
+// RegisterFunction makes fn available to substitution as ${__name(...)}. Like element
+// factories, functions are registered from init().
+func RegisterFunction(name string, fn Function) {
+	functions[name] = fn
+}
+
+func GetFunction(name string) Function {
+	return functions[name]
+}
+
+func init() {
+	RegisterFunction("uuid", uuidFunction)
+	RegisterFunction("randomInt", randomIntFunction)
+	RegisterFunction("randomString", randomStringFunction)
+	RegisterFunction("time", timeFunction)
+	RegisterFunction("threadNum", func(ctx *Context, args []string) (string, error) {
+		return strconv.Itoa(ctx.ThreadID), nil
+	})
+	RegisterFunction("iteration", func(ctx *Context, args []string) (string, error) {
+		return strconv.Itoa(ctx.Iteration), nil
+	})
+	// Text functions rejoin their arguments so unescaped commas in the text survive
+	RegisterFunction("base64", func(ctx *Context, args []string) (string, error) {
+		return base64.StdEncoding.EncodeToString([]byte(strings.Join(args, ","))), nil
+	})
+	RegisterFunction("urlencode", func(ctx *Context, args []string) (string, error) {
+		return url.QueryEscape(strings.Join(args, ",")), nil
+	})
+	RegisterFunction("env", envFunction)
+	RegisterFunction("counter", counterFunction)
+}
+
+// uuidFunction returns a random (version 4) UUID.
+func uuidFunction(ctx *Context, args []string) (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16]), nil
+}
+
+// randomIntFunction returns an integer between its two arguments, both included.
+func randomIntFunction(ctx *Context, args []string) (string, error) {
+	if len(args) != 2 {
+		return "", fmt.Errorf("randomInt takes a minimum and a maximum")
+	}
+	lo, err := strconv.ParseInt(strings.TrimSpace(args[0]), 10, 64)
+	if err != nil {
+		return "", fmt.Errorf("randomInt minimum: %w", err)
+	}
+	hi, err := strconv.ParseInt(strings.TrimSpace(args[1]), 10, 64)
+	if err != nil {
+		return "", fmt.Errorf("randomInt maximum: %w", err)
+	}
+	if hi < lo {
+		return "", fmt.Errorf("randomInt maximum must be greater than or equal to the minimum")
+	}
+	// The span is counted in uint64 so ranges wider than MaxInt64 do not overflow
+	span := uint64(hi) - uint64(lo)
+	offset := mathrand.Uint64()
+	if span < math.MaxUint64 {
+		offset = mathrand.Uint64N(span + 1)
+	}
+	return strconv.FormatInt(lo+int64(offset), 10), nil
+}
+
+const defaultRandomStringChars = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+// randomStringFunction returns length characters drawn from its second argument,
+// letters and digits by default.
+func randomStringFunction(ctx *Context, args []string) (string, error) {
+	if len(args) < 1 {
+		return "", fmt.Errorf("randomString takes a length")
+	}
+	length, err := strconv.Atoi(strings.TrimSpace(args[0]))
+	if err != nil || length < 0 {
+		return "", fmt.Errorf("randomString length must be a non-negative integer")
+	}
+	chars := []rune(defaultRandomStringChars)
+	if len(args) > 1 {
+		if custom := []rune(strings.Join(args[1:], ",")); len(custom) > 0 {
+			chars = custom
+		}
+	}
+	out := make([]rune, length)
+	for i := range out {
+		out[i] = chars[mathrand.IntN(len(chars))]
+	}
+	return string(out), nil
+}
+
+// timeFunction formats the current time: unix_ms (the default), unix, unix_ns,
+// rfc3339, or any other text as a Go time layout.
+func timeFunction(ctx *Context, args []string) (string, error) {
+	now := time.Now()
+	format := ""
+	if len(args) > 0 {
+		format = strings.Join(args, ",")
+	}
+	switch strings.TrimSpace(format) {
+	case "", "unix_ms":
+		return strconv.FormatInt(now.UnixMilli(), 10), nil
+	case "unix":
+		return strconv.FormatInt(now.Unix(), 10), nil
+	case "unix_ns":
+		return strconv.FormatInt(now.UnixNano(), 10), nil
+	case "rfc3339":
+		return now.Format(time.RFC3339), nil
+	default:
+		return now.Format(format), nil
+	}
+}
+
+// envFunction returns the agent's environment variable named by its first argument,
+// or the second argument when it is unset.
+func envFunction(ctx *Context, args []string) (string, error) {
+	if len(args) < 1 || strings.TrimSpace(args[0]) == "" {
+		return "", fmt.Errorf("env takes a variable name")
+	}
+	if val, ok := os.LookupEnv(strings.TrimSpace(args[0])); ok {
+		return val, nil
+	}
+	if len(args) > 1 {
+		return strings.Join(args[1:], ","), nil
+	}
+	return "", nil
+}
+
+// standaloneFunctionStore backs run-wide function state for contexts outside a run.
+var standaloneFunctionStore = NewRunStore()
+
+// counterFunction returns 1, 2, 3... on successive calls, counted across the run, or
+// per thread when its argument is TRUE.
+func counterFunction(ctx *Context, args []string) (string, error) {
+	perThread := len(args) > 0 && strings.EqualFold(strings.TrimSpace(args[0]), "true")
+	if perThread {
+		next, _ := ctx.GetVar("__counter").(int)
+		next++
+		ctx.SetStateVar("__counter", next)
+		return strconv.Itoa(next), nil
+	}
+	store := RunStoreFromContext(ctx)
+	if store == nil {
+		store = standaloneFunctionStore
+	}
+	counter := store.GetOrCreate("function:counter", func() interface{} {
+		return &atomic.Int64{}
+	}).(*atomic.Int64)
+	return strconv.FormatInt(counter.Add(1), 10), nil
+}
+
+// splitFunctionArgs splits raw at the commas outside nested ${...} references. A
+// comma escaped as \, is kept in its argument; escapes inside nested references are
+// left for the nested call.
+func splitFunctionArgs(raw string) []string {
+	if raw == "" {
+		return nil
+	}
+	var args []string
+	var current strings.Builder
+	depth := 0
+	for i := 0; i < len(raw); i++ {
+		switch {
+		case raw[i] == '\\' && i+1 < len(raw) && raw[i+1] == ',' && depth == 0:
+			current.WriteByte(',')
+			i++
+		case raw[i] == '$' && i+1 < len(raw) && raw[i+1] == '{':
+			depth++
+			current.WriteString("${")
+			i++
+		case raw[i] == '}' && depth > 0:
+			depth--
+			current.WriteByte('}')
+		case raw[i] == ',' && depth == 0:
+			args = append(args, current.String())
+			current.Reset()
+		default:
+			current.WriteByte(raw[i])
+		}
+	}
+	return append(args, current.String())
+}
@@ -45,6 +45,35 @@ type ThreadGroup interface {
 	Start(ctx context.Context, runner Runner)
 }
 
+// Run phases order the thread groups of a run: setup groups finish before the main
+// groups start and teardown groups run last, even when the run is stopped.
+const (
+	RunPhaseSetup    = "setup"
+	RunPhaseMain     = "main"
+	RunPhaseTeardown = "teardown"
+)
+
+// PhasedThreadGroup is implemented by thread groups that run outside the main phase.
+type PhasedThreadGroup interface {
+	ThreadGroup
+	RunPhase() string
+}
+
+// ParameterizedThreadGroup is implemented by thread groups that take the plan's
+// parameters, which the UI binds to them before a run.
+type ParameterizedThreadGroup interface {
+	ThreadGroup
+	SetParameters(params []Parameter)
+}
+
+// ThreadGroupRunPhase returns the run phase of tg, RunPhaseMain unless it says otherwise.
+func ThreadGroupRunPhase(tg ThreadGroup) string {
+	if phased, ok := tg.(PhasedThreadGroup); ok {
+		return phased.RunPhase()
+	}
+	return RunPhaseMain
+}
+
 // Runner is the interface used by ThreadGroups to report execution or orchestrate.
 type Runner interface {
 	ReportResult(result *SampleResult)
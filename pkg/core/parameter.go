@@ -12,10 +12,21 @@ func (p Parameter) IsExtractor() bool {
 	return p.Type == ParamTypeRegexp || p.Type == ParamTypeJSON
 }
 
+// Variable scopes decide which threads share a parameter's value.
+const (
+	VarScopeThread = "Thread"       // Each virtual user keeps its own value
+	VarScopeGroup  = "Thread group" // Shared by the threads of one thread group
+	VarScopeGlobal = "Global"       // Shared by every thread of the run
+)
+
+// VarScopes lists the variable scopes in display order.
+var VarScopes = []string{VarScopeThread, VarScopeGroup, VarScopeGlobal}
+
 type Parameter struct {
 	ID         string
 	Name       string
 	Type       string // Static, Regexp, etc.
 	Value      string // For Static: value, for others: default/fallback
 	Expression string // Regex for Regexp, JsonPath, etc.
+	Scope      string `json:",omitempty"` // VarScope*; empty means VarScopeThread
 }
@@ -188,8 +188,7 @@ func fromDTOWithPath(dto TestElementDTO, path string) (TestElement, error) {
 
 	if factory == nil {
 		if dto.Type == "TestPlan" {
-			e := NewBaseElement(dto.Name)
-			el = &e
+			el = newTestPlanFromProps(dto.Name, dto.Props)
 		} else {
 			return nil, fmt.Errorf("%s: unknown element type: %s", path, dto.Type)
 		}
@@ -305,6 +304,27 @@ func GetStringMap(props map[string]interface{}, key string) map[string]string {
 	return nil
 }
 
+func GetFloatMap(props map[string]interface{}, key string) map[string]float64 {
+	if v, ok := props[key]; ok {
+		if m, ok := v.(map[string]interface{}); ok {
+			result := make(map[string]float64)
+			for k, val := range m {
+				switch n := val.(type) {
+				case float64:
+					result[k] = n
+				case int:
+					result[k] = float64(n)
+				}
+			}
+			return result
+		}
+		if m, ok := v.(map[string]float64); ok {
+			return m
+		}
+	}
+	return nil
+}
+
 func GetStringSlice(props map[string]interface{}, key string) []string {
 	if v, ok := props[key]; ok {
 		if arr, ok := v.([]interface{}); ok {
@@ -335,6 +355,7 @@ func GetParameters(props map[string]interface{}, key string) []Parameter {
 						Value:      GetString(m, "Value", ""),
 						Type:       GetString(m, "Type", "Static"),
 						Expression: GetString(m, "Expression", ""),
+						Scope:      GetString(m, "Scope", ""),
 					})
 				}
 			}
@@ -35,3 +35,43 @@ func (p *Project) RemovePlanAt(index int) {
 func (p *Project) PlanCount() int {
 	return len(p.Plans)
 }
+
+// TestPlan is the root element of a plan and holds the plan-wide run settings.
+// Roots built from a bare BaseElement run with the defaults.
+type TestPlan struct {
+	BaseElement
+	SequentialThreadGroups bool // Run top-level thread groups one after another in tree order
+}
+
+func NewTestPlan(name string) *TestPlan {
+	return &TestPlan{BaseElement: NewBaseElement(name)}
+}
+
+func newTestPlanFromProps(name string, props map[string]interface{}) *TestPlan {
+	plan := NewTestPlan(name)
+	plan.SequentialThreadGroups = GetBool(props, "SequentialThreadGroups", false)
+	return plan
+}
+
+func (p *TestPlan) GetType() string {
+	return "TestPlan"
+}
+
+func (p *TestPlan) GetProps() map[string]interface{} {
+	return map[string]interface{}{
+		"SequentialThreadGroups": p.SequentialThreadGroups,
+	}
+}
+
+func (p *TestPlan) Clone() TestElement {
+	newPlan := *p
+	newPlan.BaseElement = *p.BaseElement.Clone().(*BaseElement)
+	return &newPlan
+}
+
+// RunsThreadGroupsSequentially reports whether the plan rooted at root starts its
+// thread groups one after another instead of all at once.
+func RunsThreadGroupsSequentially(root TestElement) bool {
+	plan, ok := root.(*TestPlan)
+	return ok && plan.SequentialThreadGroups
+}
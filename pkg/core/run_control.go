@@ -0,0 +1,198 @@
+package core
+
+import (
+	"context"
+	"sync"
+)
+
+type testStopperContextKey struct{}
+
+// WithTestStopper attaches the function that ends the whole run, so elements deep in
+// the tree can stop the test without holding a reference to the runner.
+func WithTestStopper(ctx context.Context, stop func()) context.Context {
+	if ctx == nil || stop == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, testStopperContextKey{}, stop)
+}
+
+// StopTest ends the run that ctx belongs to. It reports false when no stopper is attached.
+func StopTest(ctx context.Context) bool {
+	if ctx == nil {
+		return false
+	}
+	stop, _ := ctx.Value(testStopperContextKey{}).(func())
+	if stop == nil {
+		return false
+	}
+	stop()
+	return true
+}
+
+// RunStore holds values shared by every thread of a run, such as named locks.
+type RunStore struct {
+	mu     sync.Mutex
+	values map[string]interface{}
+}
+
+func NewRunStore() *RunStore {
+	return &RunStore{values: make(map[string]interface{})}
+}
+
+// GetOrCreate returns the value stored under key, storing create() first if absent.
+func (s *RunStore) GetOrCreate(key string, create func() interface{}) interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if v, ok := s.values[key]; ok {
+		return v
+	}
+	v := create()
+	s.values[key] = v
+	return v
+}
+
+type runStoreContextKey struct{}
+
+func WithRunStore(ctx context.Context, store *RunStore) context.Context {
+	if ctx == nil || store == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, runStoreContextKey{}, store)
+}
+
+func RunStoreFromContext(ctx context.Context) *RunStore {
+	if ctx == nil {
+		return nil
+	}
+	store, _ := ctx.Value(runStoreContextKey{}).(*RunStore)
+	return store
+}
+
+// Globals holds variables shared by several threads: the run's global variables, the
+// variables exported by its setup thread groups, or the variables of one thread
+// group. It is safe for concurrent use.
+type Globals struct {
+	mu   sync.RWMutex
+	vars map[string]interface{}
+}
+
+func NewGlobals(vars map[string]interface{}) *Globals {
+	copied := make(map[string]interface{}, len(vars))
+	for k, v := range vars {
+		copied[k] = v
+	}
+	return &Globals{vars: copied}
+}
+
+func (g *Globals) Get(key string) (interface{}, bool) {
+	if g == nil {
+		return nil, false
+	}
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	v, ok := g.vars[key]
+	return v, ok
+}
+
+func (g *Globals) Set(key string, val interface{}) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.vars[key] = val
+}
+
+// SetIfAbsent stores val under key unless key already has a value, and reports
+// whether it stored it.
+func (g *Globals) SetIfAbsent(key string, val interface{}) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if _, ok := g.vars[key]; ok {
+		return false
+	}
+	g.vars[key] = val
+	return true
+}
+
+// Update replaces the value under key with update(old, ok) atomically, so concurrent
+// read-modify-write changes such as increments are not lost, and returns the new value.
+func (g *Globals) Update(key string, update func(old interface{}, ok bool) interface{}) interface{} {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	old, ok := g.vars[key]
+	val := update(old, ok)
+	g.vars[key] = val
+	return val
+}
+
+type globalsContextKey struct{}
+
+// WithGlobals attaches the run's global variables.
+func WithGlobals(ctx context.Context, globals *Globals) context.Context {
+	if ctx == nil || globals == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, globalsContextKey{}, globals)
+}
+
+func GlobalsFromContext(ctx context.Context) *Globals {
+	if ctx == nil {
+		return nil
+	}
+	globals, _ := ctx.Value(globalsContextKey{}).(*Globals)
+	return globals
+}
+
+type setupExportsContextKey struct{}
+
+// WithSetupExports attaches the variables the run's setup thread groups exported.
+// Threads read them after the run's globals and cannot change them.
+func WithSetupExports(ctx context.Context, exports *Globals) context.Context {
+	if ctx == nil || exports == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, setupExportsContextKey{}, exports)
+}
+
+func SetupExportsFromContext(ctx context.Context) *Globals {
+	if ctx == nil {
+		return nil
+	}
+	exports, _ := ctx.Value(setupExportsContextKey{}).(*Globals)
+	return exports
+}
+
+type groupVariablesContextKey struct{}
+
+// WithGroupVariables attaches the variables shared by the threads of a thread group.
+func WithGroupVariables(ctx context.Context, vars *Globals) context.Context {
+	if ctx == nil || vars == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, groupVariablesContextKey{}, vars)
+}
+
+func GroupVariablesFromContext(ctx context.Context) *Globals {
+	if ctx == nil {
+		return nil
+	}
+	vars, _ := ctx.Value(groupVariablesContextKey{}).(*Globals)
+	return vars
+}
+
+type variableExportsContextKey struct{}
+
+// WithVariableExports attaches the context that threads of a setup phase join their
+// variables into once they finish.
+func WithVariableExports(ctx context.Context, exports *Context) context.Context {
+	if ctx == nil || exports == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, variableExportsContextKey{}, exports)
+}
+
+func VariableExportsFromContext(ctx context.Context) *Context {
+	if ctx == nil {
+		return nil
+	}
+	exports, _ := ctx.Value(variableExportsContextKey{}).(*Context)
+	return exports
+}
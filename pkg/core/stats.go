@@ -2,16 +2,42 @@ package core
 
 import (
 	"context"
+	"math"
+	"sort"
 	"sync"
 	"time"
 )
 
 type Metric struct {
-	RPS           float64
-	AvgLatency    float64
-	Errors        int
-	TotalRequests int
-	TotalErrors   int
+	RPS            float64
+	AvgLatency     float64
+	P95Latency     float64 // Milliseconds, over the latest stats window
+	Errors         int
+	TotalRequests  int
+	TotalErrors    int
+	ActiveUsers    int     // Gauge, only set on "Total"
+	SustainableRPS float64 // Gauge, only set on "Total" once a capacity search reports
+}
+
+// ActiveUsersReporter is implemented by runners that track how many virtual users
+// each thread group currently runs.
+type ActiveUsersReporter interface {
+	SetActiveUsers(group string, users int)
+}
+
+// LiveStatsProvider is implemented by runners that publish interval metrics while the
+// test runs, which closed-loop thread groups read to judge the load they generate.
+type LiveStatsProvider interface {
+	Snapshot() map[string]Metric
+	// GroupSnapshot returns the request series of the thread group with the given ID,
+	// from samples tagged with it, by sampler name.
+	GroupSnapshot(group string) map[string]Metric
+}
+
+// SustainableRateReporter is implemented by runners that record the highest rate a
+// thread group found the system under test to sustain.
+type SustainableRateReporter interface {
+	SetSustainableRPS(group string, rps float64)
 }
 
 type StatsRunner struct {
@@ -20,13 +46,20 @@ type StatsRunner struct {
 	intervalCounts map[string]int
 	intervalErrors map[string]int
 	intervalLatSum map[string]time.Duration
+	intervalLats   map[string][]time.Duration
 
 	totalCounts map[string]int
 	totalErrors map[string]int
 	totalLatSum map[string]time.Duration
 
-	knownSamplers map[string]bool
-	latest        map[string]Metric
+	knownSamplers  map[string]bool
+	run            runTotals
+	activeUsers    map[string]int
+	sustainableRPS map[string]float64
+	latest         map[string]Metric
+
+	groups       map[string]*groupSeries
+	latestGroups map[string]map[string]Metric
 
 	reportInterval time.Duration
 
@@ -39,10 +72,15 @@ func NewStatsRunner(ctx context.Context, onUpdate func(data map[string]Metric))
 		intervalCounts: make(map[string]int),
 		intervalErrors: make(map[string]int),
 		intervalLatSum: make(map[string]time.Duration),
+		intervalLats:   make(map[string][]time.Duration),
 		totalCounts:    make(map[string]int),
 		totalErrors:    make(map[string]int),
 		totalLatSum:    make(map[string]time.Duration),
 		knownSamplers:  make(map[string]bool),
+		activeUsers:    make(map[string]int),
+		sustainableRPS: make(map[string]float64),
+		groups:         make(map[string]*groupSeries),
+		latestGroups:   make(map[string]map[string]Metric),
 		latest: map[string]Metric{
 			"Total": {},
 		},
@@ -59,9 +97,11 @@ func (sr *StatsRunner) ReportResult(result *SampleResult) {
 
 	name := result.SamplerName
 	sr.knownSamplers[name] = true
+	sr.run.add(result)
 
 	sr.intervalCounts[name]++
 	sr.intervalLatSum[name] += result.Duration()
+	sr.intervalLats[name] = append(sr.intervalLats[name], result.Duration())
 
 	sr.totalCounts[name]++
 	sr.totalLatSum[name] += result.Duration()
@@ -70,6 +110,39 @@ func (sr *StatsRunner) ReportResult(result *SampleResult) {
 		sr.intervalErrors[name]++
 		sr.totalErrors[name]++
 	}
+
+	if result.ThreadGroup != "" && result.Kind == SampleRequest {
+		group, ok := sr.groups[result.ThreadGroup]
+		if !ok {
+			group = newGroupSeries()
+			sr.groups[result.ThreadGroup] = group
+		}
+		group.add(result)
+	}
+}
+
+func (sr *StatsRunner) SetActiveUsers(group string, users int) {
+	sr.mu.Lock()
+	defer sr.mu.Unlock()
+	sr.activeUsers[group] = users
+}
+
+// ActiveUsers returns the virtual users each thread group currently runs, by the
+// group ID passed to SetActiveUsers.
+func (sr *StatsRunner) ActiveUsers() map[string]int {
+	sr.mu.RLock()
+	defer sr.mu.RUnlock()
+	out := make(map[string]int, len(sr.activeUsers))
+	for group, users := range sr.activeUsers {
+		out[group] = users
+	}
+	return out
+}
+
+func (sr *StatsRunner) SetSustainableRPS(group string, rps float64) {
+	sr.mu.Lock()
+	defer sr.mu.Unlock()
+	sr.sustainableRPS[group] = rps
 }
 
 func (sr *StatsRunner) Snapshot() map[string]Metric {
@@ -86,6 +159,17 @@ func (sr *StatsRunner) Snapshot() map[string]Metric {
 	return out
 }
 
+func (sr *StatsRunner) GroupSnapshot(group string) map[string]Metric {
+	sr.mu.RLock()
+	defer sr.mu.RUnlock()
+
+	out := make(map[string]Metric, len(sr.latestGroups[group]))
+	for k, v := range sr.latestGroups[group] {
+		out[k] = v
+	}
+	return out
+}
+
 func (sr *StatsRunner) reportLoop(ctx context.Context) {
 	ticker := time.NewTicker(sr.reportInterval)
 	defer ticker.Stop()
@@ -112,12 +196,6 @@ func (sr *StatsRunner) publishIntervalSnapshot() {
 
 	data := make(map[string]Metric, len(sr.knownSamplers)+1)
 
-	totalIntervalCount := 0
-	totalIntervalErrors := 0
-	var totalIntervalLatSum time.Duration
-	totalRequestCount := 0
-	totalErrorCount := 0
-
 	for sampler := range sr.knownSamplers {
 		intervalCount := sr.intervalCounts[sampler]
 		intervalErrors := sr.intervalErrors[sampler]
@@ -126,12 +204,6 @@ func (sr *StatsRunner) publishIntervalSnapshot() {
 		totalCount := sr.totalCounts[sampler]
 		totalErrors := sr.totalErrors[sampler]
 
-		totalIntervalCount += intervalCount
-		totalIntervalErrors += intervalErrors
-		totalIntervalLatSum += intervalLatSum
-		totalRequestCount += totalCount
-		totalErrorCount += totalErrors
-
 		avgLatency := 0.0
 		if intervalCount > 0 {
 			avgLatency = float64(intervalLatSum.Milliseconds()) / float64(intervalCount)
@@ -140,6 +212,7 @@ func (sr *StatsRunner) publishIntervalSnapshot() {
 		data[sampler] = Metric{
 			RPS:           float64(intervalCount) / windowSeconds,
 			AvgLatency:    avgLatency,
+			P95Latency:    p95Millis(sr.intervalLats[sampler]),
 			Errors:        intervalErrors,
 			TotalRequests: totalCount,
 			TotalErrors:   totalErrors,
@@ -147,23 +220,43 @@ func (sr *StatsRunner) publishIntervalSnapshot() {
 	}
 
 	totalAvgLatency := 0.0
-	if totalIntervalCount > 0 {
-		totalAvgLatency = float64(totalIntervalLatSum.Milliseconds()) / float64(totalIntervalCount)
+	if sr.run.intervalCount > 0 {
+		totalAvgLatency = float64(sr.run.intervalLatSum.Milliseconds()) / float64(sr.run.intervalCount)
+	}
+
+	activeUsers := 0
+	for _, users := range sr.activeUsers {
+		activeUsers += users
+	}
+
+	sustainableRPS := 0.0
+	for _, rps := range sr.sustainableRPS {
+		sustainableRPS = math.Max(sustainableRPS, rps)
 	}
 
 	data["Total"] = Metric{
-		RPS:           float64(totalIntervalCount) / windowSeconds,
-		AvgLatency:    totalAvgLatency,
-		Errors:        totalIntervalErrors,
-		TotalRequests: totalRequestCount,
-		TotalErrors:   totalErrorCount,
+		RPS:            float64(sr.run.intervalCount) / windowSeconds,
+		AvgLatency:     totalAvgLatency,
+		P95Latency:     p95Millis(sr.run.intervalLats),
+		Errors:         sr.run.intervalErrors,
+		TotalRequests:  sr.run.totalCount,
+		TotalErrors:    sr.run.totalErrors,
+		ActiveUsers:    activeUsers,
+		SustainableRPS: sustainableRPS,
 	}
 
 	sr.latest = data
 
+	sr.latestGroups = make(map[string]map[string]Metric, len(sr.groups))
+	for id, group := range sr.groups {
+		sr.latestGroups[id] = group.publish(windowSeconds)
+	}
+
 	sr.intervalCounts = make(map[string]int, len(sr.intervalCounts))
 	sr.intervalErrors = make(map[string]int, len(sr.intervalErrors))
 	sr.intervalLatSum = make(map[string]time.Duration, len(sr.intervalLatSum))
+	sr.intervalLats = make(map[string][]time.Duration, len(sr.intervalLats))
+	sr.run.resetInterval()
 
 	if sr.OnUpdate != nil {
 		copyData := make(map[string]Metric, len(sr.latest))
@@ -173,3 +266,105 @@ func (sr *StatsRunner) publishIntervalSnapshot() {
 		sr.OnUpdate(copyData)
 	}
 }
+
+// runTotals accumulates the Total series. Every sample counts by its own kind, so a
+// transaction and a sampler that share a name are still told apart.
+type runTotals struct {
+	intervalCount  int
+	intervalErrors int
+	intervalLatSum time.Duration
+	intervalLats   []time.Duration
+	totalCount     int
+	totalErrors    int
+}
+
+func (t *runTotals) add(result *SampleResult) {
+	failed := !result.Success || result.Error != nil
+	switch result.Kind {
+	case SampleRequest:
+		t.intervalCount++
+		t.intervalLatSum += result.Duration()
+		t.intervalLats = append(t.intervalLats, result.Duration())
+		t.totalCount++
+	case SampleDroppedIteration:
+		// A dropped iteration sent no request but is an error of the run
+	default:
+		return
+	}
+	if failed {
+		t.intervalErrors++
+		t.totalErrors++
+	}
+}
+
+func (t *runTotals) resetInterval() {
+	t.intervalCount = 0
+	t.intervalErrors = 0
+	t.intervalLatSum = 0
+	t.intervalLats = nil
+}
+
+// groupSeries accumulates the request samples of one thread group by sampler name.
+type groupSeries struct {
+	intervalLats   map[string][]time.Duration
+	intervalErrors map[string]int
+	totalCounts    map[string]int
+	totalErrors    map[string]int
+}
+
+func newGroupSeries() *groupSeries {
+	return &groupSeries{
+		intervalLats:   make(map[string][]time.Duration),
+		intervalErrors: make(map[string]int),
+		totalCounts:    make(map[string]int),
+		totalErrors:    make(map[string]int),
+	}
+}
+
+func (g *groupSeries) add(result *SampleResult) {
+	name := result.SamplerName
+	g.intervalLats[name] = append(g.intervalLats[name], result.Duration())
+	g.totalCounts[name]++
+	if !result.Success || result.Error != nil {
+		g.intervalErrors[name]++
+		g.totalErrors[name]++
+	}
+}
+
+// publish returns the group's metrics for the window that ends now and starts the next.
+func (g *groupSeries) publish(windowSeconds float64) map[string]Metric {
+	data := make(map[string]Metric, len(g.totalCounts))
+	for name, total := range g.totalCounts {
+		lats := g.intervalLats[name]
+		var latSum time.Duration
+		for _, lat := range lats {
+			latSum += lat
+		}
+		avgLatency := 0.0
+		if len(lats) > 0 {
+			avgLatency = float64(latSum.Milliseconds()) / float64(len(lats))
+		}
+		data[name] = Metric{
+			RPS:           float64(len(lats)) / windowSeconds,
+			AvgLatency:    avgLatency,
+			P95Latency:    p95Millis(lats),
+			Errors:        g.intervalErrors[name],
+			TotalRequests: total,
+			TotalErrors:   g.totalErrors[name],
+		}
+	}
+	g.intervalLats = make(map[string][]time.Duration, len(g.intervalLats))
+	g.intervalErrors = make(map[string]int, len(g.intervalErrors))
+	return data
+}
+
+// p95Millis returns the nearest-rank 95th percentile of latencies in milliseconds.
+// latencies is sorted in place.
+func p95Millis(latencies []time.Duration) float64 {
+	if len(latencies) == 0 {
+		return 0
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	rank := int(math.Ceil(0.95*float64(len(latencies)))) - 1
+	return float64(latencies[rank].Microseconds()) / 1000
+}
@@ -95,6 +95,8 @@ func humanizeElementType(typeName string) string {
 		return "If Controller"
 	case "PauseController":
 		return "Pause Controller"
+	case "CSVDataSet":
+		return "CSV Data Set"
 	}
 
 	var b strings.Builder
@@ -0,0 +1,559 @@
+package elements
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"perfolizer/pkg/core"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+func init() {
+	core.RegisterFactory("CSVDataSet", func(name string, props map[string]interface{}) core.TestElement {
+		d := NewCSVDataSet(name, core.GetString(props, "Filename", ""))
+		d.Delimiter = core.GetString(props, "Delimiter", d.Delimiter)
+		d.VariableNames = core.GetString(props, "VariableNames", "")
+		d.HeaderRow = core.GetBool(props, "HeaderRow", false)
+		d.Sharing = core.GetString(props, "Sharing", d.Sharing)
+		d.RecycleOnEOF = core.GetBool(props, "RecycleOnEOF", d.RecycleOnEOF)
+		d.StopThreadOnEOF = core.GetBool(props, "StopThreadOnEOF", false)
+		d.Data = core.GetString(props, "Data", "")
+		d.Bundled = core.GetBool(props, "Bundled", false)
+		return d
+	})
+	core.RegisterFactory("Counter", func(name string, props map[string]interface{}) core.TestElement {
+		c := NewCounter(name, core.GetString(props, "VariableName", ""))
+		c.Start = int64(core.GetInt(props, "Start", int(c.Start)))
+		c.Increment = int64(core.GetInt(props, "Increment", int(c.Increment)))
+		c.Max = int64(core.GetInt(props, "Max", 0))
+		c.Format = core.GetString(props, "Format", "")
+		c.PerUser = core.GetBool(props, "PerUser", false)
+		c.ResetPerIteration = core.GetBool(props, "ResetPerIteration", false)
+		return c
+	})
+	core.RegisterFactory("RandomVariable", func(name string, props map[string]interface{}) core.TestElement {
+		r := NewRandomVariable(name, core.GetString(props, "VariableName", ""))
+		r.Min = int64(core.GetInt(props, "Min", int(r.Min)))
+		r.Max = int64(core.GetInt(props, "Max", int(r.Max)))
+		r.Format = core.GetString(props, "Format", "")
+		r.Seed = uint64(core.GetInt(props, "Seed", 0))
+		return r
+	})
+}
+
+// ErrStopThread is returned up the element tree by elements that end their thread,
+// such as a CSVDataSet out of rows. The thread stops whatever its on-sample-error
+// policy is.
+var ErrStopThread = errors.New("thread stopped")
+
+// --- CSV Data Set ---
+
+const (
+	// CSVSharingAll makes every thread of the run read from one cursor per file and
+	// parsing options.
+	CSVSharingAll = "All threads"
+	// CSVSharingGroup makes the threads of a thread group read from one cursor.
+	CSVSharingGroup = "Thread group"
+	// CSVSharingThread gives every thread its own cursor from the first row.
+	CSVSharingThread = "Thread"
+)
+
+// CSVSharingModes lists the supported sharing modes in display order.
+var CSVSharingModes = []string{CSVSharingAll, CSVSharingGroup, CSVSharingThread}
+
+// CSVEOF is the value the variables take when a CSVDataSet that neither recycles nor
+// stops the thread runs out of rows.
+const CSVEOF = "<EOF>"
+
+// CSVDataSet reads the next row of a CSV file into variables every time it is
+// executed, so it is usually the first step of a thread group. Filename is relative
+// to the project file. BundleDataFiles copies the file contents into Data and sets
+// Bundled, which is how the file reaches a remote agent; without it the file is read
+// from the local disk.
+type CSVDataSet struct {
+	core.BaseElement
+	Filename        string
+	Delimiter       string // One character; `\t` stands for a tab
+	VariableNames   string // Comma separated; empty takes the names from the header row
+	HeaderRow       bool   // The first row holds column names and is not read as data
+	Sharing         string
+	RecycleOnEOF    bool
+	StopThreadOnEOF bool
+	Data            string
+	Bundled         bool // Data holds the file contents, even when the file is empty
+	state           *csvDataSetState
+}
+
+type csvDataSetState struct {
+	once  sync.Once
+	names []string
+	rows  [][]string
+	err   error
+	group *csvCursor
+}
+
+// csvCursor is the position of the next row to read.
+type csvCursor struct {
+	mu   sync.Mutex
+	next int
+}
+
+func NewCSVDataSet(name, filename string) *CSVDataSet {
+	return &CSVDataSet{
+		BaseElement:  core.NewBaseElement(name),
+		Filename:     filename,
+		Delimiter:    ",",
+		Sharing:      CSVSharingAll,
+		RecycleOnEOF: true,
+		state:        &csvDataSetState{group: &csvCursor{}},
+	}
+}
+
+func (d *CSVDataSet) GetType() string {
+	return "CSVDataSet"
+}
+
+func (d *CSVDataSet) GetProps() map[string]interface{} {
+	props := map[string]interface{}{
+		"Filename":        d.Filename,
+		"Delimiter":       d.Delimiter,
+		"VariableNames":   d.VariableNames,
+		"HeaderRow":       d.HeaderRow,
+		"Sharing":         d.Sharing,
+		"RecycleOnEOF":    d.RecycleOnEOF,
+		"StopThreadOnEOF": d.StopThreadOnEOF,
+	}
+	if d.Bundled {
+		props["Data"] = d.Data
+		props["Bundled"] = true
+	}
+	return props
+}
+
+func (d *CSVDataSet) Clone() core.TestElement {
+	newD := *d
+	newD.BaseElement = core.NewBaseElement(d.Name())
+	newD.state = &csvDataSetState{group: &csvCursor{}}
+	return &newD
+}
+
+func (d *CSVDataSet) Validate() error {
+	if strings.TrimSpace(d.Filename) == "" {
+		return fmt.Errorf("Filename is required")
+	}
+	if _, err := d.delimiter(); err != nil {
+		return err
+	}
+	names := splitVariableNames(d.VariableNames)
+	if len(names) == 0 && !d.HeaderRow {
+		return fmt.Errorf("Variable names are required without a header row")
+	}
+	for _, name := range names {
+		if err := ValidateVariableName("Variable names", name); err != nil {
+			return err
+		}
+	}
+	for _, mode := range CSVSharingModes {
+		if d.Sharing == mode {
+			return nil
+		}
+	}
+	return fmt.Errorf("Sharing must be one of %s", strings.Join(CSVSharingModes, ", "))
+}
+
+func (d *CSVDataSet) delimiter() (rune, error) {
+	if d.Delimiter == `\t` {
+		return '\t', nil
+	}
+	r, size := utf8.DecodeRuneInString(d.Delimiter)
+	if size == 0 || size != len(d.Delimiter) || r == '"' || r == '\r' || r == '\n' || r == utf8.RuneError {
+		return 0, fmt.Errorf("Delimiter must be a single character other than a quote or line break")
+	}
+	return r, nil
+}
+
+func splitVariableNames(value string) []string {
+	var names []string
+	for _, name := range strings.Split(value, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+func (d *CSVDataSet) Execute(ctx *core.Context) error {
+	if d.state == nil {
+		d.state = &csvDataSetState{group: &csvCursor{}}
+	}
+	s := d.state
+	s.once.Do(func() { s.names, s.rows, s.err = d.load() })
+	if s.err != nil {
+		return fmt.Errorf("CSV data set %q: %w", d.Name(), s.err)
+	}
+
+	row, ok := d.cursor(ctx).take(len(s.rows), d.RecycleOnEOF)
+	if !ok {
+		if d.StopThreadOnEOF {
+			return fmt.Errorf("CSV data set %q reached the end of %s: %w", d.Name(), d.Filename, ErrStopThread)
+		}
+		for _, name := range s.names {
+			ctx.SetVar(name, CSVEOF)
+		}
+		return nil
+	}
+
+	values := s.rows[row]
+	for i, name := range s.names {
+		value := ""
+		if i < len(values) {
+			value = values[i]
+		}
+		ctx.SetVar(name, value)
+	}
+	return nil
+}
+
+// take returns the index of the next of n rows, wrapping around when recycle is set.
+func (c *csvCursor) take(n int, recycle bool) (int, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.next >= n {
+		if !recycle || n == 0 {
+			return 0, false
+		}
+		c.next = 0
+	}
+	row := c.next
+	c.next++
+	return row, true
+}
+
+func (d *CSVDataSet) cursor(ctx *core.Context) *csvCursor {
+	switch d.Sharing {
+	case CSVSharingThread:
+		key := "CSV_" + d.ID()
+		c, ok := ctx.GetVar(key).(*csvCursor)
+		if !ok {
+			c = &csvCursor{}
+			ctx.SetStateVar(key, c)
+		}
+		return c
+	case CSVSharingGroup:
+		return d.state.group
+	default:
+		store := core.RunStoreFromContext(ctx)
+		if store == nil {
+			store = standaloneRunStore
+		}
+		return store.GetOrCreate(d.sharedCursorKey(), func() interface{} {
+			return &csvCursor{}
+		}).(*csvCursor)
+	}
+}
+
+// sharedCursorKey identifies the run-wide cursor of the data sets whose rows line up:
+// the same file read with the same delimiter and header row setting.
+func (d *CSVDataSet) sharedCursorKey() string {
+	return fmt.Sprintf("csv:%s|%q|%t", filepath.Clean(d.Filename), d.Delimiter, d.HeaderRow)
+}
+
+// load parses the bundled data, or the file when it was not bundled, into the
+// variable names and data rows.
+func (d *CSVDataSet) load() ([]string, [][]string, error) {
+	comma, err := d.delimiter()
+	if err != nil {
+		return nil, nil, err
+	}
+	data := d.Data
+	if !d.Bundled {
+		raw, err := os.ReadFile(d.Filename)
+		if err != nil {
+			return nil, nil, err
+		}
+		data = string(raw)
+	}
+
+	reader := csv.NewReader(strings.NewReader(data))
+	reader.Comma = comma
+	reader.LazyQuotes = true
+	reader.FieldsPerRecord = -1
+	var rows [][]string
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		rows = append(rows, record)
+	}
+
+	names := splitVariableNames(d.VariableNames)
+	if d.HeaderRow && len(rows) > 0 {
+		if len(names) == 0 {
+			for _, name := range rows[0] {
+				names = append(names, strings.TrimSpace(name))
+			}
+		}
+		rows = rows[1:]
+	}
+	return names, rows, nil
+}
+
+// BundleDataFiles reads the files of the enabled data set elements under root into
+// their Data and marks them Bundled so the plan carries them to a remote agent. Relative paths are resolved
+// against baseDir. root is modified in place, so callers pass a copy such as the one
+// ResolveModules returns.
+func BundleDataFiles(root core.TestElement, baseDir string) error {
+	if d, ok := root.(*CSVDataSet); ok && d.Enabled() {
+		path := strings.TrimSpace(d.Filename)
+		if !filepath.IsAbs(path) && baseDir != "" {
+			path = filepath.Join(baseDir, path)
+		}
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("CSV data set %q: %w", d.Name(), err)
+		}
+		d.Data = string(raw)
+		d.Bundled = true
+	}
+	for _, child := range root.GetChildren() {
+		if !child.Enabled() {
+			continue
+		}
+		if err := BundleDataFiles(child, baseDir); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ValidateBundledDataFiles reports the first enabled CSVDataSet in root whose file
+// BundleDataFiles has not embedded. An agent runs plans sent over the network, so it
+// refuses to read the files they name from its own disk.
+func ValidateBundledDataFiles(root core.TestElement) error {
+	if d, ok := root.(*CSVDataSet); ok && d.Enabled() && !d.Bundled {
+		return &core.ValidationError{Err: fmt.Errorf("CSV Data Set %q: file %s is not bundled", d.Name(), d.Filename)}
+	}
+	for _, child := range root.GetChildren() {
+		if !child.Enabled() {
+			continue
+		}
+		if err := ValidateBundledDataFiles(child); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func formatInt(format string, value int64) string {
+	if format == "" {
+		return strconv.FormatInt(value, 10)
+	}
+	return fmt.Sprintf(format, value)
+}
+
+// --- Counter ---
+
+// Counter sets VariableName before every sampler in its scope to Start, then Start
+// plus Increment and so on, wrapping back to Start after passing a non-zero Max. A
+// global counter is shared by the threads of its thread group; a PerUser counter is
+// kept by each thread and, with ResetPerIteration, starts over every iteration.
+type Counter struct {
+	core.BaseElement
+	VariableName      string
+	Start             int64
+	Increment         int64
+	Max               int64  // 0 = no maximum
+	Format            string // fmt verb such as ORD-%06d; empty prints the number
+	PerUser           bool
+	ResetPerIteration bool
+	state             *counterState
+}
+
+type counterState struct {
+	mu      sync.Mutex
+	started bool
+	value   int64
+}
+
+// userCounter is the position of a PerUser counter, kept in the thread's variables.
+type userCounter struct {
+	value     int64
+	iteration int
+}
+
+func NewCounter(name, variableName string) *Counter {
+	return &Counter{
+		BaseElement:  core.NewBaseElement(name),
+		VariableName: variableName,
+		Start:        1,
+		Increment:    1,
+		state:        &counterState{},
+	}
+}
+
+func (c *Counter) GetType() string {
+	return "Counter"
+}
+
+func (c *Counter) GetProps() map[string]interface{} {
+	return map[string]interface{}{
+		"VariableName":      c.VariableName,
+		"Start":             c.Start,
+		"Increment":         c.Increment,
+		"Max":               c.Max,
+		"Format":            c.Format,
+		"PerUser":           c.PerUser,
+		"ResetPerIteration": c.ResetPerIteration,
+	}
+}
+
+func (c *Counter) Clone() core.TestElement {
+	newC := *c
+	newC.BaseElement = core.NewBaseElement(c.Name())
+	newC.state = &counterState{}
+	return &newC
+}
+
+func (c *Counter) Validate() error {
+	if err := ValidateVariableName("Variable name", c.VariableName); err != nil {
+		return err
+	}
+	if c.Increment == 0 {
+		return fmt.Errorf("Increment must not be 0")
+	}
+	if c.Max != 0 && c.Increment > 0 && c.Max < c.Start {
+		return fmt.Errorf("Max must be greater than or equal to start")
+	}
+	if c.Max != 0 && c.Increment < 0 && c.Max > c.Start {
+		return fmt.Errorf("Max must be less than or equal to start with a negative increment")
+	}
+	return ValidateIntFormat("Format", c.Format)
+}
+
+// next returns the value after v, wrapping to Start once it passes Max.
+func (c *Counter) next(v int64) int64 {
+	v += c.Increment
+	if c.Max != 0 && ((c.Increment > 0 && v > c.Max) || (c.Increment < 0 && v < c.Max)) {
+		return c.Start
+	}
+	return v
+}
+
+func (c *Counter) BeforeSample(ctx *core.Context) error {
+	var value int64
+	if c.PerUser {
+		key := "Counter_" + c.ID()
+		u, ok := ctx.GetVar(key).(userCounter)
+		switch {
+		case !ok || (c.ResetPerIteration && u.iteration != ctx.Iteration):
+			u = userCounter{value: c.Start, iteration: ctx.Iteration}
+		default:
+			u.value = c.next(u.value)
+		}
+		ctx.SetStateVar(key, u)
+		value = u.value
+	} else {
+		if c.state == nil {
+			c.state = &counterState{}
+		}
+		s := c.state
+		s.mu.Lock()
+		if s.started {
+			s.value = c.next(s.value)
+		} else {
+			s.started = true
+			s.value = c.Start
+		}
+		value = s.value
+		s.mu.Unlock()
+	}
+	ctx.SetVar(strings.TrimSpace(c.VariableName), formatInt(c.Format, value))
+	return nil
+}
+
+// --- Random Variable ---
+
+// RandomVariable sets VariableName before every sampler in its scope to a number
+// between Min and Max, both included. Every thread draws from its own generator; a
+// non-zero Seed makes each thread's sequence repeat from run to run.
+type RandomVariable struct {
+	core.BaseElement
+	VariableName string
+	Min          int64
+	Max          int64
+	Format       string // fmt verb such as %04d; empty prints the number
+	Seed         uint64 // 0 = seeded randomly
+}
+
+func NewRandomVariable(name, variableName string) *RandomVariable {
+	return &RandomVariable{
+		BaseElement:  core.NewBaseElement(name),
+		VariableName: variableName,
+		Min:          1,
+		Max:          100,
+	}
+}
+
+func (r *RandomVariable) GetType() string {
+	return "RandomVariable"
+}
+
+func (r *RandomVariable) GetProps() map[string]interface{} {
+	return map[string]interface{}{
+		"VariableName": r.VariableName,
+		"Min":          r.Min,
+		"Max":          r.Max,
+		"Format":       r.Format,
+		"Seed":         r.Seed,
+	}
+}
+
+func (r *RandomVariable) Clone() core.TestElement {
+	newR := *r
+	newR.BaseElement = core.NewBaseElement(r.Name())
+	return &newR
+}
+
+func (r *RandomVariable) Validate() error {
+	if err := ValidateVariableName("Variable name", r.VariableName); err != nil {
+		return err
+	}
+	if r.Max < r.Min {
+		return fmt.Errorf("Max must be greater than or equal to min")
+	}
+	return ValidateIntFormat("Format", r.Format)
+}
+
+func (r *RandomVariable) BeforeSample(ctx *core.Context) error {
+	key := "Random_" + r.ID()
+	rng, ok := ctx.GetVar(key).(*rand.Rand)
+	if !ok {
+		seed := r.Seed
+		if seed == 0 {
+			seed = rand.Uint64()
+		}
+		rng = rand.New(rand.NewPCG(seed, uint64(ctx.ThreadID)))
+		ctx.SetStateVar(key, rng)
+	}
+	// The span is counted in uint64 so ranges wider than MaxInt64 do not overflow
+	span := uint64(r.Max) - uint64(r.Min)
+	offset := rng.Uint64()
+	if span < math.MaxUint64 {
+		offset = rng.Uint64N(span + 1)
+	}
+	value := r.Min + int64(offset)
+	ctx.SetVar(strings.TrimSpace(r.VariableName), formatInt(r.Format, value))
+	return nil
+}
@@ -1,7 +1,14 @@
 package elements
 
 import (
+	"fmt"
+	"log"
+	"math"
+	"math/rand/v2"
 	"perfolizer/pkg/core"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -13,10 +20,69 @@ func init() {
 		}
 	})
 	core.RegisterFactory("IfController", func(name string, props map[string]interface{}) core.TestElement {
-		return &IfController{
-			BaseElement: core.NewBaseElement(name),
-			Condition:   func(ctx *core.Context) bool { return true }, // Scripting not supported in JSON yet
+		return NewExpressionIfController(name, core.GetString(props, "Expression", "true")) // Legacy plans without an expression always ran
+	})
+	core.RegisterFactory("WhileController", func(name string, props map[string]interface{}) core.TestElement {
+		w := NewWhileController(name, core.GetString(props, "Expression", ""))
+		w.MaxIterations = core.GetInt(props, "MaxIterations", 0)
+		w.Timeout = time.Duration(core.GetInt(props, "TimeoutMS", 0)) * time.Millisecond
+		return w
+	})
+	core.RegisterFactory("TransactionController", func(name string, props map[string]interface{}) core.TestElement {
+		t := NewTransactionController(name)
+		t.IncludePauses = core.GetBool(props, "IncludePauses", false)
+		t.ReportChildren = core.GetBool(props, "ReportChildren", true)
+		return t
+	})
+	core.RegisterFactory("RandomController", func(name string, props map[string]interface{}) core.TestElement {
+		return NewRandomController(name)
+	})
+	core.RegisterFactory("WeightedSwitchController", func(name string, props map[string]interface{}) core.TestElement {
+		w := NewWeightedSwitchController(name)
+		if weights := core.GetFloatMap(props, "Weights"); weights != nil {
+			w.Weights = weights
 		}
+		return w
+	})
+	core.RegisterFactory("OnceOnlyController", func(name string, props map[string]interface{}) core.TestElement {
+		return NewOnceOnlyController(name)
+	})
+	core.RegisterFactory("InterleaveController", func(name string, props map[string]interface{}) core.TestElement {
+		i := NewInterleaveController(name)
+		i.Shared = core.GetBool(props, "Shared", false)
+		return i
+	})
+	core.RegisterFactory("ForEachController", func(name string, props map[string]interface{}) core.TestElement {
+		f := NewForEachController(name, core.GetString(props, "InputVariable", ""), core.GetString(props, "OutputVariable", ""))
+		f.Source = core.GetString(props, "Source", ForEachSourcePrefix)
+		f.IndexVariable = core.GetString(props, "IndexVariable", "")
+		return f
+	})
+	core.RegisterFactory("ParallelController", func(name string, props map[string]interface{}) core.TestElement {
+		p := NewParallelController(name, core.GetInt(props, "MaxConcurrency", 0))
+		p.ReportTiming = core.GetBool(props, "ReportTiming", false)
+		return p
+	})
+	core.RegisterFactory("ThroughputController", func(name string, props map[string]interface{}) core.TestElement {
+		t := NewThroughputController(name, core.GetString(props, "Mode", ThroughputModePercent))
+		t.Percent = core.GetFloat(props, "Percent", t.Percent)
+		t.Executions = core.GetInt(props, "Executions", t.Executions)
+		t.Shared = core.GetBool(props, "Shared", false)
+		return t
+	})
+	core.RegisterFactory("RuntimeController", func(name string, props map[string]interface{}) core.TestElement {
+		return NewRuntimeController(name, time.Duration(core.GetInt(props, "DurationMS", 60000))*time.Millisecond)
+	})
+	core.RegisterFactory("ModuleController", func(name string, props map[string]interface{}) core.TestElement {
+		m := NewModuleController(name, core.GetString(props, "PlanName", ""), core.GetString(props, "ElementID", ""))
+		m.File = core.GetString(props, "File", "")
+		m.Resolved = core.GetBool(props, "Resolved", false)
+		return m
+	})
+	core.RegisterFactory("CriticalSectionController", func(name string, props map[string]interface{}) core.TestElement {
+		c := NewCriticalSectionController(name, core.GetString(props, "LockName", "global_lock"))
+		c.ReportWaitTime = core.GetBool(props, "ReportWaitTime", true)
+		return c
 	})
 	core.RegisterFactory("PauseController", func(name string, props map[string]interface{}) core.TestElement {
 		return &PauseController{
@@ -45,9 +111,172 @@ func (c *IfController) GetType() string {
 }
 
 func (c *IfController) GetProps() map[string]interface{} {
+	return map[string]interface{}{
+		"Expression": c.Expression,
+	}
+}
+
+// ... WhileController methods ...
+
+func (w *WhileController) GetType() string {
+	return "WhileController"
+}
+
+func (w *WhileController) GetProps() map[string]interface{} {
+	return map[string]interface{}{
+		"Expression":    w.Expression,
+		"MaxIterations": w.MaxIterations,
+		"TimeoutMS":     w.Timeout.Milliseconds(),
+	}
+}
+
+// ... TransactionController methods ...
+
+func (t *TransactionController) GetType() string {
+	return "TransactionController"
+}
+
+func (t *TransactionController) GetProps() map[string]interface{} {
+	return map[string]interface{}{
+		"IncludePauses":  t.IncludePauses,
+		"ReportChildren": t.ReportChildren,
+	}
+}
+
+// ... RandomController methods ...
+
+func (r *RandomController) GetType() string {
+	return "RandomController"
+}
+
+func (r *RandomController) GetProps() map[string]interface{} {
+	return map[string]interface{}{}
+}
+
+// ... WeightedSwitchController methods ...
+
+func (w *WeightedSwitchController) GetType() string {
+	return "WeightedSwitchController"
+}
+
+func (w *WeightedSwitchController) GetProps() map[string]interface{} {
+	weights := make(map[string]float64, len(w.Weights))
+	for id, weight := range w.Weights {
+		weights[id] = weight
+	}
+	return map[string]interface{}{
+		"Weights": weights,
+	}
+}
+
+// ... OnceOnlyController methods ...
+
+func (o *OnceOnlyController) GetType() string {
+	return "OnceOnlyController"
+}
+
+func (o *OnceOnlyController) GetProps() map[string]interface{} {
 	return map[string]interface{}{}
 }
 
+// ... InterleaveController methods ...
+
+func (i *InterleaveController) GetType() string {
+	return "InterleaveController"
+}
+
+func (i *InterleaveController) GetProps() map[string]interface{} {
+	return map[string]interface{}{
+		"Shared": i.Shared,
+	}
+}
+
+// ... ForEachController methods ...
+
+func (f *ForEachController) GetType() string {
+	return "ForEachController"
+}
+
+func (f *ForEachController) GetProps() map[string]interface{} {
+	return map[string]interface{}{
+		"Source":         f.Source,
+		"InputVariable":  f.InputVariable,
+		"OutputVariable": f.OutputVariable,
+		"IndexVariable":  f.IndexVariable,
+	}
+}
+
+// ... ParallelController methods ...
+
+func (p *ParallelController) GetType() string {
+	return "ParallelController"
+}
+
+func (p *ParallelController) GetProps() map[string]interface{} {
+	return map[string]interface{}{
+		"MaxConcurrency": p.MaxConcurrency,
+		"ReportTiming":   p.ReportTiming,
+	}
+}
+
+// ... ThroughputController methods ...
+
+func (t *ThroughputController) GetType() string {
+	return "ThroughputController"
+}
+
+func (t *ThroughputController) GetProps() map[string]interface{} {
+	return map[string]interface{}{
+		"Mode":       t.Mode,
+		"Percent":    t.Percent,
+		"Executions": t.Executions,
+		"Shared":     t.Shared,
+	}
+}
+
+// ... RuntimeController methods ...
+
+func (r *RuntimeController) GetType() string {
+	return "RuntimeController"
+}
+
+func (r *RuntimeController) GetProps() map[string]interface{} {
+	return map[string]interface{}{
+		"DurationMS": r.Duration.Milliseconds(),
+	}
+}
+
+// ... ModuleController methods ...
+
+func (m *ModuleController) GetType() string {
+	return "ModuleController"
+}
+
+func (m *ModuleController) GetProps() map[string]interface{} {
+	props := map[string]interface{}{
+		"PlanName":  m.PlanName,
+		"ElementID": m.ElementID,
+		"File":      m.File,
+	}
+	if m.Resolved {
+		props["Resolved"] = true
+	}
+	return props
+}
+
+// ... CriticalSectionController methods ...
+
+func (c *CriticalSectionController) GetType() string {
+	return "CriticalSectionController"
+}
+
+func (c *CriticalSectionController) GetProps() map[string]interface{} {
+	return map[string]interface{}{
+		"LockName":       c.LockName,
+		"ReportWaitTime": c.ReportWaitTime,
+	}
+}
+
 // ... PauseController methods ...
 
 func (p *PauseController) GetType() string {
@@ -103,14 +332,22 @@ func (l *LoopController) Execute(ctx *core.Context) error {
 			return ctx.Err()
 		}
 
-		for _, child := range l.GetChildren() {
-			if !child.Enabled() {
-				continue
-			}
-			if exec, ok := child.(core.Executable); ok {
-				if err := exec.Execute(ctx); err != nil {
-					return err
-				}
+		if err := executeChildren(ctx, l.GetChildren()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// executeChildren runs the enabled executable children in order and stops at the first error.
+func executeChildren(ctx *core.Context, children []core.TestElement) error {
+	for _, child := range children {
+		if !child.Enabled() {
+			continue
+		}
+		if exec, ok := child.(core.Executable); ok {
+			if err := exec.Execute(ctx); err != nil {
+				return err
 			}
 		}
 	}
@@ -121,7 +358,9 @@ func (l *LoopController) Execute(ctx *core.Context) error {
 
 type IfController struct {
 	core.BaseElement
-	Condition func(ctx *core.Context) bool
+	// Condition, when set, takes precedence over Expression. It is not persisted.
+	Condition  func(ctx *core.Context) bool
+	Expression string // Persisted condition expression, see expression.go
 }
 
 func NewIfController(name string, condition func(ctx *core.Context) bool) *IfController {
@@ -131,28 +370,964 @@ func NewIfController(name string, condition func(ctx *core.Context) bool) *IfCon
 	}
 }
 
+// NewExpressionIfController creates an IfController driven by a persisted condition expression.
+func NewExpressionIfController(name, expression string) *IfController {
+	return &IfController{
+		BaseElement: core.NewBaseElement(name),
+		Expression:  expression,
+	}
+}
+
 func (c *IfController) Clone() core.TestElement {
 	newC := *c
 	newC.BaseElement = core.NewBaseElement(c.Name())
 	return &newC
 }
 
+func (c *IfController) Validate() error {
+	if c.Condition != nil {
+		return nil
+	}
+	return ValidateExpression("Condition", c.Expression)
+}
+
 func (c *IfController) Execute(ctx *core.Context) error {
-	if c.Condition(ctx) {
-		for _, child := range c.GetChildren() {
-			if !child.Enabled() {
-				continue
+	ok, err := evaluateCondition(ctx, c.Condition, c.Expression)
+	if err != nil {
+		return fmt.Errorf("If Controller %q condition failed: %w", c.Name(), err)
+	}
+	if ok {
+		return executeChildren(ctx, c.GetChildren())
+	}
+	return nil
+}
+
+// evaluateCondition prefers a Go condition func and falls back to the expression text.
+func evaluateCondition(ctx *core.Context, condition func(ctx *core.Context) bool, expression string) (bool, error) {
+	if condition != nil {
+		return condition(ctx), nil
+	}
+	expr, err := compileExpressionCached(expression)
+	if err != nil {
+		return false, err
+	}
+	return expr.Eval(ctx)
+}
+
+// --- While Controller ---
+
+// WhileController repeats its children while its condition holds. The condition is
+// re-evaluated before every pass; MaxIterations and Timeout guard against conditions
+// that never become false.
+type WhileController struct {
+	core.BaseElement
+	Condition     func(ctx *core.Context) bool // Takes precedence over Expression; not persisted
+	Expression    string
+	MaxIterations int           // 0 for unlimited
+	Timeout       time.Duration // 0 for no timeout
+}
+
+func NewWhileController(name, expression string) *WhileController {
+	return &WhileController{
+		BaseElement: core.NewBaseElement(name),
+		Expression:  expression,
+	}
+}
+
+func (w *WhileController) Clone() core.TestElement {
+	newW := *w
+	newW.BaseElement = core.NewBaseElement(w.Name())
+	return &newW
+}
+
+func (w *WhileController) Validate() error {
+	if w.Condition == nil {
+		if err := ValidateExpression("Condition", w.Expression); err != nil {
+			return err
+		}
+	}
+	if err := ValidateNonNegative("Max iterations", w.MaxIterations); err != nil {
+		return err
+	}
+	return ValidateDuration("Timeout", w.Timeout)
+}
+
+func (w *WhileController) Execute(ctx *core.Context) error {
+	var deadline time.Time
+	if w.Timeout > 0 {
+		deadline = time.Now().Add(w.Timeout)
+	}
+
+	for i := 0; w.MaxIterations <= 0 || i < w.MaxIterations; i++ {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if !deadline.IsZero() && !time.Now().Before(deadline) {
+			log.Printf("Warning: While Controller %q stopped after timeout %v", w.Name(), w.Timeout)
+			return nil
+		}
+
+		ok, err := evaluateCondition(ctx, w.Condition, w.Expression)
+		if err != nil {
+			return fmt.Errorf("While Controller %q condition failed: %w", w.Name(), err)
+		}
+		if !ok {
+			return nil
+		}
+
+		if err := executeChildren(ctx, w.GetChildren()); err != nil {
+			return err
+		}
+	}
+
+	log.Printf("Warning: While Controller %q stopped after %d iteration(s)", w.Name(), w.MaxIterations)
+	return nil
+}
+
+// --- Transaction Controller ---
+
+// TransactionController times its children as one business transaction and reports
+// the result as its own sample. The transaction fails when any child sample fails.
+type TransactionController struct {
+	core.BaseElement
+	IncludePauses  bool // Count time spent in pauses towards the transaction duration
+	ReportChildren bool // Also report the individual child samples
+}
+
+func NewTransactionController(name string) *TransactionController {
+	return &TransactionController{
+		BaseElement:    core.NewBaseElement(name),
+		ReportChildren: true,
+	}
+}
+
+func (t *TransactionController) Clone() core.TestElement {
+	newT := *t
+	newT.BaseElement = core.NewBaseElement(t.Name())
+	return &newT
+}
+
+func (t *TransactionController) Execute(ctx *core.Context) error {
+	outer, _ := ctx.GetVar("Reporter").(core.Runner)
+	collector := &transactionCollector{next: outer, forward: t.ReportChildren}
+	outerPauses, _ := ctx.GetVar("PauseTracker").(*pauseTracker)
+	pauses := &pauseTracker{parent: outerPauses}
+
+	start := time.Now()
+	err := t.run(ctx, collector, pauses)
+	end := time.Now()
+
+	if ctx.Err() != nil {
+		return err
+	}
+
+	samples, failed, bytesReceived := collector.summary()
+	if samples == 0 && err == nil {
+		return nil
+	}
+
+	if !t.IncludePauses {
+		end = end.Add(-pauses.total())
+		if end.Before(start) {
+			end = start
+		}
+	}
+
+	result := &core.SampleResult{
+		SamplerName:   t.Name(),
+		StartTime:     start,
+		EndTime:       end,
+		Latency:       end.Sub(start),
+		Success:       failed == 0 && err == nil,
+		BytesReceived: bytesReceived,
+	}
+	if t.ReportChildren {
+		result.Kind = core.SampleTransaction
+	}
+	switch {
+	case err != nil:
+		result.Error = err
+	case failed > 0:
+		result.Error = fmt.Errorf("%d of %d sample(s) failed", failed, samples)
+	}
+	if reportErr := reportSample(ctx, result); err == nil {
+		err = reportErr
+	}
+	return err
+}
+
+// run executes the children with collector as the thread's Reporter and pauses as its
+// PauseTracker, restoring both however the children return.
+func (t *TransactionController) run(ctx *core.Context, collector *transactionCollector, pauses *pauseTracker) error {
+	defer swapStateVar(ctx, "Reporter", collector)()
+	defer swapStateVar(ctx, "PauseTracker", pauses)()
+	return executeChildren(ctx, t.GetChildren())
+}
+
+// swapStateVar sets the thread state key to val and returns a func that restores the
+// previous value, meant to be deferred.
+func swapStateVar(ctx *core.Context, key string, val interface{}) func() {
+	previous := ctx.GetVar(key)
+	ctx.SetStateVar(key, val)
+	return func() {
+		ctx.SetStateVar(key, previous)
+	}
+}
+
+// transactionCollector sits in front of the thread's Reporter while a transaction runs.
+type transactionCollector struct {
+	mu            sync.Mutex
+	next          core.Runner
+	forward       bool
+	samples       int
+	failed        int
+	bytesReceived int64
+}
+
+func (c *transactionCollector) ReportResult(result *core.SampleResult) {
+	c.mu.Lock()
+	c.samples++
+	if !result.Success || result.Error != nil {
+		c.failed++
+	}
+	c.bytesReceived += result.BytesReceived
+	c.mu.Unlock()
+
+	if c.forward && c.next != nil {
+		c.next.ReportResult(result)
+	}
+}
+
+func (c *transactionCollector) summary() (samples, failed int, bytesReceived int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.samples, c.failed, c.bytesReceived
+}
+
+// pauseTracker accumulates deliberate waiting so enclosing transactions can exclude it.
+type pauseTracker struct {
+	mu     sync.Mutex
+	parent *pauseTracker
+	waited time.Duration
+}
+
+func (p *pauseTracker) add(d time.Duration) {
+	for tracker := p; tracker != nil; tracker = tracker.parent {
+		tracker.mu.Lock()
+		tracker.waited += d
+		tracker.mu.Unlock()
+	}
+}
+
+func (p *pauseTracker) total() time.Duration {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.waited
+}
+
+// pause waits for d unless the thread is cancelled and records the time spent with
+// any enclosing transaction.
+func pause(ctx *core.Context, d time.Duration) error {
+	defer trackPause(ctx, time.Now())
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// trackPause records the time waited since start with any enclosing transaction.
+func trackPause(ctx *core.Context, start time.Time) {
+	if tracker, ok := ctx.GetVar("PauseTracker").(*pauseTracker); ok && tracker != nil {
+		tracker.add(time.Since(start))
+	}
+}
+
+// --- Random Controller ---
+
+// RandomController executes exactly one randomly chosen enabled child per pass.
+type RandomController struct {
+	core.BaseElement
+}
+
+func NewRandomController(name string) *RandomController {
+	return &RandomController{BaseElement: core.NewBaseElement(name)}
+}
+
+func (r *RandomController) Clone() core.TestElement {
+	newR := *r
+	newR.BaseElement = core.NewBaseElement(r.Name())
+	return &newR
+}
+
+func (r *RandomController) Execute(ctx *core.Context) error {
+	candidates := executableChildren(r.GetChildren())
+	if len(candidates) == 0 {
+		return nil
+	}
+	return candidates[rand.IntN(len(candidates))].(core.Executable).Execute(ctx)
+}
+
+// --- Weighted Switch Controller ---
+
+// WeightedSwitchController executes exactly one enabled child per pass, chosen with
+// probability proportional to its weight. Weights are keyed by child ID; children
+// without an explicit weight default to DefaultChildWeight.
+type WeightedSwitchController struct {
+	core.BaseElement
+	Weights map[string]float64
+}
+
+const DefaultChildWeight = 1.0
+
+func NewWeightedSwitchController(name string) *WeightedSwitchController {
+	return &WeightedSwitchController{
+		BaseElement: core.NewBaseElement(name),
+		Weights:     make(map[string]float64),
+	}
+}
+
+func (w *WeightedSwitchController) Clone() core.TestElement {
+	newW := *w
+	newW.BaseElement = core.NewBaseElement(w.Name())
+	newW.Weights = make(map[string]float64, len(w.Weights))
+	for id, weight := range w.Weights {
+		newW.Weights[id] = weight
+	}
+	return &newW
+}
+
+// WeightOf returns the effective weight of the child with the given ID.
+func (w *WeightedSwitchController) WeightOf(childID string) float64 {
+	if weight, ok := w.Weights[childID]; ok {
+		return weight
+	}
+	return DefaultChildWeight
+}
+
+func (w *WeightedSwitchController) SetWeight(childID string, weight float64) {
+	if w.Weights == nil {
+		w.Weights = make(map[string]float64)
+	}
+	w.Weights[childID] = weight
+}
+
+func (w *WeightedSwitchController) Validate() error {
+	total := 0.0
+	candidates := executableChildren(w.GetChildren())
+	for _, child := range candidates {
+		weight := w.WeightOf(child.ID())
+		if err := ValidateWeight(fmt.Sprintf("Weight of %q", child.Name()), weight); err != nil {
+			return err
+		}
+		total += weight
+	}
+	if len(candidates) > 0 && total <= 0 {
+		return fmt.Errorf("at least one child weight must be greater than 0")
+	}
+	return nil
+}
+
+func (w *WeightedSwitchController) Execute(ctx *core.Context) error {
+	candidates := executableChildren(w.GetChildren())
+	total := 0.0
+	for _, child := range candidates {
+		if weight := w.WeightOf(child.ID()); weight > 0 {
+			total += weight
+		}
+	}
+	if total <= 0 {
+		return nil
+	}
+
+	pick := rand.Float64() * total
+	for _, child := range candidates {
+		weight := w.WeightOf(child.ID())
+		if weight <= 0 {
+			continue
+		}
+		if pick < weight {
+			return child.(core.Executable).Execute(ctx)
+		}
+		pick -= weight
+	}
+	// Floating point rounding can leave pick just above the last bucket.
+	for i := len(candidates) - 1; i >= 0; i-- {
+		if w.WeightOf(candidates[i].ID()) > 0 {
+			return candidates[i].(core.Executable).Execute(ctx)
+		}
+	}
+	return nil
+}
+
+// --- Once Only Controller ---
+
+// OnceOnlyController runs its children once per thread, e.g. for a login that each
+// virtual user performs once. A pass counts once it produces a sample, so a request
+// skipped by an RPS limiter is retried on the next iteration.
+type OnceOnlyController struct {
+	core.BaseElement
+}
+
+func NewOnceOnlyController(name string) *OnceOnlyController {
+	return &OnceOnlyController{BaseElement: core.NewBaseElement(name)}
+}
+
+func (o *OnceOnlyController) Clone() core.TestElement {
+	newO := *o
+	newO.BaseElement = core.NewBaseElement(o.Name())
+	return &newO
+}
+
+func (o *OnceOnlyController) Execute(ctx *core.Context) error {
+	key := "OnceOnly_" + o.ID()
+	if done, _ := ctx.GetVar(key).(bool); done {
+		return nil
+	}
+	before := ctx.LastSample()
+	err := executeChildren(ctx, o.GetChildren())
+	if ctx.LastSample() != before {
+		ctx.SetStateVar(key, true)
+	}
+	return err
+}
+
+// --- Interleave Controller ---
+
+// InterleaveController runs one enabled child per pass in rotation. The rotation is
+// kept per thread by default, or shared across all threads of the group (round-robin).
+type InterleaveController struct {
+	core.BaseElement
+	Shared bool
+	shared *atomic.Uint64
+}
+
+func NewInterleaveController(name string) *InterleaveController {
+	return &InterleaveController{
+		BaseElement: core.NewBaseElement(name),
+		shared:      &atomic.Uint64{},
+	}
+}
+
+func (i *InterleaveController) Clone() core.TestElement {
+	newI := *i
+	newI.BaseElement = core.NewBaseElement(i.Name())
+	newI.shared = &atomic.Uint64{}
+	return &newI
+}
+
+func (i *InterleaveController) Execute(ctx *core.Context) error {
+	candidates := executableChildren(i.GetChildren())
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	var position uint64
+	if i.Shared && i.shared != nil {
+		position = i.shared.Add(1) - 1
+	} else {
+		key := "Interleave_" + i.ID()
+		next, _ := ctx.GetVar(key).(uint64)
+		position = next
+		ctx.SetStateVar(key, next+1)
+	}
+
+	return candidates[position%uint64(len(candidates))].(core.Executable).Execute(ctx)
+}
+
+// --- ForEach Controller ---
+
+const (
+	// ForEachSourcePrefix iterates over InputVariable_1 .. InputVariable_N.
+	ForEachSourcePrefix = "Prefix"
+	// ForEachSourceJSON iterates over a JSON array stored in InputVariable.
+	ForEachSourceJSON = "JSON"
+)
+
+// ForEachController runs its children once per item of a list held in the context.
+// The current item is bound to OutputVariable and its 1-based position to
+// IndexVariable (OutputVariable + "_index" when empty).
+type ForEachController struct {
+	core.BaseElement
+	Source         string
+	InputVariable  string
+	OutputVariable string
+	IndexVariable  string
+}
+
+func NewForEachController(name, inputVariable, outputVariable string) *ForEachController {
+	return &ForEachController{
+		BaseElement:    core.NewBaseElement(name),
+		Source:         ForEachSourcePrefix,
+		InputVariable:  inputVariable,
+		OutputVariable: outputVariable,
+	}
+}
+
+func (f *ForEachController) Clone() core.TestElement {
+	newF := *f
+	newF.BaseElement = core.NewBaseElement(f.Name())
+	return &newF
+}
+
+func (f *ForEachController) Validate() error {
+	if f.Source != ForEachSourcePrefix && f.Source != ForEachSourceJSON {
+		return fmt.Errorf("Source must be %q or %q", ForEachSourcePrefix, ForEachSourceJSON)
+	}
+	if err := ValidateVariableName("Input variable", f.InputVariable); err != nil {
+		return err
+	}
+	if err := ValidateVariableName("Output variable", f.OutputVariable); err != nil {
+		return err
+	}
+	if strings.TrimSpace(f.IndexVariable) != "" {
+		return ValidateVariableName("Index variable", f.IndexVariable)
+	}
+	return nil
+}
+
+func (f *ForEachController) indexVariable() string {
+	if name := strings.TrimSpace(f.IndexVariable); name != "" {
+		return name
+	}
+	return f.OutputVariable + "_index"
+}
+
+func (f *ForEachController) Execute(ctx *core.Context) error {
+	items, err := f.items(ctx)
+	if err != nil {
+		return fmt.Errorf("ForEach Controller %q cannot read %q: %w", f.Name(), f.InputVariable, err)
+	}
+
+	indexVar := f.indexVariable()
+	for i, item := range items {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		ctx.SetVar(f.OutputVariable, item)
+		ctx.SetVar(indexVar, i+1)
+		if err := executeChildren(ctx, f.GetChildren()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *ForEachController) items(ctx *core.Context) ([]string, error) {
+	if f.Source == ForEachSourceJSON {
+		raw := ctx.GetVar(f.InputVariable)
+		if raw == nil {
+			return nil, nil
+		}
+		return jsonArrayItems(fmt.Sprintf("%v", raw))
+	}
+
+	items := make([]string, 0)
+	for i := 1; ; i++ {
+		val := ctx.GetVar(fmt.Sprintf("%s_%d", f.InputVariable, i))
+		if val == nil {
+			return items, nil
+		}
+		items = append(items, fmt.Sprintf("%v", val))
+	}
+}
+
+// --- Parallel Controller ---
+
+// ParallelController runs its children concurrently on behalf of the same thread,
+// the way a browser fetches several resources at once. Each child runs in a forked
+// context whose variable changes are merged back once all children have finished.
+type ParallelController struct {
+	core.BaseElement
+	MaxConcurrency int  // Maximum children running at once, 0 = all
+	ReportTiming   bool // Report the combined wall-clock time as a parent sample
+}
+
+func NewParallelController(name string, maxConcurrency int) *ParallelController {
+	return &ParallelController{
+		BaseElement:    core.NewBaseElement(name),
+		MaxConcurrency: maxConcurrency,
+	}
+}
+
+func (p *ParallelController) Clone() core.TestElement {
+	newP := *p
+	newP.BaseElement = core.NewBaseElement(p.Name())
+	return &newP
+}
+
+func (p *ParallelController) Validate() error {
+	return ValidateNonNegative("Max concurrency", p.MaxConcurrency)
+}
+
+func (p *ParallelController) Execute(ctx *core.Context) error {
+	children := executableChildren(p.GetChildren())
+	if len(children) == 0 {
+		return nil
+	}
+
+	var collector *transactionCollector
+	if p.ReportTiming {
+		outer, _ := ctx.GetVar("Reporter").(core.Runner)
+		collector = &transactionCollector{next: outer, forward: true}
+	}
+
+	start := time.Now()
+	err := p.run(ctx, children, collector)
+	end := time.Now()
+
+	if collector == nil || ctx.Err() != nil {
+		return err
+	}
+
+	samples, failed, bytesReceived := collector.summary()
+	if samples == 0 && err == nil {
+		return nil
+	}
+
+	result := &core.SampleResult{
+		SamplerName:   p.Name(),
+		StartTime:     start,
+		EndTime:       end,
+		Latency:       end.Sub(start),
+		Success:       failed == 0 && err == nil,
+		BytesReceived: bytesReceived,
+		Kind:          core.SampleTransaction,
+	}
+	switch {
+	case err != nil:
+		result.Error = err
+	case failed > 0:
+		result.Error = fmt.Errorf("%d of %d sample(s) failed", failed, samples)
+	}
+	if reportErr := reportSample(ctx, result); err == nil {
+		err = reportErr
+	}
+	return err
+}
+
+// run executes children concurrently on forked contexts, joins them back and returns
+// the first branch error. With collector set it is the thread's Reporter until the
+// branches have been joined.
+func (p *ParallelController) run(ctx *core.Context, children []core.TestElement, collector *transactionCollector) error {
+	if collector != nil {
+		defer swapStateVar(ctx, "Reporter", collector)()
+	}
+
+	limit := p.MaxConcurrency
+	if limit <= 0 || limit > len(children) {
+		limit = len(children)
+	}
+	slots := make(chan struct{}, limit)
+	branches := make([]*core.Context, len(children))
+	errs := make([]error, len(children))
+
+	var wg sync.WaitGroup
+	for i, child := range children {
+		branches[i] = ctx.Fork()
+		wg.Add(1)
+		go func(i int, exec core.Executable) {
+			defer wg.Done()
+			select {
+			case slots <- struct{}{}:
+			case <-ctx.Done():
+				errs[i] = ctx.Err()
+				return
+			}
+			defer func() { <-slots }()
+			errs[i] = exec.Execute(branches[i])
+		}(i, child.(core.Executable))
+	}
+	wg.Wait()
+
+	for _, branch := range branches {
+		ctx.Join(branch)
+	}
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// --- Throughput Controller ---
+
+const (
+	// ThroughputModePercent runs the children on Percent % of passes.
+	ThroughputModePercent = "Percent"
+	// ThroughputModeTotal runs the children on the first Executions passes only.
+	ThroughputModeTotal = "Total"
+)
+
+// ThroughputController limits how often its children run. Passes are counted per
+// thread by default, or across all threads of the group when Shared is set. Percent
+// mode spreads executions evenly instead of sampling randomly, so the mix is exact.
+type ThroughputController struct {
+	core.BaseElement
+	Mode       string
+	Percent    float64
+	Executions int
+	Shared     bool
+	shared     *atomic.Uint64
+}
+
+func NewThroughputController(name, mode string) *ThroughputController {
+	return &ThroughputController{
+		BaseElement: core.NewBaseElement(name),
+		Mode:        mode,
+		Percent:     100,
+		Executions:  1,
+		shared:      &atomic.Uint64{},
+	}
+}
+
+func (t *ThroughputController) Clone() core.TestElement {
+	newT := *t
+	newT.BaseElement = core.NewBaseElement(t.Name())
+	newT.shared = &atomic.Uint64{}
+	return &newT
+}
+
+func (t *ThroughputController) Validate() error {
+	switch t.Mode {
+	case ThroughputModePercent:
+		if math.IsNaN(t.Percent) || t.Percent < 0 || t.Percent > 100 {
+			return fmt.Errorf("Percent must be between 0 and 100")
+		}
+		return nil
+	case ThroughputModeTotal:
+		return ValidateNonNegative("Executions", t.Executions)
+	default:
+		return fmt.Errorf("Mode must be %q or %q", ThroughputModePercent, ThroughputModeTotal)
+	}
+}
+
+func (t *ThroughputController) Execute(ctx *core.Context) error {
+	var pass uint64
+	if t.Shared && t.shared != nil {
+		pass = t.shared.Add(1) - 1
+	} else {
+		key := "Throughput_" + t.ID()
+		next, _ := ctx.GetVar(key).(uint64)
+		pass = next
+		ctx.SetStateVar(key, next+1)
+	}
+
+	if !t.allows(pass) {
+		return nil
+	}
+	return executeChildren(ctx, t.GetChildren())
+}
+
+// allows reports whether the zero-based pass should run the children.
+func (t *ThroughputController) allows(pass uint64) bool {
+	if t.Mode == ThroughputModeTotal {
+		return t.Executions > 0 && pass < uint64(t.Executions)
+	}
+	ratio := t.Percent / 100
+	return math.Floor(float64(pass+1)*ratio) > math.Floor(float64(pass)*ratio)
+}
+
+// --- Runtime Controller ---
+
+// RuntimeController repeats its children until Duration has elapsed. The deadline
+// and cancellation are checked before every child, so a pass may stop part-way.
+type RuntimeController struct {
+	core.BaseElement
+	Duration time.Duration
+}
+
+func NewRuntimeController(name string, duration time.Duration) *RuntimeController {
+	return &RuntimeController{
+		BaseElement: core.NewBaseElement(name),
+		Duration:    duration,
+	}
+}
+
+func (r *RuntimeController) Clone() core.TestElement {
+	newR := *r
+	newR.BaseElement = core.NewBaseElement(r.Name())
+	return &newR
+}
+
+func (r *RuntimeController) Validate() error {
+	if r.Duration <= 0 {
+		return fmt.Errorf("Duration must be greater than 0 ms")
+	}
+	return nil
+}
+
+func (r *RuntimeController) Execute(ctx *core.Context) error {
+	children := executableChildren(r.GetChildren())
+	if r.Duration <= 0 || len(children) == 0 {
+		return nil
+	}
+
+	deadline := time.Now().Add(r.Duration)
+	for {
+		passStart := time.Now()
+		before := ctx.LastSample()
+		for _, child := range children {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if !time.Now().Before(deadline) {
+				return nil
 			}
-			if exec, ok := child.(core.Executable); ok {
-				if err := exec.Execute(ctx); err != nil {
-					return err
-				}
+			if err := child.(core.Executable).Execute(ctx); err != nil {
+				return err
 			}
 		}
+		// A pass without samples, e.g. with every child a false IfController, would
+		// otherwise spin until the deadline.
+		if ctx.LastSample() == before {
+			wait := min(time.Until(passStart.Add(runtimeIdlePass)), time.Until(deadline))
+			if !waitForDuration(ctx, wait) {
+				return ctx.Err()
+			}
+		}
+	}
+}
+
+// runtimeIdlePass is the shortest a RuntimeController pass that produced no sample lasts.
+const runtimeIdlePass = 10 * time.Millisecond
+
+// --- Module Controller ---
+
+// ModuleController executes a fragment defined elsewhere: an element of another plan
+// in the same project, or of an external plan/project file. The reference is inlined
+// by ResolveModules (see modules.go) before the plan is sent to an agent, so at run time the controller
+// simply executes the resolved fragment held as its children.
+type ModuleController struct {
+	core.BaseElement
+	PlanName  string // Plan in the project (or in File when it is a project file)
+	ElementID string // Target element, empty for the whole plan
+	File      string // External plan or project file, relative to the project directory
+	Resolved  bool   // Set by ResolveModules once the fragment is inlined
+}
+
+func NewModuleController(name, planName, elementID string) *ModuleController {
+	return &ModuleController{
+		BaseElement: core.NewBaseElement(name),
+		PlanName:    planName,
+		ElementID:   elementID,
+	}
+}
+
+func (m *ModuleController) Clone() core.TestElement {
+	newM := *m
+	newM.BaseElement = core.NewBaseElement(m.Name())
+	newM.Resolved = false
+	return &newM
+}
+
+func (m *ModuleController) Validate() error {
+	if strings.TrimSpace(m.PlanName) == "" && strings.TrimSpace(m.File) == "" {
+		return fmt.Errorf("a plan or an external file is required")
 	}
 	return nil
 }
 
+func (m *ModuleController) Execute(ctx *core.Context) error {
+	return executeChildren(ctx, m.GetChildren())
+}
+
+// --- Critical Section Controller ---
+
+// CriticalSectionController runs its children while holding a lock shared by name
+// across all threads of the run, so only one thread at a time is inside any section
+// using that name. The time spent waiting is reported as "<name> lock wait".
+type CriticalSectionController struct {
+	core.BaseElement
+	LockName       string
+	ReportWaitTime bool
+}
+
+func NewCriticalSectionController(name, lockName string) *CriticalSectionController {
+	return &CriticalSectionController{
+		BaseElement:    core.NewBaseElement(name),
+		LockName:       lockName,
+		ReportWaitTime: true,
+	}
+}
+
+func (c *CriticalSectionController) Clone() core.TestElement {
+	newC := *c
+	newC.BaseElement = core.NewBaseElement(c.Name())
+	return &newC
+}
+
+func (c *CriticalSectionController) Validate() error {
+	if strings.TrimSpace(c.LockName) == "" {
+		return fmt.Errorf("Lock name is required")
+	}
+	return nil
+}
+
+func (c *CriticalSectionController) Execute(ctx *core.Context) error {
+	lock := namedLock(ctx, strings.TrimSpace(c.LockName))
+
+	start := time.Now()
+	select {
+	case lock <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	defer func() { <-lock }()
+	acquired := time.Now()
+
+	if c.ReportWaitTime {
+		if reporter, ok := ctx.GetVar("Reporter").(core.Runner); ok {
+			reporter.ReportResult(&core.SampleResult{
+				SamplerName: c.Name() + " lock wait",
+				StartTime:   start,
+				EndTime:     acquired,
+				Latency:     acquired.Sub(start),
+				Success:     true,
+				Kind:        core.SampleLockWait,
+			})
+		}
+	}
+
+	return executeChildren(ctx, c.GetChildren())
+}
+
+// standaloneRunStore backs named locks for controllers executed outside a thread group.
+var standaloneRunStore = core.NewRunStore()
+
+// namedLock returns the run-wide lock for name as a one-slot channel, so waiting for
+// it can be abandoned on cancellation.
+func namedLock(ctx *core.Context, name string) chan struct{} {
+	store := core.RunStoreFromContext(ctx)
+	if store == nil {
+		store = standaloneRunStore
+	}
+	return store.GetOrCreate("lock:"+name, func() interface{} {
+		return make(chan struct{}, 1)
+	}).(chan struct{})
+}
+
+// executableChildren returns the enabled children that can be executed.
+func executableChildren(children []core.TestElement) []core.TestElement {
+	out := make([]core.TestElement, 0, len(children))
+	for _, child := range children {
+		if !child.Enabled() {
+			continue
+		}
+		if _, ok := child.(core.Executable); ok {
+			out = append(out, child)
+		}
+	}
+	return out
+}
+
 // --- Pause Controller ---
 
 type PauseController struct {
@@ -178,10 +1353,5 @@ func (p *PauseController) Validate() error {
 }
 
 func (p *PauseController) Execute(ctx *core.Context) error {
-	select {
-	case <-time.After(p.Duration):
-		return nil
-	case <-ctx.Done():
-		return ctx.Err()
-	}
+	return pause(ctx, p.Duration)
 }
@@ -0,0 +1,495 @@
+package elements
+
+import (
+	"fmt"
+	"math"
+	"perfolizer/pkg/core"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// Condition expressions are a small boolean language evaluated against the
+// thread context, for example:
+//
+//	${status} == "200" && ${retries} < 3
+//	${body} =~ "token=\w+" || !lastSampleOk
+//
+// Operands are ${var} references, quoted strings, numbers, true/false and the
+// last-sample identifiers lastSampleOk, lastSampleCode and lastSampleMs. Before the
+// thread's first sample lastSampleOk is true, so a condition on it does not skip the
+// first request of a thread.
+// Comparisons are numeric when both sides parse as numbers, otherwise string based.
+
+// Expression is a compiled condition expression.
+type Expression struct {
+	source string
+	root   exprNode
+}
+
+// CompileExpression parses a condition expression.
+func CompileExpression(source string) (*Expression, error) {
+	p := &exprParser{src: source}
+	if err := p.tokenize(); err != nil {
+		return nil, err
+	}
+	if len(p.tokens) == 0 {
+		return nil, fmt.Errorf("expression is empty")
+	}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected %q at position %d", p.tokens[p.pos].text, p.tokens[p.pos].offset+1)
+	}
+	return &Expression{source: source, root: root}, nil
+}
+
+func (e *Expression) String() string {
+	return e.source
+}
+
+// Eval evaluates the expression against the thread context.
+func (e *Expression) Eval(ctx *core.Context) (bool, error) {
+	v, err := e.root.eval(ctx)
+	if err != nil {
+		return false, err
+	}
+	return v.truthy(), nil
+}
+
+// ValidateExpression reports whether source is a well-formed condition expression.
+func ValidateExpression(field, source string) error {
+	if strings.TrimSpace(source) == "" {
+		return fmt.Errorf("%s is required", field)
+	}
+	if _, err := CompileExpression(source); err != nil {
+		return fmt.Errorf("%s is invalid: %v", field, err)
+	}
+	return nil
+}
+
+var compiledExpressions sync.Map // source -> *Expression
+
+// compileExpressionCached compiles source once and reuses the result; compiled
+// expressions are immutable, so they are safe to share between threads.
+func compileExpressionCached(source string) (*Expression, error) {
+	if cached, ok := compiledExpressions.Load(source); ok {
+		return cached.(*Expression), nil
+	}
+	expr, err := CompileExpression(source)
+	if err != nil {
+		return nil, err
+	}
+	compiledExpressions.Store(source, expr)
+	return expr, nil
+}
+
+// --- values ---
+
+type exprValue struct {
+	str    string
+	num    float64
+	isNum  bool
+	isBool bool
+	b      bool
+}
+
+func stringValue(s string) exprValue {
+	v := exprValue{str: s}
+	if f, err := strconv.ParseFloat(strings.TrimSpace(s), 64); err == nil && !math.IsNaN(f) {
+		v.num = f
+		v.isNum = true
+	}
+	return v
+}
+
+func boolValue(b bool) exprValue {
+	return exprValue{str: strconv.FormatBool(b), isBool: true, b: b}
+}
+
+func (v exprValue) truthy() bool {
+	if v.isBool {
+		return v.b
+	}
+	if v.isNum {
+		return v.num != 0
+	}
+	return strings.EqualFold(strings.TrimSpace(v.str), "true")
+}
+
+// --- AST ---
+
+type exprNode interface {
+	eval(ctx *core.Context) (exprValue, error)
+}
+
+type literalNode struct {
+	value exprValue
+}
+
+func (n literalNode) eval(*core.Context) (exprValue, error) {
+	return n.value, nil
+}
+
+type varNode struct {
+	name string
+}
+
+func (n varNode) eval(ctx *core.Context) (exprValue, error) {
+	val := ctx.GetVar(n.name)
+	if val == nil {
+		return stringValue(""), nil
+	}
+	if b, ok := val.(bool); ok {
+		return boolValue(b), nil
+	}
+	return stringValue(fmt.Sprintf("%v", val)), nil
+}
+
+type identNode struct {
+	name string
+}
+
+func (n identNode) eval(ctx *core.Context) (exprValue, error) {
+	last := ctx.LastSample()
+	switch n.name {
+	case "lastSampleOk":
+		return boolValue(last == nil || last.Success && last.Error == nil), nil
+	case "lastSampleCode":
+		if last == nil {
+			return stringValue(""), nil
+		}
+		code, _, _ := strings.Cut(last.ResponseCode, " ")
+		return stringValue(code), nil
+	case "lastSampleMs":
+		if last == nil {
+			return stringValue("0"), nil
+		}
+		return stringValue(strconv.FormatInt(last.Duration().Milliseconds(), 10)), nil
+	}
+	return exprValue{}, fmt.Errorf("unknown identifier %q", n.name)
+}
+
+type notNode struct {
+	operand exprNode
+}
+
+func (n notNode) eval(ctx *core.Context) (exprValue, error) {
+	v, err := n.operand.eval(ctx)
+	if err != nil {
+		return exprValue{}, err
+	}
+	return boolValue(!v.truthy()), nil
+}
+
+type logicalNode struct {
+	and         bool
+	left, right exprNode
+}
+
+func (n logicalNode) eval(ctx *core.Context) (exprValue, error) {
+	l, err := n.left.eval(ctx)
+	if err != nil {
+		return exprValue{}, err
+	}
+	if n.and && !l.truthy() {
+		return boolValue(false), nil
+	}
+	if !n.and && l.truthy() {
+		return boolValue(true), nil
+	}
+	r, err := n.right.eval(ctx)
+	if err != nil {
+		return exprValue{}, err
+	}
+	return boolValue(r.truthy()), nil
+}
+
+type compareNode struct {
+	op          string
+	left, right exprNode
+	re          *regexp.Regexp // precompiled when the pattern is a literal
+}
+
+func (n compareNode) eval(ctx *core.Context) (exprValue, error) {
+	l, err := n.left.eval(ctx)
+	if err != nil {
+		return exprValue{}, err
+	}
+	r, err := n.right.eval(ctx)
+	if err != nil {
+		return exprValue{}, err
+	}
+
+	switch n.op {
+	case "=~", "!~":
+		re := n.re
+		if re == nil {
+			re, err = regexp.Compile(r.str)
+			if err != nil {
+				return exprValue{}, fmt.Errorf("invalid regex %q: %v", r.str, err)
+			}
+		}
+		matched := re.MatchString(l.str)
+		return boolValue(matched == (n.op == "=~")), nil
+	}
+
+	var cmp int
+	if l.isNum && r.isNum {
+		switch {
+		case l.num < r.num:
+			cmp = -1
+		case l.num > r.num:
+			cmp = 1
+		}
+	} else {
+		cmp = strings.Compare(l.str, r.str)
+	}
+
+	switch n.op {
+	case "==":
+		return boolValue(cmp == 0), nil
+	case "!=":
+		return boolValue(cmp != 0), nil
+	case "<":
+		return boolValue(cmp < 0), nil
+	case "<=":
+		return boolValue(cmp <= 0), nil
+	case ">":
+		return boolValue(cmp > 0), nil
+	case ">=":
+		return boolValue(cmp >= 0), nil
+	}
+	return exprValue{}, fmt.Errorf("unknown operator %q", n.op)
+}
+
+// --- parser ---
+
+type exprTokenKind int
+
+const (
+	tokOp exprTokenKind = iota
+	tokString
+	tokNumber
+	tokVar
+	tokIdent
+)
+
+type exprToken struct {
+	kind   exprTokenKind
+	text   string
+	offset int
+}
+
+type exprParser struct {
+	src    string
+	tokens []exprToken
+	pos    int
+}
+
+var exprOperators = []string{"&&", "||", "==", "!=", "<=", ">=", "=~", "!~", "<", ">", "!", "(", ")"}
+
+func (p *exprParser) tokenize() error {
+	s := p.src
+	i := 0
+	for i < len(s) {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '"' || c == '\'':
+			var b strings.Builder
+			j := i + 1
+			closed := false
+			for j < len(s) {
+				if s[j] == '\\' && j+1 < len(s) && (s[j+1] == c || s[j+1] == '\\') {
+					b.WriteByte(s[j+1])
+					j += 2
+					continue
+				}
+				if s[j] == c {
+					closed = true
+					break
+				}
+				b.WriteByte(s[j])
+				j++
+			}
+			if !closed {
+				return fmt.Errorf("unterminated string at position %d", i+1)
+			}
+			p.tokens = append(p.tokens, exprToken{kind: tokString, text: b.String(), offset: i})
+			i = j + 1
+		case c == '$' && i+1 < len(s) && s[i+1] == '{':
+			end := strings.IndexByte(s[i:], '}')
+			if end < 0 {
+				return fmt.Errorf("unterminated variable reference at position %d", i+1)
+			}
+			name := strings.TrimSpace(s[i+2 : i+end])
+			if name == "" {
+				return fmt.Errorf("empty variable reference at position %d", i+1)
+			}
+			p.tokens = append(p.tokens, exprToken{kind: tokVar, text: name, offset: i})
+			i += end + 1
+		case c >= '0' && c <= '9' || (c == '-' || c == '.') && i+1 < len(s) && s[i+1] >= '0' && s[i+1] <= '9':
+			j := i + 1
+			for j < len(s) && (s[j] >= '0' && s[j] <= '9' || s[j] == '.') {
+				j++
+			}
+			if _, err := strconv.ParseFloat(s[i:j], 64); err != nil {
+				return fmt.Errorf("invalid number %q at position %d", s[i:j], i+1)
+			}
+			p.tokens = append(p.tokens, exprToken{kind: tokNumber, text: s[i:j], offset: i})
+			i = j
+		case unicode.IsLetter(rune(c)) || c == '_':
+			j := i + 1
+			for j < len(s) && (unicode.IsLetter(rune(s[j])) || unicode.IsDigit(rune(s[j])) || s[j] == '_') {
+				j++
+			}
+			p.tokens = append(p.tokens, exprToken{kind: tokIdent, text: s[i:j], offset: i})
+			i = j
+		default:
+			matched := false
+			for _, op := range exprOperators {
+				if strings.HasPrefix(s[i:], op) {
+					p.tokens = append(p.tokens, exprToken{kind: tokOp, text: op, offset: i})
+					i += len(op)
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				return fmt.Errorf("unexpected character %q at position %d", c, i+1)
+			}
+		}
+	}
+	return nil
+}
+
+func (p *exprParser) peekOp(ops ...string) (string, bool) {
+	if p.pos >= len(p.tokens) || p.tokens[p.pos].kind != tokOp {
+		return "", false
+	}
+	for _, op := range ops {
+		if p.tokens[p.pos].text == op {
+			return op, true
+		}
+	}
+	return "", false
+}
+
+func (p *exprParser) parseOr() (exprNode, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if _, ok := p.peekOp("||"); !ok {
+			return left, nil
+		}
+		p.pos++
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = logicalNode{and: false, left: left, right: right}
+	}
+}
+
+func (p *exprParser) parseAnd() (exprNode, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if _, ok := p.peekOp("&&"); !ok {
+			return left, nil
+		}
+		p.pos++
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = logicalNode{and: true, left: left, right: right}
+	}
+}
+
+func (p *exprParser) parseNot() (exprNode, error) {
+	if _, ok := p.peekOp("!"); ok {
+		p.pos++
+		operand, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return notNode{operand: operand}, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *exprParser) parseComparison() (exprNode, error) {
+	left, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	op, ok := p.peekOp("==", "!=", "<=", ">=", "<", ">", "=~", "!~")
+	if !ok {
+		return left, nil
+	}
+	p.pos++
+	right, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	node := compareNode{op: op, left: left, right: right}
+	if op == "=~" || op == "!~" {
+		if lit, ok := right.(literalNode); ok {
+			re, err := regexp.Compile(lit.value.str)
+			if err != nil {
+				return nil, fmt.Errorf("invalid regex %q: %v", lit.value.str, err)
+			}
+			node.re = re
+		}
+	}
+	return node, nil
+}
+
+func (p *exprParser) parsePrimary() (exprNode, error) {
+	if p.pos >= len(p.tokens) {
+		return nil, fmt.Errorf("unexpected end of expression")
+	}
+	tok := p.tokens[p.pos]
+	p.pos++
+
+	switch tok.kind {
+	case tokString, tokNumber:
+		return literalNode{value: stringValue(tok.text)}, nil
+	case tokVar:
+		return varNode{name: tok.text}, nil
+	case tokIdent:
+		switch tok.text {
+		case "true":
+			return literalNode{value: boolValue(true)}, nil
+		case "false":
+			return literalNode{value: boolValue(false)}, nil
+		case "lastSampleOk", "lastSampleCode", "lastSampleMs":
+			return identNode{name: tok.text}, nil
+		}
+		return nil, fmt.Errorf("unknown identifier %q at position %d", tok.text, tok.offset+1)
+	case tokOp:
+		if tok.text == "(" {
+			inner, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			if _, ok := p.peekOp(")"); !ok {
+				return nil, fmt.Errorf("missing closing parenthesis for position %d", tok.offset+1)
+			}
+			p.pos++
+			return inner, nil
+		}
+	}
+	return nil, fmt.Errorf("unexpected %q at position %d", tok.text, tok.offset+1)
+}
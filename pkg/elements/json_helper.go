@@ -7,6 +7,21 @@ import (
 	"strings"
 )
 
+// jsonArrayItems decodes a JSON array and returns each element in the same string
+// form that ExtractJSONPathSimple uses for scalars and nested values.
+func jsonArrayItems(jsonStr string) ([]string, error) {
+	var arr []interface{}
+	if err := json.Unmarshal([]byte(jsonStr), &arr); err != nil {
+		return nil, err
+	}
+
+	items := make([]string, 0, len(arr))
+	for _, v := range arr {
+		items = append(items, jsonValueString(v))
+	}
+	return items, nil
+}
+
 // ExtractJSONPathSimple extracts a value from JSON using a simple dot notation path
 // Examples: "user.name", "data.items.0.id", "response.token"
 func ExtractJSONPathSimple(jsonStr, path string) string {
@@ -44,8 +59,11 @@ func ExtractJSONPathSimple(jsonStr, path string) string {
 		}
 	}
 
-	// Convert result to string
-	switch v := current.(type) {
+	return jsonValueString(current)
+}
+
+func jsonValueString(value interface{}) string {
+	switch v := value.(type) {
 	case string:
 		return v
 	case float64:
@@ -0,0 +1,269 @@
+package elements
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"perfolizer/pkg/core"
+	"strings"
+)
+
+// ErrModuleCycle reports a ModuleController that, directly or through the modules
+// it references, references itself.
+var ErrModuleCycle = errors.New("reference cycle")
+
+// reference identifies the module target for cycle detection and error messages.
+func (m *ModuleController) reference() string {
+	var b strings.Builder
+	if file := strings.TrimSpace(m.File); file != "" {
+		b.WriteString(file)
+		b.WriteString(":")
+	}
+	b.WriteString(strings.TrimSpace(m.PlanName))
+	if id := strings.TrimSpace(m.ElementID); id != "" {
+		b.WriteString("#")
+		b.WriteString(id)
+	}
+	return b.String()
+}
+
+// ResolveModules returns a self-contained copy of root in which every enabled
+// ModuleController holds a copy of the fragment it references. Plan names are looked
+// up in project and relative file paths are resolved against baseDir. Reference
+// cycles are reported as a *core.ValidationError. root itself is not modified.
+func ResolveModules(root core.TestElement, project *core.Project, baseDir string) (core.TestElement, error) {
+	resolved, err := copyElementTree(root)
+	if err != nil {
+		return nil, err
+	}
+	r := &moduleResolver{project: project, baseDir: baseDir, files: make(map[string]*core.Project)}
+	if err := r.resolveTree(resolved, nil); err != nil {
+		return nil, &core.ValidationError{Err: err}
+	}
+	return resolved, nil
+}
+
+// ValidateModuleReferences checks that every enabled ModuleController in root
+// references an existing fragment without a cycle, the same checks ResolveModules
+// makes, for callers that validate a plan without running it. Problems are reported
+// as a *core.ValidationError; cycles also match ErrModuleCycle.
+func ValidateModuleReferences(root core.TestElement, project *core.Project, baseDir string) error {
+	_, err := ResolveModules(root, project, baseDir)
+	return err
+}
+
+type moduleResolver struct {
+	project *core.Project
+	baseDir string
+	files   map[string]*core.Project
+}
+
+func (r *moduleResolver) resolveTree(el core.TestElement, chain []string) error {
+	if module, ok := el.(*ModuleController); ok && module.Enabled() {
+		return r.resolveModule(module, chain)
+	}
+	for _, child := range el.GetChildren() {
+		if !child.Enabled() {
+			continue
+		}
+		if err := r.resolveTree(child, chain); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *moduleResolver) resolveModule(module *ModuleController, chain []string) error {
+	describe := fmt.Sprintf("Module Controller %q", module.Name())
+	if err := module.Validate(); err != nil {
+		return fmt.Errorf("%s: %w", describe, err)
+	}
+
+	ref := module.reference()
+	for i, seen := range chain {
+		if seen == ref {
+			cycle := append(append([]string{}, chain[i:]...), ref)
+			return fmt.Errorf("%s: %w %s", describe, ErrModuleCycle, strings.Join(cycle, " -> "))
+		}
+	}
+
+	target, err := r.lookup(module)
+	if err != nil {
+		return fmt.Errorf("%s: %w", describe, err)
+	}
+
+	fragment, err := moduleFragment(target)
+	if err != nil {
+		return fmt.Errorf("%s: %w", describe, err)
+	}
+
+	for _, child := range module.GetChildren() {
+		module.RemoveChild(child.ID())
+	}
+	next := append(append([]string{}, chain...), ref)
+	for _, el := range fragment {
+		if err := r.resolveTree(el, next); err != nil {
+			return err
+		}
+		module.AddChild(el)
+	}
+	module.Resolved = true
+	return nil
+}
+
+// ValidateResolvedModules reports the first enabled ModuleController in root that
+// ResolveModules has not inlined. An agent cannot look references up, so it runs
+// only plans whose modules were resolved, with their cycles checked, beforehand.
+func ValidateResolvedModules(root core.TestElement) error {
+	if module, ok := root.(*ModuleController); ok && module.Enabled() && !module.Resolved {
+		return &core.ValidationError{Err: fmt.Errorf("Module Controller %q: reference %s is not resolved", module.Name(), module.reference())}
+	}
+	for _, child := range root.GetChildren() {
+		if !child.Enabled() {
+			continue
+		}
+		if err := ValidateResolvedModules(child); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *moduleResolver) lookup(module *ModuleController) (core.TestElement, error) {
+	project := r.project
+	if file := strings.TrimSpace(module.File); file != "" {
+		loaded, err := r.loadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		project = loaded
+	}
+	if project == nil || project.PlanCount() == 0 {
+		return nil, fmt.Errorf("no plans available to resolve %q", module.reference())
+	}
+
+	var root core.TestElement
+	planName := strings.TrimSpace(module.PlanName)
+	switch {
+	case planName != "":
+		for _, plan := range project.Plans {
+			if plan.Name == planName {
+				root = plan.Root
+				break
+			}
+		}
+		if root == nil {
+			return nil, fmt.Errorf("plan %q not found", planName)
+		}
+	case project.PlanCount() == 1:
+		root = project.Plans[0].Root
+	default:
+		return nil, fmt.Errorf("plan name is required for a file with %d plans", project.PlanCount())
+	}
+
+	elementID := strings.TrimSpace(module.ElementID)
+	if elementID == "" {
+		return root, nil
+	}
+	target := findElementByID(root, elementID)
+	if target == nil {
+		return nil, fmt.Errorf("element %q not found in plan %q", elementID, planName)
+	}
+	return target, nil
+}
+
+func (r *moduleResolver) loadFile(file string) (*core.Project, error) {
+	path := file
+	if !filepath.IsAbs(path) && r.baseDir != "" {
+		path = filepath.Join(r.baseDir, path)
+	}
+	path = filepath.Clean(path)
+	if project, ok := r.files[path]; ok {
+		return project, nil
+	}
+
+	project, err := core.LoadProject(path)
+	if err != nil {
+		// Legacy: single test plan JSON
+		plan, planErr := core.LoadTestPlan(path)
+		if planErr != nil {
+			return nil, fmt.Errorf("load %q: %w", file, err)
+		}
+		project = core.NewProject(filepath.Base(path))
+		project.AddPlan(plan.Name(), plan)
+	}
+	r.files[path] = project
+	return project, nil
+}
+
+// moduleFragment copies the executable part of target. Executable targets are copied
+// as a whole; for a plan root or thread group the enabled scenario steps are copied,
+// flattening any thread groups directly under a plan root.
+func moduleFragment(target core.TestElement) ([]core.TestElement, error) {
+	var sources []core.TestElement
+	if _, ok := target.(core.Executable); ok {
+		sources = []core.TestElement{target}
+	} else {
+		for _, child := range target.GetChildren() {
+			if !child.Enabled() {
+				continue
+			}
+			if _, ok := child.(core.ThreadGroup); ok {
+				sources = append(sources, executableChildren(child.GetChildren())...)
+			} else if _, ok := child.(core.Executable); ok {
+				sources = append(sources, child)
+			}
+		}
+	}
+
+	fragment := make([]core.TestElement, 0, len(sources))
+	for _, source := range sources {
+		copied, err := copyElementTree(source)
+		if err != nil {
+			return nil, err
+		}
+		renewIDs(copied)
+		fragment = append(fragment, copied)
+	}
+	return fragment, nil
+}
+
+// renewIDs gives every element of an inlined copy a fresh ID, so per-thread state
+// keyed by element ID is not shared with the original or with other copies of the
+// same fragment. Weights keyed by child ID follow their children.
+func renewIDs(el core.TestElement) {
+	el.SetID(core.GenerateID())
+	renamed := make(map[string]string, len(el.GetChildren()))
+	for _, child := range el.GetChildren() {
+		oldID := child.ID()
+		renewIDs(child)
+		renamed[oldID] = child.ID()
+	}
+	if w, ok := el.(*WeightedSwitchController); ok {
+		weights := make(map[string]float64, len(w.Weights))
+		for id, weight := range w.Weights {
+			if newID, ok := renamed[id]; ok {
+				id = newID
+			}
+			weights[id] = weight
+		}
+		w.Weights = weights
+	}
+}
+
+// copyElementTree deep-copies an element tree through its persisted form, keeping IDs.
+func copyElementTree(el core.TestElement) (core.TestElement, error) {
+	return core.DTOToTestElement(core.TestElementToDTO(el))
+}
+
+func findElementByID(el core.TestElement, id string) core.TestElement {
+	if el.ID() == id {
+		return el
+	}
+	for _, child := range el.GetChildren() {
+		if found := findElementByID(child, id); found != nil {
+			return found
+		}
+	}
+	return nil
+}
@@ -3,6 +3,7 @@ package elements
 import (
 	"bytes"
 	"context"
+	"fmt"
 	"io"
 	"log"
 	"math"
@@ -105,6 +106,12 @@ func (h *HttpSampler) Execute(ctx *core.Context) error {
 		}
 	}
 
+	// Timers and other hooks scoped to this sampler run after rate limiting so that
+	// skipped RPS slots are not delayed.
+	if err := runSampleHooks(ctx, h); err != nil {
+		return err
+	}
+
 	// 1. Prepare Request
 	// Substitute variables
 	url := ctx.Substitute(h.Url)
@@ -178,7 +185,7 @@ func (h *HttpSampler) Execute(ctx *core.Context) error {
 							// Config Error or User mistake: Expression empty.
 							log.Printf("Debug: Param %q has empty expression, using Value as default", varName)
 							if param.Value != "" {
-								ctx.SetVar(varName, param.Value)
+								ctx.SetScopedVar(param.Scope, varName, param.Value)
 							}
 							continue
 						}
@@ -188,15 +195,15 @@ func (h *HttpSampler) Execute(ctx *core.Context) error {
 							matches := re.FindStringSubmatch(respBody)
 							if len(matches) > 1 {
 								log.Printf("Debug: Extracted %s=%q", varName, matches[1])
-								ctx.SetVar(varName, matches[1])
+								ctx.SetScopedVar(param.Scope, varName, matches[1])
 							} else if len(matches) == 1 {
 								log.Printf("Debug: Extracted %s=%q", varName, matches[0])
-								ctx.SetVar(varName, matches[0])
+								ctx.SetScopedVar(param.Scope, varName, matches[0])
 							} else {
 								// No match, use default/fallback
 								log.Printf("Debug: No match for %s, using default=%q", varName, param.Value)
 								if param.Value != "" {
-									ctx.SetVar(varName, param.Value)
+									ctx.SetScopedVar(param.Scope, varName, param.Value)
 								}
 							}
 						} else {
@@ -206,7 +213,7 @@ func (h *HttpSampler) Execute(ctx *core.Context) error {
 						if param.Expression == "" {
 							log.Printf("Debug: Param %q has empty JSON path, using Value as default", varName)
 							if param.Value != "" {
-								ctx.SetVar(varName, param.Value)
+								ctx.SetScopedVar(param.Scope, varName, param.Value)
 							}
 							continue
 						}
@@ -215,11 +222,11 @@ func (h *HttpSampler) Execute(ctx *core.Context) error {
 						extractedValue := ExtractJSONPathSimple(respBody, param.Expression)
 						if extractedValue != "" {
 							log.Printf("Debug: Extracted %s=%q from JSON path %q", varName, extractedValue, param.Expression)
-							ctx.SetVar(varName, extractedValue)
+							ctx.SetScopedVar(param.Scope, varName, extractedValue)
 						} else {
 							log.Printf("Debug: No value found for JSON path %q, using default=%q", param.Expression, param.Value)
 							if param.Value != "" {
-								ctx.SetVar(varName, param.Value)
+								ctx.SetScopedVar(param.Scope, varName, param.Value)
 							}
 						}
 					}
@@ -230,12 +237,26 @@ func (h *HttpSampler) Execute(ctx *core.Context) error {
 		}
 	}
 
-	// Used mechanism to report up?
+	return reportSample(ctx, result)
+}
+
+// reportSample records result as the thread's last sample and forwards it to
+// the Reporter injected by the thread group. It returns ErrSampleFailed for a
+// failed sample when the thread group's on-sample-error policy must interrupt
+// the thread, so controllers unwind back to the thread group loop.
+func reportSample(ctx *core.Context, result *core.SampleResult) error {
+	ctx.SetLastSample(result)
 	if reporter, ok := ctx.GetVar("Reporter").(core.Runner); ok {
 		reporter.ReportResult(result)
 	}
 
-	return nil
+	if result.Success && result.Error == nil {
+		return nil
+	}
+	if policy, _ := ctx.GetVar("OnSampleError").(string); policy == "" || policy == OnSampleErrorContinue {
+		return nil
+	}
+	return fmt.Errorf("%w: %s", ErrSampleFailed, result.SamplerName)
 }
 
 type limiterStore struct {
@@ -272,7 +293,7 @@ func getOrCreateLimiter(ctx *core.Context, key string, targetRPS float64) *rate.
 	}
 
 	limiter := rate.NewLimiter(rate.Limit(targetRPS), 1)
-	ctx.SetVar(key, limiter)
+	ctx.SetStateVar(key, limiter)
 	return limiter
 }
 
@@ -0,0 +1,55 @@
+package elements
+
+import "perfolizer/pkg/core"
+
+// SampleHook is implemented by elements that are not executed in tree order but act
+// before every sampler in their scope, such as timers. An element's scope is its
+// parent's subtree; when the parent is a sampler, only that sampler.
+type SampleHook interface {
+	core.TestElement
+	BeforeSample(ctx *core.Context) error
+}
+
+// sampleHooks maps each sampler of a thread group to the hooks in its scope,
+// outermost first. It is built once per thread group start and only read afterwards.
+type sampleHooks map[core.TestElement][]SampleHook
+
+func buildSampleHooks(root core.TestElement) sampleHooks {
+	hooks := make(sampleHooks)
+	collectSampleHooks(root, nil, hooks)
+	return hooks
+}
+
+func collectSampleHooks(el core.TestElement, inherited []SampleHook, hooks sampleHooks) {
+	scoped := inherited
+	for _, child := range el.GetChildren() {
+		if hook, ok := child.(SampleHook); ok && child.Enabled() {
+			scoped = append(scoped[:len(scoped):len(scoped)], hook)
+		}
+	}
+
+	if _, ok := el.(*HttpSampler); ok && len(scoped) > 0 {
+		hooks[el] = scoped
+	}
+
+	for _, child := range el.GetChildren() {
+		if !child.Enabled() {
+			continue
+		}
+		if _, ok := child.(SampleHook); ok {
+			continue
+		}
+		collectSampleHooks(child, scoped, hooks)
+	}
+}
+
+// runSampleHooks applies the hooks in scope of sampler, stopping at the first error.
+func runSampleHooks(ctx *core.Context, sampler core.TestElement) error {
+	hooks, _ := ctx.GetVar("SampleHooks").(sampleHooks)
+	for _, hook := range hooks[sampler] {
+		if err := hook.BeforeSample(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -2,7 +2,10 @@ package elements
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log"
+	"math"
 	"perfolizer/pkg/core"
 	"runtime"
 	"sync"
@@ -12,44 +15,150 @@ import (
 const (
 	defaultThreadGroupHTTPRequestTimeout = 5 * time.Second
 	defaultThreadGroupHTTPKeepAlive      = true
+	defaultTeardownTimeout               = time.Minute
 )
 
+// On-sample-error policies decide what a thread does after a failed sample or an
+// error returned by one of its elements.
+const (
+	OnSampleErrorContinue           = "Continue"
+	OnSampleErrorStartNextIteration = "StartNextIteration"
+	OnSampleErrorStopThread         = "StopThread"
+	OnSampleErrorStopTest           = "StopTest"
+)
+
+// OnSampleErrorPolicies lists the supported policies in display order.
+var OnSampleErrorPolicies = []string{
+	OnSampleErrorContinue,
+	OnSampleErrorStartNextIteration,
+	OnSampleErrorStopThread,
+	OnSampleErrorStopTest,
+}
+
+// ErrSampleFailed is returned up the element tree when a sample fails and the
+// thread group's policy is anything other than OnSampleErrorContinue.
+var ErrSampleFailed = errors.New("sample failed")
+
+// ErrIterationDropped marks the samples an ArrivalRateThreadGroup reports for arrivals
+// that found every virtual user busy.
+var ErrIterationDropped = errors.New("iteration dropped: no free virtual user")
+
 func init() {
 	core.RegisterFactory("SimpleThreadGroup", func(name string, props map[string]interface{}) core.TestElement {
-		tg := &SimpleThreadGroup{
+		return newSimpleThreadGroupFromProps(name, props)
+	})
+	core.RegisterFactory("SetupThreadGroup", func(name string, props map[string]interface{}) core.TestElement {
+		return &SetupThreadGroup{SimpleThreadGroup: *newSimpleThreadGroupFromProps(name, props)}
+	})
+	core.RegisterFactory("TeardownThreadGroup", func(name string, props map[string]interface{}) core.TestElement {
+		return &TeardownThreadGroup{
+			SimpleThreadGroup: *newSimpleThreadGroupFromProps(name, props),
+			Timeout:           time.Duration(core.GetInt(props, "TimeoutMS", int(defaultTeardownTimeout/time.Millisecond))) * time.Millisecond,
+		}
+	})
+	core.RegisterFactory("RPSThreadGroup", func(name string, props map[string]interface{}) core.TestElement {
+		tg := &RPSThreadGroup{
+			BaseElement:        core.NewBaseElement(name),
+			Users:              core.GetInt(props, "Users", 10),
+			RPS:                core.GetFloat(props, "RPS", 10.0),
+			ProfileBlocks:      parseRPSProfileBlocks(props),
+			GracefulShutdown:   time.Duration(core.GetInt(props, "GracefulShutdownMS", 0)) * time.Millisecond,
+			HTTPRequestTimeout: time.Duration(core.GetInt(props, "HTTPRequestTimeoutMS", int(defaultThreadGroupHTTPRequestTimeout/time.Millisecond))) * time.Millisecond,
+			HTTPKeepAlive:      core.GetBool(props, "HTTPKeepAlive", defaultThreadGroupHTTPKeepAlive),
+			OnSampleError:      core.GetString(props, "OnSampleError", OnSampleErrorContinue),
+			StartDelay:         time.Duration(core.GetInt(props, "StartDelayMS", 0)) * time.Millisecond,
+			Duration:           time.Duration(core.GetInt(props, "RunDurationMS", 0)) * time.Millisecond,
+		}
+		tg.Parameters = core.GetParameters(props, "Parameters")
+		return tg
+	})
+	core.RegisterFactory("UltimateThreadGroup", func(name string, props map[string]interface{}) core.TestElement {
+		tg := &UltimateThreadGroup{
 			BaseElement:        core.NewBaseElement(name),
-			Users:              core.GetInt(props, "Users", 1),
-			Iterations:         core.GetInt(props, "Iterations", 1),
+			Schedule:           parseUserScheduleRows(props),
 			HTTPRequestTimeout: time.Duration(core.GetInt(props, "HTTPRequestTimeoutMS", int(defaultThreadGroupHTTPRequestTimeout/time.Millisecond))) * time.Millisecond,
 			HTTPKeepAlive:      core.GetBool(props, "HTTPKeepAlive", defaultThreadGroupHTTPKeepAlive),
+			OnSampleError:      core.GetString(props, "OnSampleError", OnSampleErrorContinue),
+			StartDelay:         time.Duration(core.GetInt(props, "StartDelayMS", 0)) * time.Millisecond,
+			Duration:           time.Duration(core.GetInt(props, "RunDurationMS", 0)) * time.Millisecond,
 		}
 		tg.Parameters = core.GetParameters(props, "Parameters")
 		return tg
 	})
-	core.RegisterFactory("RPSThreadGroup", func(name string, props map[string]interface{}) core.TestElement {
-		tg := &RPSThreadGroup{
+	core.RegisterFactory("CapacityThreadGroup", func(name string, props map[string]interface{}) core.TestElement {
+		tg := &CapacityThreadGroup{
 			BaseElement:        core.NewBaseElement(name),
 			Users:              core.GetInt(props, "Users", 10),
-			RPS:                core.GetFloat(props, "RPS", 10.0),
+			StartRPS:           core.GetFloat(props, "StartRPS", 10),
+			StepRPS:            core.GetFloat(props, "StepRPS", 10),
+			MaxRPS:             core.GetFloat(props, "MaxRPS", 1000),
+			StepDuration:       time.Duration(core.GetInt(props, "StepDurationMS", 30000)) * time.Millisecond,
+			MaxP95Latency:      time.Duration(core.GetInt(props, "MaxP95LatencyMS", 500)) * time.Millisecond,
+			MaxErrorPercent:    core.GetFloat(props, "MaxErrorPercent", 1),
+			HTTPRequestTimeout: time.Duration(core.GetInt(props, "HTTPRequestTimeoutMS", int(defaultThreadGroupHTTPRequestTimeout/time.Millisecond))) * time.Millisecond,
+			HTTPKeepAlive:      core.GetBool(props, "HTTPKeepAlive", defaultThreadGroupHTTPKeepAlive),
+			OnSampleError:      core.GetString(props, "OnSampleError", OnSampleErrorContinue),
+			StartDelay:         time.Duration(core.GetInt(props, "StartDelayMS", 0)) * time.Millisecond,
+			Duration:           time.Duration(core.GetInt(props, "RunDurationMS", 0)) * time.Millisecond,
+		}
+		tg.Parameters = core.GetParameters(props, "Parameters")
+		return tg
+	})
+	core.RegisterFactory("ArrivalRateThreadGroup", func(name string, props map[string]interface{}) core.TestElement {
+		tg := &ArrivalRateThreadGroup{
+			BaseElement:        core.NewBaseElement(name),
+			Rate:               core.GetFloat(props, "Rate", 10.0),
+			PreAllocatedUsers:  core.GetInt(props, "PreAllocatedUsers", 1),
+			MaxUsers:           core.GetInt(props, "MaxUsers", 100),
 			ProfileBlocks:      parseRPSProfileBlocks(props),
 			GracefulShutdown:   time.Duration(core.GetInt(props, "GracefulShutdownMS", 0)) * time.Millisecond,
 			HTTPRequestTimeout: time.Duration(core.GetInt(props, "HTTPRequestTimeoutMS", int(defaultThreadGroupHTTPRequestTimeout/time.Millisecond))) * time.Millisecond,
 			HTTPKeepAlive:      core.GetBool(props, "HTTPKeepAlive", defaultThreadGroupHTTPKeepAlive),
+			OnSampleError:      core.GetString(props, "OnSampleError", OnSampleErrorContinue),
+			StartDelay:         time.Duration(core.GetInt(props, "StartDelayMS", 0)) * time.Millisecond,
+			Duration:           time.Duration(core.GetInt(props, "RunDurationMS", 0)) * time.Millisecond,
 		}
 		tg.Parameters = core.GetParameters(props, "Parameters")
 		return tg
 	})
 }
 
+func newSimpleThreadGroupFromProps(name string, props map[string]interface{}) *SimpleThreadGroup {
+	tg := &SimpleThreadGroup{
+		BaseElement:        core.NewBaseElement(name),
+		Users:              core.GetInt(props, "Users", 1),
+		Iterations:         core.GetInt(props, "Iterations", 1),
+		RampUp:             time.Duration(core.GetInt(props, "RampUpMS", 0)) * time.Millisecond,
+		Hold:               time.Duration(core.GetInt(props, "HoldMS", 0)) * time.Millisecond,
+		RampDown:           time.Duration(core.GetInt(props, "RampDownMS", 0)) * time.Millisecond,
+		HTTPRequestTimeout: time.Duration(core.GetInt(props, "HTTPRequestTimeoutMS", int(defaultThreadGroupHTTPRequestTimeout/time.Millisecond))) * time.Millisecond,
+		HTTPKeepAlive:      core.GetBool(props, "HTTPKeepAlive", defaultThreadGroupHTTPKeepAlive),
+		OnSampleError:      core.GetString(props, "OnSampleError", OnSampleErrorContinue),
+		StartDelay:         time.Duration(core.GetInt(props, "StartDelayMS", 0)) * time.Millisecond,
+		Duration:           time.Duration(core.GetInt(props, "RunDurationMS", 0)) * time.Millisecond,
+	}
+	tg.Parameters = core.GetParameters(props, "Parameters")
+	return tg
+}
+
 // --- Simple Thread Group ---
 
+// SimpleThreadGroup is a closed-model group of Users threads, started evenly over
+// RampUp. Each thread runs Iterations passes; when Hold is set the group also stops
+// at the end of ramp-up plus Hold, with threads leaving one by one over RampDown in
+// reverse start order. Time limits are checked between iterations.
 type SimpleThreadGroup struct {
 	core.BaseElement
 	Users              int
 	Iterations         int // -1 for infinite
 	RampUp             time.Duration
+	Hold               time.Duration // 0 runs by iterations only
+	RampDown           time.Duration // Requires Hold
 	HTTPRequestTimeout time.Duration
 	HTTPKeepAlive      bool
+	OnSampleError      string           // See OnSampleErrorPolicies
+	StartDelay         time.Duration    // Wait before starting, counted from the group's start
+	Duration           time.Duration    // 0 runs without a time limit
 	Parameters         []core.Parameter // Injected from Plan
 }
 
@@ -61,9 +170,15 @@ func (tg *SimpleThreadGroup) GetProps() map[string]interface{} {
 	return map[string]interface{}{
 		"Users":                tg.Users,
 		"Iterations":           tg.Iterations,
+		"RampUpMS":             tg.RampUp.Milliseconds(),
+		"HoldMS":               tg.Hold.Milliseconds(),
+		"RampDownMS":           tg.RampDown.Milliseconds(),
 		"Parameters":           tg.Parameters,
 		"HTTPRequestTimeoutMS": tg.HTTPRequestTimeout.Milliseconds(),
 		"HTTPKeepAlive":        tg.HTTPKeepAlive,
+		"OnSampleError":        tg.OnSampleError,
+		"StartDelayMS":         tg.StartDelay.Milliseconds(),
+		"RunDurationMS":        tg.Duration.Milliseconds(),
 	}
 }
 
@@ -74,9 +189,14 @@ func NewSimpleThreadGroup(name string, users, iterations int) *SimpleThreadGroup
 		Iterations:         iterations,
 		HTTPRequestTimeout: defaultThreadGroupHTTPRequestTimeout,
 		HTTPKeepAlive:      defaultThreadGroupHTTPKeepAlive,
+		OnSampleError:      OnSampleErrorContinue,
 	}
 }
 
+func (tg *SimpleThreadGroup) SetParameters(params []core.Parameter) {
+	tg.Parameters = params
+}
+
 func (tg *SimpleThreadGroup) Clone() core.TestElement {
 	newTG := *tg
 	newTG.BaseElement = core.NewBaseElement(tg.Name())
@@ -91,16 +211,67 @@ func (tg *SimpleThreadGroup) Validate() error {
 	if err := ValidateIterations(tg.Iterations); err != nil {
 		return err
 	}
+	if err := ValidateDuration("Ramp-up", tg.RampUp); err != nil {
+		return err
+	}
+	if err := ValidateDuration("Hold", tg.Hold); err != nil {
+		return err
+	}
+	if err := ValidateDuration("Ramp-down", tg.RampDown); err != nil {
+		return err
+	}
+	if tg.RampDown > 0 && tg.Hold <= 0 {
+		return fmt.Errorf("Ramp-down requires a hold duration")
+	}
+	if err := ValidateOnSampleError(tg.OnSampleError); err != nil {
+		return err
+	}
+	if err := validateThreadGroupSchedule(tg.StartDelay, tg.Duration); err != nil {
+		return err
+	}
+	if err := validateSyncTimers(tg, "Users", tg.Users); err != nil {
+		return err
+	}
 	return validateThreadGroupHTTPSettings(tg.HTTPRequestTimeout)
 }
 
+// stopTime returns when thread threadID must stop, or the zero time when the
+// group runs by iterations only.
+func (tg *SimpleThreadGroup) stopTime(start time.Time, threadID int) time.Time {
+	if tg.Hold <= 0 {
+		return time.Time{}
+	}
+	holdEnd := start.Add(tg.RampUp + tg.Hold)
+	if tg.RampDown <= 0 {
+		return holdEnd
+	}
+	// The last thread started leaves first; the first one leaves at the end of ramp-down.
+	return holdEnd.Add(tg.RampDown * time.Duration(tg.Users-threadID) / time.Duration(tg.Users))
+}
+
 func (tg *SimpleThreadGroup) Start(ctx context.Context, runner core.Runner) {
+	tg.run(ctx, runner, nil)
+}
+
+// run executes the group. When exports is set, each thread joins the variables its
+// elements set into exports as it finishes.
+func (tg *SimpleThreadGroup) run(ctx context.Context, runner core.Runner, exports *core.Context) {
 	if err := tg.Validate(); err != nil {
 		return
 	}
 
-	groupCtx := core.WithHTTPRuntime(ctx, newThreadGroupHTTPRuntime(tg.HTTPRequestTimeout, tg.HTTPKeepAlive))
+	groupCtx, cancel, ok := startThreadGroupWindow(ensureRunStore(ctx), tg.StartDelay, tg.Duration)
+	if !ok {
+		return
+	}
+	defer cancel()
+	groupCtx = core.WithHTTPRuntime(groupCtx, newThreadGroupHTTPRuntime(tg.HTTPRequestTimeout, tg.HTTPKeepAlive))
+
+	hooks := buildSampleHooks(tg)
+	activeUsers := newActiveUsersGauge(runner, tg.ID())
+	start := time.Now()
 
+	roster := newThreadRoster(tg.Users)
 	var wg sync.WaitGroup
 	wg.Add(tg.Users)
 
@@ -118,6 +289,7 @@ func (tg *SimpleThreadGroup) Start(ctx context.Context, runner core.Runner) {
 			case <-groupCtx.Done():
 				// If canceled during rampup, we still need to account for the added WG count
 				// But we shouldn't start the worker
+				roster.leave()
 				wg.Done()
 				continue
 			}
@@ -125,15 +297,21 @@ func (tg *SimpleThreadGroup) Start(ctx context.Context, runner core.Runner) {
 
 		go func(threadID int) {
 			defer wg.Done()
+			defer roster.leave()
+			activeUsers.add(1)
+			defer activeUsers.add(-1)
 
 			// Thread Context
-			tCtx := core.NewContext(groupCtx, threadID)
-			tCtx.SetVar("Reporter", runner)
-			// Inject parameters
-			for _, p := range tg.Parameters {
-				tCtx.SetVar(p.Name, p.Value)
-				tCtx.ParameterDefinitions[p.Name] = p
+			tCtx := newThreadContext(groupCtx, threadID, runner, tg.ID(), tg.Parameters)
+			tCtx.SetStateVar("OnSampleError", tg.OnSampleError)
+			tCtx.SetStateVar("SampleHooks", hooks)
+			tCtx.SetStateVar("ThreadRoster", roster)
+			if exports != nil {
+				// Only what the thread's elements set is exported, not the setup above
+				tCtx = tCtx.Fork()
+				defer exports.Join(tCtx)
 			}
+			stopAt := tg.stopTime(start, threadID)
 
 			for iter := 0; tg.Iterations == -1 || iter < tg.Iterations; iter++ {
 				// Check for stop
@@ -142,24 +320,14 @@ func (tg *SimpleThreadGroup) Start(ctx context.Context, runner core.Runner) {
 					return
 				default:
 				}
+				if !stopAt.IsZero() && !time.Now().Before(stopAt) {
+					return
+				}
 
 				tCtx.Iteration = iter
 
-				// Execute all children (skip disabled)
-				for _, child := range tg.GetChildren() {
-					if !child.Enabled() {
-						continue
-					}
-					if exec, ok := child.(core.Executable); ok {
-						err := exec.Execute(tCtx)
-						if err != nil {
-							// If error is due to cancellation, stop the thread
-							// We might also want to stop on other critical errors if configured
-							if groupCtx.Err() != nil {
-								return
-							}
-						}
-					}
+				if !runThreadIteration(tCtx, tg.GetChildren(), tg.OnSampleError, cancel) {
+					return
 				}
 			}
 		}(i)
@@ -168,6 +336,89 @@ func (tg *SimpleThreadGroup) Start(ctx context.Context, runner core.Runner) {
 	wg.Wait()
 }
 
+// --- Setup / Teardown Thread Groups ---
+
+// SetupThreadGroup runs before the main thread groups of a run, which start only once
+// it finishes. Variables its threads set become read-only run globals for the
+// groups that follow.
+type SetupThreadGroup struct {
+	SimpleThreadGroup
+}
+
+func NewSetupThreadGroup(name string) *SetupThreadGroup {
+	return &SetupThreadGroup{SimpleThreadGroup: *NewSimpleThreadGroup(name, 1, 1)}
+}
+
+func (tg *SetupThreadGroup) GetType() string {
+	return "SetupThreadGroup"
+}
+
+func (tg *SetupThreadGroup) Clone() core.TestElement {
+	return &SetupThreadGroup{SimpleThreadGroup: *tg.SimpleThreadGroup.Clone().(*SimpleThreadGroup)}
+}
+
+func (tg *SetupThreadGroup) RunPhase() string {
+	return core.RunPhaseSetup
+}
+
+func (tg *SetupThreadGroup) Start(ctx context.Context, runner core.Runner) {
+	tg.run(ctx, runner, core.VariableExportsFromContext(ctx))
+}
+
+// TeardownThreadGroup runs after the main thread groups, including when the run is
+// stopped. It ignores the run's cancellation and is bounded by Timeout instead.
+type TeardownThreadGroup struct {
+	SimpleThreadGroup
+	Timeout time.Duration
+}
+
+func NewTeardownThreadGroup(name string) *TeardownThreadGroup {
+	return &TeardownThreadGroup{
+		SimpleThreadGroup: *NewSimpleThreadGroup(name, 1, 1),
+		Timeout:           defaultTeardownTimeout,
+	}
+}
+
+func (tg *TeardownThreadGroup) GetType() string {
+	return "TeardownThreadGroup"
+}
+
+func (tg *TeardownThreadGroup) GetProps() map[string]interface{} {
+	props := tg.SimpleThreadGroup.GetProps()
+	props["TimeoutMS"] = tg.Timeout.Milliseconds()
+	return props
+}
+
+func (tg *TeardownThreadGroup) Clone() core.TestElement {
+	return &TeardownThreadGroup{
+		SimpleThreadGroup: *tg.SimpleThreadGroup.Clone().(*SimpleThreadGroup),
+		Timeout:           tg.Timeout,
+	}
+}
+
+func (tg *TeardownThreadGroup) Validate() error {
+	if err := tg.SimpleThreadGroup.Validate(); err != nil {
+		return err
+	}
+	if tg.Timeout <= 0 {
+		return fmt.Errorf("Timeout must be greater than 0 ms")
+	}
+	return nil
+}
+
+func (tg *TeardownThreadGroup) RunPhase() string {
+	return core.RunPhaseTeardown
+}
+
+func (tg *TeardownThreadGroup) Start(ctx context.Context, runner core.Runner) {
+	if err := tg.Validate(); err != nil {
+		return
+	}
+	teardownCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), tg.Timeout)
+	defer cancel()
+	tg.run(core.WithTestStopper(teardownCtx, cancel), runner, nil)
+}
+
 // --- RPS Thread Group ---
 
 type RPSThreadGroup struct {
@@ -178,6 +429,9 @@ type RPSThreadGroup struct {
 	GracefulShutdown   time.Duration
 	HTTPRequestTimeout time.Duration
 	HTTPKeepAlive      bool
+	OnSampleError      string        // See OnSampleErrorPolicies
+	StartDelay         time.Duration // Wait before starting, counted from the group's start
+	Duration           time.Duration // 0 runs without a time limit
 	Parameters         []core.Parameter
 }
 
@@ -196,6 +450,7 @@ func NewRPSThreadGroup(name string, rps float64) *RPSThreadGroup {
 		GracefulShutdown:   0,
 		HTTPRequestTimeout: defaultThreadGroupHTTPRequestTimeout,
 		HTTPKeepAlive:      defaultThreadGroupHTTPKeepAlive,
+		OnSampleError:      OnSampleErrorContinue,
 	}
 }
 
@@ -204,26 +459,24 @@ func (tg *RPSThreadGroup) GetType() string {
 }
 
 func (tg *RPSThreadGroup) GetProps() map[string]interface{} {
-	blocks := make([]map[string]interface{}, 0, len(tg.ProfileBlocks))
-	for _, block := range tg.ProfileBlocks {
-		blocks = append(blocks, map[string]interface{}{
-			"RampUpMS":       block.RampUp.Milliseconds(),
-			"StepDurationMS": block.StepDuration.Milliseconds(),
-			"ProfilePercent": block.ProfilePercent,
-		})
-	}
-
 	return map[string]interface{}{
 		"Users":                tg.Users,
 		"RPS":                  tg.RPS,
-		"ProfileBlocks":        blocks,
+		"ProfileBlocks":        rpsProfileBlocksProps(tg.ProfileBlocks),
 		"GracefulShutdownMS":   tg.GracefulShutdown.Milliseconds(),
 		"HTTPRequestTimeoutMS": tg.HTTPRequestTimeout.Milliseconds(),
 		"HTTPKeepAlive":        tg.HTTPKeepAlive,
+		"OnSampleError":        tg.OnSampleError,
+		"StartDelayMS":         tg.StartDelay.Milliseconds(),
+		"RunDurationMS":        tg.Duration.Milliseconds(),
 		"Parameters":           tg.Parameters,
 	}
 }
 
+func (tg *RPSThreadGroup) SetParameters(params []core.Parameter) {
+	tg.Parameters = params
+}
+
 func (tg *RPSThreadGroup) Clone() core.TestElement {
 	newTG := *tg
 	newTG.BaseElement = core.NewBaseElement(tg.Name())
@@ -242,18 +495,19 @@ func (tg *RPSThreadGroup) Validate() error {
 	if err := ValidateDuration("Graceful shutdown", tg.GracefulShutdown); err != nil {
 		return err
 	}
-	if err := validateThreadGroupHTTPSettings(tg.HTTPRequestTimeout); err != nil {
+	if err := ValidateOnSampleError(tg.OnSampleError); err != nil {
 		return err
 	}
-	for i, block := range tg.ProfileBlocks {
-		if err := ValidateDuration(fmt.Sprintf("Profile block %d ramp-up", i+1), block.RampUp); err != nil {
-			return err
-		}
-		if err := ValidateDuration(fmt.Sprintf("Profile block %d step duration", i+1), block.StepDuration); err != nil {
-			return err
-		}
+	if err := validateThreadGroupSchedule(tg.StartDelay, tg.Duration); err != nil {
+		return err
 	}
-	return nil
+	if err := validateSyncTimers(tg, "Users", tg.Users); err != nil {
+		return err
+	}
+	if err := validateThreadGroupHTTPSettings(tg.HTTPRequestTimeout); err != nil {
+		return err
+	}
+	return validateRPSProfileBlocks(tg.ProfileBlocks)
 }
 
 func (tg *RPSThreadGroup) Start(ctx context.Context, runner core.Runner) {
@@ -261,7 +515,10 @@ func (tg *RPSThreadGroup) Start(ctx context.Context, runner core.Runner) {
 		return
 	}
 
-	groupCtx, cancel := context.WithCancel(ctx)
+	groupCtx, cancel, ok := startThreadGroupWindow(ensureRunStore(ctx), tg.StartDelay, tg.Duration)
+	if !ok {
+		return
+	}
 	defer cancel()
 	groupCtx = core.WithHTTPRuntime(groupCtx, newThreadGroupHTTPRuntime(tg.HTTPRequestTimeout, tg.HTTPKeepAlive))
 
@@ -294,6 +551,10 @@ func (tg *RPSThreadGroup) Start(ctx context.Context, runner core.Runner) {
 		}
 	}()
 
+	hooks := buildSampleHooks(tg)
+	activeUsers := newActiveUsersGauge(runner, tg.ID())
+
+	roster := newThreadRoster(tg.Users)
 	var wg sync.WaitGroup
 	wg.Add(tg.Users)
 
@@ -301,25 +562,25 @@ func (tg *RPSThreadGroup) Start(ctx context.Context, runner core.Runner) {
 	for i := 0; i < tg.Users; i++ {
 		go func(threadID int) {
 			defer wg.Done()
+			defer roster.leave()
+			activeUsers.add(1)
+			defer activeUsers.add(-1)
 
 			// Thread Context
-			tCtx := core.NewContext(groupCtx, threadID)
-			tCtx.SetVar("Reporter", runner)
-			// Inject parameters
-			for _, p := range tg.Parameters {
-				tCtx.SetVar(p.Name, p.Value)
-				tCtx.ParameterDefinitions[p.Name] = p
-			}
+			tCtx := newThreadContext(groupCtx, threadID, runner, tg.ID(), tg.Parameters)
 			// Inject DefaultRPS for children to inherit if they don't have one
-			tCtx.SetVar("DefaultRPS", tg.RPS)
+			tCtx.SetStateVar("DefaultRPS", tg.RPS)
 			// RPS Thread Group uses shared, non-blocking limiter checks so each sampler
 			// can run at its own rate without being stalled by slower siblings.
-			tCtx.SetVar("SharedLimiterStore", sharedLimiters)
-			tCtx.SetVar("RPSNonBlocking", true)
-			tCtx.SetVar("RPSProfileScale", profileScale)
+			tCtx.SetStateVar("SharedLimiterStore", sharedLimiters)
+			tCtx.SetStateVar("RPSNonBlocking", true)
+			tCtx.SetStateVar("RPSProfileScale", profileScale)
+			tCtx.SetStateVar("OnSampleError", tg.OnSampleError)
+			tCtx.SetStateVar("SampleHooks", hooks)
+			tCtx.SetStateVar("ThreadRoster", roster)
 
 			// Loop until timeout or cancellation
-			for {
+			for iter := 0; ; iter++ {
 				select {
 				case <-groupCtx.Done():
 					return
@@ -327,19 +588,9 @@ func (tg *RPSThreadGroup) Start(ctx context.Context, runner core.Runner) {
 					return
 				default:
 					runtime.Gosched()
-					// Execute children (skip disabled)
-					for _, child := range tg.GetChildren() {
-						if !child.Enabled() {
-							continue
-						}
-						if exec, ok := child.(core.Executable); ok {
-							if err := exec.Execute(tCtx); err != nil {
-								if groupCtx.Err() != nil {
-									return
-								}
-								// Log other errors?
-							}
-						}
+					tCtx.Iteration = iter
+					if !runThreadIteration(tCtx, tg.GetChildren(), tg.OnSampleError, cancel) {
+						return
 					}
 				}
 			}
@@ -349,6 +600,888 @@ func (tg *RPSThreadGroup) Start(ctx context.Context, runner core.Runner) {
 	wg.Wait()
 }
 
+// --- Arrival Rate Thread Group ---
+
+// ArrivalRateThreadGroup is an open-model group: it starts one iteration per arrival at
+// Rate arrivals per second, shaped by ProfileBlocks, regardless of how long iterations
+// take. Arrivals go to an idle virtual user; the pool grows on demand up to MaxUsers,
+// after which arrivals are dropped and reported as "<name> dropped iterations".
+type ArrivalRateThreadGroup struct {
+	core.BaseElement
+	Rate               float64 // Arrivals per second at 100% profile
+	PreAllocatedUsers  int     // Virtual users started before the first arrival
+	MaxUsers           int
+	ProfileBlocks      []RPSProfileBlock
+	GracefulShutdown   time.Duration
+	HTTPRequestTimeout time.Duration
+	HTTPKeepAlive      bool
+	OnSampleError      string        // See OnSampleErrorPolicies
+	StartDelay         time.Duration // Wait before starting, counted from the group's start
+	Duration           time.Duration // 0 runs without a time limit
+	Parameters         []core.Parameter
+}
+
+func NewArrivalRateThreadGroup(name string, rate float64) *ArrivalRateThreadGroup {
+	return &ArrivalRateThreadGroup{
+		BaseElement:        core.NewBaseElement(name),
+		Rate:               rate,
+		PreAllocatedUsers:  1,
+		MaxUsers:           100,
+		ProfileBlocks:      []RPSProfileBlock{{RampUp: 0, StepDuration: 60 * time.Second, ProfilePercent: 100}},
+		HTTPRequestTimeout: defaultThreadGroupHTTPRequestTimeout,
+		HTTPKeepAlive:      defaultThreadGroupHTTPKeepAlive,
+		OnSampleError:      OnSampleErrorContinue,
+	}
+}
+
+func (tg *ArrivalRateThreadGroup) GetType() string {
+	return "ArrivalRateThreadGroup"
+}
+
+func (tg *ArrivalRateThreadGroup) GetProps() map[string]interface{} {
+	return map[string]interface{}{
+		"Rate":                 tg.Rate,
+		"PreAllocatedUsers":    tg.PreAllocatedUsers,
+		"MaxUsers":             tg.MaxUsers,
+		"ProfileBlocks":        rpsProfileBlocksProps(tg.ProfileBlocks),
+		"GracefulShutdownMS":   tg.GracefulShutdown.Milliseconds(),
+		"HTTPRequestTimeoutMS": tg.HTTPRequestTimeout.Milliseconds(),
+		"HTTPKeepAlive":        tg.HTTPKeepAlive,
+		"OnSampleError":        tg.OnSampleError,
+		"StartDelayMS":         tg.StartDelay.Milliseconds(),
+		"RunDurationMS":        tg.Duration.Milliseconds(),
+		"Parameters":           tg.Parameters,
+	}
+}
+
+func (tg *ArrivalRateThreadGroup) SetParameters(params []core.Parameter) {
+	tg.Parameters = params
+}
+
+func (tg *ArrivalRateThreadGroup) Clone() core.TestElement {
+	newTG := *tg
+	newTG.BaseElement = core.NewBaseElement(tg.Name())
+	newTG.ProfileBlocks = append([]RPSProfileBlock(nil), tg.ProfileBlocks...)
+	newTG.Parameters = append([]core.Parameter(nil), tg.Parameters...)
+	return &newTG
+}
+
+func (tg *ArrivalRateThreadGroup) Validate() error {
+	if err := ValidateRPS("Arrival rate", tg.Rate); err != nil {
+		return err
+	}
+	if tg.MaxUsers < 1 {
+		return fmt.Errorf("Max users must be greater than or equal to 1")
+	}
+	if err := ValidateNonNegative("Pre-allocated users", tg.PreAllocatedUsers); err != nil {
+		return err
+	}
+	if tg.PreAllocatedUsers > tg.MaxUsers {
+		return fmt.Errorf("Pre-allocated users must not exceed max users")
+	}
+	if err := ValidateDuration("Graceful shutdown", tg.GracefulShutdown); err != nil {
+		return err
+	}
+	if err := ValidateOnSampleError(tg.OnSampleError); err != nil {
+		return err
+	}
+	if err := validateThreadGroupSchedule(tg.StartDelay, tg.Duration); err != nil {
+		return err
+	}
+	if err := validateSyncTimers(tg, "Max users", tg.MaxUsers); err != nil {
+		return err
+	}
+	if err := validateThreadGroupHTTPSettings(tg.HTTPRequestTimeout); err != nil {
+		return err
+	}
+	return validateRPSProfileBlocks(tg.ProfileBlocks)
+}
+
+// maxArrivalWait bounds how long the scheduler sleeps, so rate changes during a
+// ramp take effect promptly even at very low rates.
+const maxArrivalWait = 10 * time.Millisecond
+
+func (tg *ArrivalRateThreadGroup) Start(ctx context.Context, runner core.Runner) {
+	if err := tg.Validate(); err != nil {
+		return
+	}
+
+	groupCtx, cancel, ok := startThreadGroupWindow(ensureRunStore(ctx), tg.StartDelay, tg.Duration)
+	if !ok {
+		return
+	}
+	defer cancel()
+	groupCtx = core.WithHTTPRuntime(groupCtx, newThreadGroupHTTPRuntime(tg.HTTPRequestTimeout, tg.HTTPKeepAlive))
+
+	profileScale := newProfileScaleState(0)
+	arrivalsDone := make(chan struct{})
+	go func() {
+		defer close(arrivalsDone)
+		runRPSProfileBlocks(groupCtx, tg.ProfileBlocks, profileScale)
+	}()
+
+	hooks := buildSampleHooks(tg)
+	activeUsers := newActiveUsersGauge(runner, tg.ID())
+	arrivals := make(chan struct{})
+
+	var wg sync.WaitGroup
+	var poolMu sync.Mutex
+	users, nextThreadID := 0, 0
+
+	// worker runs one virtual user. A user spawned for an arrival runs its first
+	// iteration immediately; pre-allocated users wait for one.
+	worker := func(threadID int, runNow bool) {
+		activeUsers.add(1)
+		defer func() {
+			activeUsers.add(-1)
+			poolMu.Lock()
+			users--
+			poolMu.Unlock()
+			wg.Done()
+		}()
+
+		// Thread Context
+		tCtx := newThreadContext(groupCtx, threadID, runner, tg.ID(), tg.Parameters)
+		tCtx.SetStateVar("OnSampleError", tg.OnSampleError)
+		tCtx.SetStateVar("SampleHooks", hooks)
+
+		for iter := 0; ; iter++ {
+			if !runNow {
+				select {
+				case <-arrivals:
+				case <-arrivalsDone:
+					return
+				case <-groupCtx.Done():
+					return
+				}
+			}
+			runNow = false
+
+			tCtx.Iteration = iter
+			if !runThreadIteration(tCtx, tg.GetChildren(), tg.OnSampleError, cancel) {
+				return
+			}
+		}
+	}
+
+	// spawn adds a virtual user unless the pool is already at MaxUsers.
+	spawn := func(runNow bool) bool {
+		poolMu.Lock()
+		defer poolMu.Unlock()
+		if users >= tg.MaxUsers {
+			return false
+		}
+		users++
+		threadID := nextThreadID
+		nextThreadID++
+		wg.Add(1)
+		go worker(threadID, runNow)
+		return true
+	}
+
+	for i := 0; i < tg.PreAllocatedUsers; i++ {
+		spawn(false)
+	}
+
+	dispatch := func() {
+		select {
+		case arrivals <- struct{}{}:
+			return
+		default:
+		}
+		if spawn(true) {
+			return
+		}
+		now := time.Now()
+		runner.ReportResult(&core.SampleResult{
+			SamplerName: tg.Name() + " dropped iterations",
+			StartTime:   now,
+			EndTime:     now,
+			Error:       ErrIterationDropped,
+			Kind:        core.SampleDroppedIteration,
+			ThreadGroup: tg.ID(),
+		})
+	}
+
+	// Arrivals accumulate as rate x elapsed time, so ramps are followed without drift.
+	pending := 0.0
+	last := time.Now()
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+schedule:
+	for {
+		currentRate := tg.Rate * profileScale.get()
+		wait := maxArrivalWait
+		if currentRate > 0 {
+			if untilNext := time.Duration((1 - pending) / currentRate * float64(time.Second)); untilNext < wait {
+				wait = untilNext
+			}
+		}
+		timer.Reset(wait)
+
+		select {
+		case <-arrivalsDone:
+			break schedule
+		case <-groupCtx.Done():
+			break schedule
+		case now := <-timer.C:
+			pending += currentRate * now.Sub(last).Seconds()
+			last = now
+		}
+
+		for ; pending >= 1; pending-- {
+			dispatch()
+		}
+	}
+
+	finished := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(finished)
+	}()
+	if tg.GracefulShutdown > 0 {
+		select {
+		case <-finished:
+		case <-groupCtx.Done():
+		case <-time.After(tg.GracefulShutdown):
+		}
+	}
+	cancel()
+	<-finished
+}
+
+// --- Ultimate Thread Group ---
+
+// UltimateThreadGroup shapes concurrency with a schedule: each row starts its own
+// virtual users after StartDelay, spread over RampUp, keeps them for Hold and stops
+// them over RampDown, last started first. Rows overlap freely, so steps, spikes and
+// plateaus are expressed as independent rows. Stops are checked between iterations.
+type UltimateThreadGroup struct {
+	core.BaseElement
+	Schedule           []UserScheduleRow
+	HTTPRequestTimeout time.Duration
+	HTTPKeepAlive      bool
+	OnSampleError      string        // See OnSampleErrorPolicies
+	StartDelay         time.Duration // Wait before starting, counted from the group's start
+	Duration           time.Duration // 0 runs without a time limit
+	Parameters         []core.Parameter
+}
+
+type UserScheduleRow struct {
+	StartDelay time.Duration
+	Users      int
+	RampUp     time.Duration
+	Hold       time.Duration
+	RampDown   time.Duration
+}
+
+func NewUltimateThreadGroup(name string) *UltimateThreadGroup {
+	return &UltimateThreadGroup{
+		BaseElement:        core.NewBaseElement(name),
+		Schedule:           []UserScheduleRow{{Users: 10, RampUp: 10 * time.Second, Hold: 60 * time.Second, RampDown: 10 * time.Second}},
+		HTTPRequestTimeout: defaultThreadGroupHTTPRequestTimeout,
+		HTTPKeepAlive:      defaultThreadGroupHTTPKeepAlive,
+		OnSampleError:      OnSampleErrorContinue,
+	}
+}
+
+func (tg *UltimateThreadGroup) GetType() string {
+	return "UltimateThreadGroup"
+}
+
+func (tg *UltimateThreadGroup) GetProps() map[string]interface{} {
+	rows := make([]map[string]interface{}, 0, len(tg.Schedule))
+	for _, row := range tg.Schedule {
+		rows = append(rows, map[string]interface{}{
+			"StartDelayMS": row.StartDelay.Milliseconds(),
+			"Users":        row.Users,
+			"RampUpMS":     row.RampUp.Milliseconds(),
+			"HoldMS":       row.Hold.Milliseconds(),
+			"RampDownMS":   row.RampDown.Milliseconds(),
+		})
+	}
+
+	return map[string]interface{}{
+		"Schedule":             rows,
+		"HTTPRequestTimeoutMS": tg.HTTPRequestTimeout.Milliseconds(),
+		"HTTPKeepAlive":        tg.HTTPKeepAlive,
+		"OnSampleError":        tg.OnSampleError,
+		"StartDelayMS":         tg.StartDelay.Milliseconds(),
+		"RunDurationMS":        tg.Duration.Milliseconds(),
+		"Parameters":           tg.Parameters,
+	}
+}
+
+func (tg *UltimateThreadGroup) SetParameters(params []core.Parameter) {
+	tg.Parameters = params
+}
+
+func (tg *UltimateThreadGroup) Clone() core.TestElement {
+	newTG := *tg
+	newTG.BaseElement = core.NewBaseElement(tg.Name())
+	newTG.Schedule = append([]UserScheduleRow(nil), tg.Schedule...)
+	newTG.Parameters = append([]core.Parameter(nil), tg.Parameters...)
+	return &newTG
+}
+
+func (tg *UltimateThreadGroup) Validate() error {
+	if len(tg.Schedule) == 0 {
+		return fmt.Errorf("Schedule requires at least one row")
+	}
+	for i, row := range tg.Schedule {
+		if row.Users < 1 {
+			return fmt.Errorf("Schedule row %d users must be greater than or equal to 1", i+1)
+		}
+		if err := ValidateDuration(fmt.Sprintf("Schedule row %d start delay", i+1), row.StartDelay); err != nil {
+			return err
+		}
+		if err := ValidateDuration(fmt.Sprintf("Schedule row %d ramp-up", i+1), row.RampUp); err != nil {
+			return err
+		}
+		if err := ValidateDuration(fmt.Sprintf("Schedule row %d ramp-down", i+1), row.RampDown); err != nil {
+			return err
+		}
+		if row.Hold <= 0 {
+			return fmt.Errorf("Schedule row %d hold must be greater than 0 ms", i+1)
+		}
+	}
+	if err := ValidateOnSampleError(tg.OnSampleError); err != nil {
+		return err
+	}
+	if err := validateThreadGroupSchedule(tg.StartDelay, tg.Duration); err != nil {
+		return err
+	}
+	if err := validateSyncTimers(tg, "Schedule users", tg.totalUsers()); err != nil {
+		return err
+	}
+	return validateThreadGroupHTTPSettings(tg.HTTPRequestTimeout)
+}
+
+// totalUsers returns how many virtual users the schedule starts over the whole run.
+func (tg *UltimateThreadGroup) totalUsers() int {
+	total := 0
+	for _, row := range tg.Schedule {
+		total += row.Users
+	}
+	return total
+}
+
+func (tg *UltimateThreadGroup) Start(ctx context.Context, runner core.Runner) {
+	if err := tg.Validate(); err != nil {
+		return
+	}
+
+	groupCtx, cancel, ok := startThreadGroupWindow(ensureRunStore(ctx), tg.StartDelay, tg.Duration)
+	if !ok {
+		return
+	}
+	defer cancel()
+	groupCtx = core.WithHTTPRuntime(groupCtx, newThreadGroupHTTPRuntime(tg.HTTPRequestTimeout, tg.HTTPKeepAlive))
+
+	hooks := buildSampleHooks(tg)
+	activeUsers := newActiveUsersGauge(runner, tg.ID())
+	start := time.Now()
+
+	roster := newThreadRoster(tg.totalUsers())
+	var wg sync.WaitGroup
+	threadID := 0
+	for _, row := range tg.Schedule {
+		for i := 0; i < row.Users; i++ {
+			startAt, stopAt := row.userWindow(start, i)
+			wg.Add(1)
+			go func(threadID int) {
+				defer wg.Done()
+				defer roster.leave()
+				if !waitForDuration(groupCtx, time.Until(startAt)) {
+					return
+				}
+				activeUsers.add(1)
+				defer activeUsers.add(-1)
+
+				// Thread Context
+				tCtx := newThreadContext(groupCtx, threadID, runner, tg.ID(), tg.Parameters)
+				tCtx.SetStateVar("OnSampleError", tg.OnSampleError)
+				tCtx.SetStateVar("SampleHooks", hooks)
+				tCtx.SetStateVar("ThreadRoster", roster)
+
+				for iter := 0; time.Now().Before(stopAt); iter++ {
+					if groupCtx.Err() != nil {
+						return
+					}
+					tCtx.Iteration = iter
+					if !runThreadIteration(tCtx, tg.GetChildren(), tg.OnSampleError, cancel) {
+						return
+					}
+				}
+			}(threadID)
+			threadID++
+		}
+	}
+
+	wg.Wait()
+}
+
+// userWindow returns when the user-th virtual user of the row starts and stops.
+func (row UserScheduleRow) userWindow(groupStart time.Time, user int) (time.Time, time.Time) {
+	rowStart := groupStart.Add(row.StartDelay)
+	startAt := rowStart
+	if row.Users > 1 {
+		startAt = rowStart.Add(row.RampUp * time.Duration(user) / time.Duration(row.Users-1))
+	}
+	// The last user started leaves first; the first one leaves at the end of ramp-down.
+	holdEnd := rowStart.Add(row.RampUp + row.Hold)
+	stopAt := holdEnd.Add(row.RampDown * time.Duration(row.Users-user) / time.Duration(row.Users))
+	return startAt, stopAt
+}
+
+func parseUserScheduleRows(props map[string]interface{}) []UserScheduleRow {
+	items, ok := props["Schedule"].([]interface{})
+	if !ok {
+		return nil
+	}
+
+	rows := make([]UserScheduleRow, 0, len(items))
+	for _, item := range items {
+		m, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		rows = append(rows, UserScheduleRow{
+			StartDelay: time.Duration(core.GetInt(m, "StartDelayMS", 0)) * time.Millisecond,
+			Users:      core.GetInt(m, "Users", 1),
+			RampUp:     time.Duration(core.GetInt(m, "RampUpMS", 0)) * time.Millisecond,
+			Hold:       time.Duration(core.GetInt(m, "HoldMS", 0)) * time.Millisecond,
+			RampDown:   time.Duration(core.GetInt(m, "RampDownMS", 0)) * time.Millisecond,
+		})
+	}
+
+	return rows
+}
+
+// --- Capacity Thread Group ---
+
+// CapacityThreadGroup searches for the highest rate the system under test sustains
+// within its SLOs. It drives its samplers like an RPSThreadGroup, starting at StartRPS
+// and adding StepRPS every StepDuration up to MaxRPS while the p95 latency and error
+// rate of its samplers stay within MaxP95Latency and MaxErrorPercent. On a breach it
+// backs off one step at a time, holding each lower rate for a confirmation step, and
+// reports the rate that held through core.SustainableRateReporter. SLOs are judged on
+// the live stats of the group's own samples from a runner implementing
+// core.LiveStatsProvider, such as StatsRunner.
+type CapacityThreadGroup struct {
+	core.BaseElement
+	Users              int     // Max concurrent workers
+	StartRPS           float64 // Per-sampler rate, as in RPSThreadGroup.RPS
+	StepRPS            float64
+	MaxRPS             float64
+	StepDuration       time.Duration
+	MaxP95Latency      time.Duration
+	MaxErrorPercent    float64
+	HTTPRequestTimeout time.Duration
+	HTTPKeepAlive      bool
+	OnSampleError      string        // See OnSampleErrorPolicies
+	StartDelay         time.Duration // Wait before starting, counted from the group's start
+	Duration           time.Duration // 0 runs without a time limit
+	Parameters         []core.Parameter
+}
+
+func NewCapacityThreadGroup(name string) *CapacityThreadGroup {
+	return &CapacityThreadGroup{
+		BaseElement:        core.NewBaseElement(name),
+		Users:              10,
+		StartRPS:           10,
+		StepRPS:            10,
+		MaxRPS:             1000,
+		StepDuration:       30 * time.Second,
+		MaxP95Latency:      500 * time.Millisecond,
+		MaxErrorPercent:    1,
+		HTTPRequestTimeout: defaultThreadGroupHTTPRequestTimeout,
+		HTTPKeepAlive:      defaultThreadGroupHTTPKeepAlive,
+		OnSampleError:      OnSampleErrorContinue,
+	}
+}
+
+func (tg *CapacityThreadGroup) GetType() string {
+	return "CapacityThreadGroup"
+}
+
+func (tg *CapacityThreadGroup) GetProps() map[string]interface{} {
+	return map[string]interface{}{
+		"Users":                tg.Users,
+		"StartRPS":             tg.StartRPS,
+		"StepRPS":              tg.StepRPS,
+		"MaxRPS":               tg.MaxRPS,
+		"StepDurationMS":       tg.StepDuration.Milliseconds(),
+		"MaxP95LatencyMS":      tg.MaxP95Latency.Milliseconds(),
+		"MaxErrorPercent":      tg.MaxErrorPercent,
+		"HTTPRequestTimeoutMS": tg.HTTPRequestTimeout.Milliseconds(),
+		"HTTPKeepAlive":        tg.HTTPKeepAlive,
+		"OnSampleError":        tg.OnSampleError,
+		"StartDelayMS":         tg.StartDelay.Milliseconds(),
+		"RunDurationMS":        tg.Duration.Milliseconds(),
+		"Parameters":           tg.Parameters,
+	}
+}
+
+func (tg *CapacityThreadGroup) SetParameters(params []core.Parameter) {
+	tg.Parameters = params
+}
+
+func (tg *CapacityThreadGroup) Clone() core.TestElement {
+	newTG := *tg
+	newTG.BaseElement = core.NewBaseElement(tg.Name())
+	newTG.Parameters = append([]core.Parameter(nil), tg.Parameters...)
+	return &newTG
+}
+
+func (tg *CapacityThreadGroup) Validate() error {
+	if err := ValidateUsers(tg.Users); err != nil {
+		return err
+	}
+	if tg.StartRPS <= 0 {
+		return fmt.Errorf("Start RPS must be greater than 0")
+	}
+	if tg.StepRPS <= 0 {
+		return fmt.Errorf("Step RPS must be greater than 0")
+	}
+	if tg.MaxRPS < tg.StartRPS {
+		return fmt.Errorf("Max RPS must be greater than or equal to start RPS")
+	}
+	if tg.StepDuration <= 0 {
+		return fmt.Errorf("Step duration must be greater than 0 ms")
+	}
+	if tg.MaxP95Latency <= 0 {
+		return fmt.Errorf("Max p95 latency must be greater than 0 ms")
+	}
+	if math.IsNaN(tg.MaxErrorPercent) || tg.MaxErrorPercent < 0 || tg.MaxErrorPercent > 100 {
+		return fmt.Errorf("Max error percent must be between 0 and 100")
+	}
+	if err := ValidateOnSampleError(tg.OnSampleError); err != nil {
+		return err
+	}
+	if err := validateThreadGroupSchedule(tg.StartDelay, tg.Duration); err != nil {
+		return err
+	}
+	if err := validateSyncTimers(tg, "Users", tg.Users); err != nil {
+		return err
+	}
+	return validateThreadGroupHTTPSettings(tg.HTTPRequestTimeout)
+}
+
+func (tg *CapacityThreadGroup) Start(ctx context.Context, runner core.Runner) {
+	if err := tg.Validate(); err != nil {
+		return
+	}
+	stats, ok := runner.(core.LiveStatsProvider)
+	if !ok {
+		log.Printf("Warning: capacity thread group %q needs live stats and was not started", tg.Name())
+		return
+	}
+
+	groupCtx, cancel, ok := startThreadGroupWindow(ensureRunStore(ctx), tg.StartDelay, tg.Duration)
+	if !ok {
+		return
+	}
+	defer cancel()
+	groupCtx = core.WithHTTPRuntime(groupCtx, newThreadGroupHTTPRuntime(tg.HTTPRequestTimeout, tg.HTTPKeepAlive))
+
+	// Samplers run at MaxRPS scaled down to the rate under test
+	sharedLimiters := newLimiterStore()
+	profileScale := newProfileScaleState(tg.StartRPS / tg.MaxRPS)
+	searchDone := make(chan struct{})
+
+	hooks := buildSampleHooks(tg)
+	activeUsers := newActiveUsersGauge(runner, tg.ID())
+
+	roster := newThreadRoster(tg.Users)
+	var wg sync.WaitGroup
+	wg.Add(tg.Users)
+
+	for i := 0; i < tg.Users; i++ {
+		go func(threadID int) {
+			defer wg.Done()
+			defer roster.leave()
+			activeUsers.add(1)
+			defer activeUsers.add(-1)
+
+			// Thread Context
+			tCtx := newThreadContext(groupCtx, threadID, runner, tg.ID(), tg.Parameters)
+			tCtx.SetStateVar("DefaultRPS", tg.MaxRPS)
+			tCtx.SetStateVar("SharedLimiterStore", sharedLimiters)
+			tCtx.SetStateVar("RPSNonBlocking", true)
+			tCtx.SetStateVar("RPSProfileScale", profileScale)
+			tCtx.SetStateVar("OnSampleError", tg.OnSampleError)
+			tCtx.SetStateVar("SampleHooks", hooks)
+			tCtx.SetStateVar("ThreadRoster", roster)
+
+			for iter := 0; ; iter++ {
+				select {
+				case <-groupCtx.Done():
+					return
+				case <-searchDone:
+					return
+				default:
+					runtime.Gosched()
+					tCtx.Iteration = iter
+					if !runThreadIteration(tCtx, tg.GetChildren(), tg.OnSampleError, cancel) {
+						return
+					}
+				}
+			}
+		}(i)
+	}
+
+	sustainable := tg.search(groupCtx, stats, profileScale)
+	close(searchDone)
+	wg.Wait()
+
+	log.Printf("Capacity thread group %q: highest sustainable rate %.2f RPS", tg.Name(), sustainable)
+	if reporter, ok := runner.(core.SustainableRateReporter); ok {
+		reporter.SetSustainableRPS(tg.ID(), sustainable)
+	}
+}
+
+// search steps the rate up until the SLOs break or MaxRPS holds, then backs off until
+// a rate holds for a whole step. It returns the highest rate that held, 0 when none did.
+func (tg *CapacityThreadGroup) search(ctx context.Context, stats core.LiveStatsProvider, scale *profileScaleState) float64 {
+	rate, best := tg.StartRPS, 0.0
+	backingOff := false
+	for {
+		scale.set(rate / tg.MaxRPS)
+		held, finished := tg.holdStep(ctx, stats)
+		if !finished {
+			return best
+		}
+		if held {
+			best = rate
+			if backingOff || rate >= tg.MaxRPS {
+				return best
+			}
+			rate = math.Min(rate+tg.StepRPS, tg.MaxRPS)
+			continue
+		}
+
+		// A rate that held before no longer does
+		if rate <= best {
+			best = 0
+		}
+		backingOff = true
+		rate -= tg.StepRPS
+		if rate < tg.StartRPS {
+			return best
+		}
+	}
+}
+
+// holdStep runs the current rate for one step, checking the SLOs each time the stats
+// advance. held is false as soon as a check breaches them; finished is false when ctx
+// ends first.
+func (tg *CapacityThreadGroup) holdStep(ctx context.Context, stats core.LiveStatsProvider) (held, finished bool) {
+	interval := min(time.Second, max(tg.StepDuration/4, time.Millisecond))
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	deadline := time.NewTimer(tg.StepDuration)
+	defer deadline.Stop()
+
+	// The first window straddles the rate change and only sets the baseline
+	var last sloWindow
+	checks := 0
+	for {
+		select {
+		case <-ctx.Done():
+			return false, false
+		case <-deadline.C:
+			return true, true
+		case <-ticker.C:
+		}
+
+		window := newSLOWindow(stats.GroupSnapshot(tg.ID()))
+		checks++
+		requests, failed := window.requests-last.requests, window.errors-last.errors
+		last = window
+		if checks == 1 || requests <= 0 {
+			continue
+		}
+		if float64(failed)*100/float64(requests) > tg.MaxErrorPercent ||
+			window.p95 > float64(tg.MaxP95Latency.Milliseconds()) {
+			return false, true
+		}
+	}
+}
+
+// sloWindow sums the cumulative counts of a group's request series and keeps their
+// worst p95 in the latest stats window.
+type sloWindow struct {
+	requests int
+	errors   int
+	p95      float64
+}
+
+func newSLOWindow(series map[string]core.Metric) sloWindow {
+	var w sloWindow
+	for _, metric := range series {
+		w.requests += metric.TotalRequests
+		w.errors += metric.TotalErrors
+		w.p95 = math.Max(w.p95, metric.P95Latency)
+	}
+	return w
+}
+
+// activeUsersGauge reports how many virtual users a thread group runs to runners
+// implementing core.ActiveUsersReporter.
+type activeUsersGauge struct {
+	mu       sync.Mutex
+	users    int
+	group    string
+	reporter core.ActiveUsersReporter
+}
+
+func newActiveUsersGauge(runner core.Runner, group string) *activeUsersGauge {
+	reporter, _ := runner.(core.ActiveUsersReporter)
+	return &activeUsersGauge{group: group, reporter: reporter}
+}
+
+func (g *activeUsersGauge) add(delta int) {
+	if g.reporter == nil {
+		return
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.users += delta
+	g.reporter.SetActiveUsers(g.group, g.users)
+}
+
+func rpsProfileBlocksProps(blocks []RPSProfileBlock) []map[string]interface{} {
+	props := make([]map[string]interface{}, 0, len(blocks))
+	for _, block := range blocks {
+		props = append(props, map[string]interface{}{
+			"RampUpMS":       block.RampUp.Milliseconds(),
+			"StepDurationMS": block.StepDuration.Milliseconds(),
+			"ProfilePercent": block.ProfilePercent,
+		})
+	}
+	return props
+}
+
+func validateRPSProfileBlocks(blocks []RPSProfileBlock) error {
+	for i, block := range blocks {
+		if err := ValidateDuration(fmt.Sprintf("Profile block %d ramp-up", i+1), block.RampUp); err != nil {
+			return err
+		}
+		if err := ValidateDuration(fmt.Sprintf("Profile block %d step duration", i+1), block.StepDuration); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// newThreadContext creates a virtual user's context with the reporter and the plan
+// parameters. A parameter value is stored in the parameter's scope only when the
+// thread sees no value of that name yet, so values exported by setup thread groups
+// and shared values already set by other threads win over parameter defaults.
+func newThreadContext(groupCtx context.Context, threadID int, runner core.Runner, group string, params []core.Parameter) *core.Context {
+	tCtx := core.NewContext(groupCtx, threadID)
+	if runner != nil {
+		runner = groupReporter{next: runner, group: group}
+	}
+	tCtx.SetStateVar("Reporter", runner)
+	for _, p := range params {
+		tCtx.ParameterDefinitions[p.Name] = p
+		tCtx.SetScopedVarIfAbsent(p.Scope, p.Name, p.Value)
+	}
+	return tCtx
+}
+
+// groupReporter tags the samples of a thread group's threads with the group's ID on
+// their way to the runner.
+type groupReporter struct {
+	next  core.Runner
+	group string
+}
+
+func (r groupReporter) ReportResult(result *core.SampleResult) {
+	if result.ThreadGroup == "" {
+		result.ThreadGroup = r.group
+	}
+	r.next.ReportResult(result)
+}
+
+// ensureRunStore gives thread groups started outside a full run their own RunStore.
+func ensureRunStore(ctx context.Context) context.Context {
+	if core.RunStoreFromContext(ctx) != nil {
+		return ctx
+	}
+	return core.WithRunStore(ctx, core.NewRunStore())
+}
+
+// runThreadIteration executes one pass over the thread group children and applies
+// the on-sample-error policy to any error they return other than ErrStopThread,
+// which always ends the thread. It reports whether the thread should keep
+// iterating. stopGroup is used for OnSampleErrorStopTest when the run context
+// carries no test stopper.
+func runThreadIteration(tCtx *core.Context, children []core.TestElement, policy string, stopGroup func()) bool {
+	for _, child := range children {
+		if !child.Enabled() {
+			continue
+		}
+		exec, ok := child.(core.Executable)
+		if !ok {
+			continue
+		}
+		err := exec.Execute(tCtx)
+		if err == nil {
+			continue
+		}
+		// If error is due to cancellation, stop the thread
+		if tCtx.Err() != nil {
+			return false
+		}
+		if errors.Is(err, ErrStopThread) {
+			log.Printf("Thread %d stopped: %v", tCtx.ThreadID, err)
+			return false
+		}
+
+		switch policy {
+		case OnSampleErrorStartNextIteration:
+			return true
+		case OnSampleErrorStopThread:
+			log.Printf("Warning: thread %d stopped after error: %v", tCtx.ThreadID, err)
+			return false
+		case OnSampleErrorStopTest:
+			log.Printf("Warning: test stopped by thread %d after error: %v", tCtx.ThreadID, err)
+			if !core.StopTest(tCtx) {
+				stopGroup()
+			}
+			return false
+		}
+	}
+	return true
+}
+
+func validateThreadGroupSchedule(startDelay, duration time.Duration) error {
+	if err := ValidateDuration("Start delay", startDelay); err != nil {
+		return err
+	}
+	return ValidateDuration("Duration", duration)
+}
+
+// startThreadGroupWindow waits out a group's start delay and returns the context the
+// group runs in, carrying fresh thread group variables and cancelled once duration
+// elapses when it is set. ok is false when ctx ends during the delay.
+func startThreadGroupWindow(ctx context.Context, startDelay, duration time.Duration) (context.Context, context.CancelFunc, bool) {
+	if !waitForDuration(ctx, startDelay) {
+		return nil, nil, false
+	}
+	ctx = core.WithGroupVariables(ctx, core.NewGlobals(nil))
+	if duration > 0 {
+		groupCtx, cancel := context.WithTimeout(ctx, duration)
+		return groupCtx, cancel, true
+	}
+	groupCtx, cancel := context.WithCancel(ctx)
+	return groupCtx, cancel, true
+}
+
 func validateThreadGroupHTTPSettings(timeout time.Duration) error {
 	if err := ValidateDuration("HTTP request timeout", timeout); err != nil {
 		return err
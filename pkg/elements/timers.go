@@ -0,0 +1,517 @@
+package elements
+
+import (
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"perfolizer/pkg/core"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+func init() {
+	core.RegisterFactory("ConstantTimer", func(name string, props map[string]interface{}) core.TestElement {
+		return NewConstantTimer(name, durationProp(props, "DelayMS", 300))
+	})
+	core.RegisterFactory("UniformRandomTimer", func(name string, props map[string]interface{}) core.TestElement {
+		return NewUniformRandomTimer(name, durationProp(props, "DelayMS", 0), durationProp(props, "RangeMS", 100))
+	})
+	core.RegisterFactory("GaussianRandomTimer", func(name string, props map[string]interface{}) core.TestElement {
+		return NewGaussianRandomTimer(name, durationProp(props, "DelayMS", 300), durationProp(props, "DeviationMS", 100))
+	})
+	core.RegisterFactory("ConstantThroughputTimer", func(name string, props map[string]interface{}) core.TestElement {
+		t := NewConstantThroughputTimer(name, core.GetFloat(props, "SamplesPerMinute", 60))
+		t.Shared = core.GetBool(props, "Shared", false)
+		return t
+	})
+	core.RegisterFactory("SyncTimer", func(name string, props map[string]interface{}) core.TestElement {
+		return NewSyncTimer(name, core.GetInt(props, "GroupSize", 2), durationProp(props, "TimeoutMS", 0))
+	})
+	core.RegisterFactory("PoissonRandomTimer", func(name string, props map[string]interface{}) core.TestElement {
+		return NewPoissonRandomTimer(name, durationProp(props, "DelayMS", 100), durationProp(props, "LambdaMS", 300))
+	})
+}
+
+func durationProp(props map[string]interface{}, key string, defMS int) time.Duration {
+	return time.Duration(core.GetInt(props, key, defMS)) * time.Millisecond
+}
+
+// Timer is a SampleHook that waits for a delay drawn from its distribution before
+// each sampler in scope. Waiting is cancellable and excluded from transaction time
+// like PauseController.
+type Timer interface {
+	SampleHook
+	NextDelay() time.Duration
+}
+
+func waitTimer(ctx *core.Context, t Timer) error {
+	d := t.NextDelay()
+	if d <= 0 {
+		return nil
+	}
+	return pause(ctx, d)
+}
+
+// --- Constant Timer ---
+
+type ConstantTimer struct {
+	core.BaseElement
+	Delay time.Duration
+}
+
+func NewConstantTimer(name string, delay time.Duration) *ConstantTimer {
+	return &ConstantTimer{BaseElement: core.NewBaseElement(name), Delay: delay}
+}
+
+func (t *ConstantTimer) GetType() string {
+	return "ConstantTimer"
+}
+
+func (t *ConstantTimer) GetProps() map[string]interface{} {
+	return map[string]interface{}{
+		"DelayMS": t.Delay.Milliseconds(),
+	}
+}
+
+func (t *ConstantTimer) Clone() core.TestElement {
+	newT := *t
+	newT.BaseElement = core.NewBaseElement(t.Name())
+	return &newT
+}
+
+func (t *ConstantTimer) Validate() error {
+	return ValidateDuration("Delay", t.Delay)
+}
+
+func (t *ConstantTimer) NextDelay() time.Duration {
+	return t.Delay
+}
+
+func (t *ConstantTimer) BeforeSample(ctx *core.Context) error {
+	return waitTimer(ctx, t)
+}
+
+// --- Uniform Random Timer ---
+
+// UniformRandomTimer waits Delay plus a uniformly distributed value in [0, Range).
+type UniformRandomTimer struct {
+	core.BaseElement
+	Delay time.Duration
+	Range time.Duration
+}
+
+func NewUniformRandomTimer(name string, delay, rng time.Duration) *UniformRandomTimer {
+	return &UniformRandomTimer{BaseElement: core.NewBaseElement(name), Delay: delay, Range: rng}
+}
+
+func (t *UniformRandomTimer) GetType() string {
+	return "UniformRandomTimer"
+}
+
+func (t *UniformRandomTimer) GetProps() map[string]interface{} {
+	return map[string]interface{}{
+		"DelayMS": t.Delay.Milliseconds(),
+		"RangeMS": t.Range.Milliseconds(),
+	}
+}
+
+func (t *UniformRandomTimer) Clone() core.TestElement {
+	newT := *t
+	newT.BaseElement = core.NewBaseElement(t.Name())
+	return &newT
+}
+
+func (t *UniformRandomTimer) Validate() error {
+	if err := ValidateDuration("Delay", t.Delay); err != nil {
+		return err
+	}
+	return ValidateDuration("Range", t.Range)
+}
+
+func (t *UniformRandomTimer) NextDelay() time.Duration {
+	if t.Range <= 0 {
+		return t.Delay
+	}
+	return t.Delay + rand.N(t.Range)
+}
+
+func (t *UniformRandomTimer) BeforeSample(ctx *core.Context) error {
+	return waitTimer(ctx, t)
+}
+
+// --- Gaussian Random Timer ---
+
+// GaussianRandomTimer waits a normally distributed delay with mean Delay and standard
+// deviation Deviation, never less than zero.
+type GaussianRandomTimer struct {
+	core.BaseElement
+	Delay     time.Duration
+	Deviation time.Duration
+}
+
+func NewGaussianRandomTimer(name string, delay, deviation time.Duration) *GaussianRandomTimer {
+	return &GaussianRandomTimer{BaseElement: core.NewBaseElement(name), Delay: delay, Deviation: deviation}
+}
+
+func (t *GaussianRandomTimer) GetType() string {
+	return "GaussianRandomTimer"
+}
+
+func (t *GaussianRandomTimer) GetProps() map[string]interface{} {
+	return map[string]interface{}{
+		"DelayMS":     t.Delay.Milliseconds(),
+		"DeviationMS": t.Deviation.Milliseconds(),
+	}
+}
+
+func (t *GaussianRandomTimer) Clone() core.TestElement {
+	newT := *t
+	newT.BaseElement = core.NewBaseElement(t.Name())
+	return &newT
+}
+
+func (t *GaussianRandomTimer) Validate() error {
+	if err := ValidateDuration("Delay", t.Delay); err != nil {
+		return err
+	}
+	return ValidateDuration("Deviation", t.Deviation)
+}
+
+func (t *GaussianRandomTimer) NextDelay() time.Duration {
+	d := t.Delay + time.Duration(rand.NormFloat64()*float64(t.Deviation))
+	if d < 0 {
+		return 0
+	}
+	return d
+}
+
+func (t *GaussianRandomTimer) BeforeSample(ctx *core.Context) error {
+	return waitTimer(ctx, t)
+}
+
+// --- Poisson Random Timer ---
+
+// PoissonRandomTimer waits Delay plus a Poisson-distributed number of milliseconds
+// with mean Lambda.
+type PoissonRandomTimer struct {
+	core.BaseElement
+	Delay  time.Duration
+	Lambda time.Duration
+}
+
+func NewPoissonRandomTimer(name string, delay, lambda time.Duration) *PoissonRandomTimer {
+	return &PoissonRandomTimer{BaseElement: core.NewBaseElement(name), Delay: delay, Lambda: lambda}
+}
+
+func (t *PoissonRandomTimer) GetType() string {
+	return "PoissonRandomTimer"
+}
+
+func (t *PoissonRandomTimer) GetProps() map[string]interface{} {
+	return map[string]interface{}{
+		"DelayMS":  t.Delay.Milliseconds(),
+		"LambdaMS": t.Lambda.Milliseconds(),
+	}
+}
+
+func (t *PoissonRandomTimer) Clone() core.TestElement {
+	newT := *t
+	newT.BaseElement = core.NewBaseElement(t.Name())
+	return &newT
+}
+
+func (t *PoissonRandomTimer) Validate() error {
+	if err := ValidateDuration("Delay", t.Delay); err != nil {
+		return err
+	}
+	return ValidateDuration("Lambda", t.Lambda)
+}
+
+func (t *PoissonRandomTimer) NextDelay() time.Duration {
+	return t.Delay + time.Duration(poisson(float64(t.Lambda.Milliseconds())))*time.Millisecond
+}
+
+func (t *PoissonRandomTimer) BeforeSample(ctx *core.Context) error {
+	return waitTimer(ctx, t)
+}
+
+// --- Constant Throughput Timer ---
+
+// ConstantThroughputTimer paces the samplers in its scope to SamplesPerMinute, either
+// for each thread on its own or for all threads of the thread group together.
+type ConstantThroughputTimer struct {
+	core.BaseElement
+	SamplesPerMinute float64
+	Shared           bool // Pace all threads together instead of each thread
+	shared           *limiterStore
+}
+
+func NewConstantThroughputTimer(name string, samplesPerMinute float64) *ConstantThroughputTimer {
+	return &ConstantThroughputTimer{
+		BaseElement:      core.NewBaseElement(name),
+		SamplesPerMinute: samplesPerMinute,
+		shared:           newLimiterStore(),
+	}
+}
+
+func (t *ConstantThroughputTimer) GetType() string {
+	return "ConstantThroughputTimer"
+}
+
+func (t *ConstantThroughputTimer) GetProps() map[string]interface{} {
+	return map[string]interface{}{
+		"SamplesPerMinute": t.SamplesPerMinute,
+		"Shared":           t.Shared,
+	}
+}
+
+func (t *ConstantThroughputTimer) Clone() core.TestElement {
+	newT := *t
+	newT.BaseElement = core.NewBaseElement(t.Name())
+	newT.shared = newLimiterStore()
+	return &newT
+}
+
+func (t *ConstantThroughputTimer) Validate() error {
+	return ValidateRPS("Samples per minute", t.SamplesPerMinute)
+}
+
+func (t *ConstantThroughputTimer) BeforeSample(ctx *core.Context) error {
+	targetRPS := t.SamplesPerMinute / 60
+	if targetRPS <= 0 {
+		return nil
+	}
+
+	key := "ThroughputTimer_" + t.ID()
+	var limiter *rate.Limiter
+	if t.Shared && t.shared != nil {
+		limiter = t.shared.getOrCreate(key, targetRPS)
+	} else if existing, ok := ctx.GetVar(key).(*rate.Limiter); ok {
+		limiter = existing
+	} else {
+		limiter = rate.NewLimiter(rate.Limit(targetRPS), 1)
+		ctx.SetStateVar(key, limiter)
+	}
+	if float64(limiter.Limit()) != targetRPS {
+		limiter.SetLimit(rate.Limit(targetRPS))
+	}
+
+	defer trackPause(ctx, time.Now())
+	if err := limiter.Wait(ctx); err != nil {
+		// Wait fails early when the next slot is past the run deadline. The thread is
+		// stopped by then, so pause until it is rather than report a sample error.
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	return nil
+}
+
+// --- Sync Timer ---
+
+// SyncTimer blocks threads arriving before samplers in its scope until GroupSize of
+// them are waiting, then releases them together. A non-zero Timeout releases an
+// incomplete group once the first thread of that group has waited that long, and an
+// incomplete group is also released once every thread left in the thread group is
+// waiting in it. The rendezvous is shared by all threads of the thread group.
+type SyncTimer struct {
+	core.BaseElement
+	GroupSize int
+	Timeout   time.Duration // 0 = wait until the group is complete
+	state     *syncTimerState
+}
+
+type syncTimerState struct {
+	mu    sync.Mutex
+	batch *syncTimerBatch
+}
+
+// syncTimerBatch is one group of threads waiting to be released together.
+type syncTimerBatch struct {
+	arrived  int
+	released bool
+	release  chan struct{}
+	deadline time.Time
+}
+
+func NewSyncTimer(name string, groupSize int, timeout time.Duration) *SyncTimer {
+	return &SyncTimer{
+		BaseElement: core.NewBaseElement(name),
+		GroupSize:   groupSize,
+		Timeout:     timeout,
+		state:       &syncTimerState{},
+	}
+}
+
+func (t *SyncTimer) GetType() string {
+	return "SyncTimer"
+}
+
+func (t *SyncTimer) GetProps() map[string]interface{} {
+	return map[string]interface{}{
+		"GroupSize": t.GroupSize,
+		"TimeoutMS": t.Timeout.Milliseconds(),
+	}
+}
+
+func (t *SyncTimer) Clone() core.TestElement {
+	newT := *t
+	newT.BaseElement = core.NewBaseElement(t.Name())
+	newT.state = &syncTimerState{}
+	return &newT
+}
+
+func (t *SyncTimer) Validate() error {
+	if t.GroupSize < 1 {
+		return fmt.Errorf("Group size must be greater than 0")
+	}
+	return ValidateDuration("Timeout", t.Timeout)
+}
+
+func (t *SyncTimer) BeforeSample(ctx *core.Context) error {
+	if t.GroupSize <= 1 || t.state == nil {
+		return nil
+	}
+	defer trackPause(ctx, time.Now())
+
+	s := t.state
+	s.mu.Lock()
+	b := s.batch
+	if b == nil {
+		b = &syncTimerBatch{release: make(chan struct{})}
+		if t.Timeout > 0 {
+			b.deadline = time.Now().Add(t.Timeout)
+		}
+		s.batch = b
+	}
+	b.arrived++
+	roster, _ := ctx.GetVar("ThreadRoster").(*threadRoster)
+	remaining, rosterChanged := roster.watch()
+	if b.arrived >= t.GroupSize || b.arrived >= remaining {
+		s.releaseLocked(b)
+		s.mu.Unlock()
+		return nil
+	}
+	s.mu.Unlock()
+
+	var timeout <-chan time.Time
+	if !b.deadline.IsZero() {
+		timer := time.NewTimer(time.Until(b.deadline))
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	for {
+		select {
+		case <-b.release:
+			return nil
+		case <-timeout:
+			s.mu.Lock()
+			s.releaseLocked(b)
+			s.mu.Unlock()
+			return nil
+		case <-rosterChanged:
+			// A thread left the group; the rest may all be waiting here already
+			s.mu.Lock()
+			remaining, rosterChanged = roster.watch()
+			if b.arrived >= remaining {
+				s.releaseLocked(b)
+			}
+			s.mu.Unlock()
+		case <-ctx.Done():
+			// Leave the group so the threads still waiting are not released early
+			s.mu.Lock()
+			if !b.released {
+				b.arrived--
+			}
+			s.mu.Unlock()
+			return ctx.Err()
+		}
+	}
+}
+
+// releaseLocked lets every thread waiting in b go and starts a new group.
+func (s *syncTimerState) releaseLocked(b *syncTimerBatch) {
+	if b.released {
+		return
+	}
+	b.released = true
+	close(b.release)
+	if s.batch == b {
+		s.batch = nil
+	}
+}
+
+// threadRoster counts the threads of a thread group run that may still reach a
+// SyncTimer: those running and those yet to start.
+type threadRoster struct {
+	mu        sync.Mutex
+	remaining int
+	changed   chan struct{}
+}
+
+func newThreadRoster(threads int) *threadRoster {
+	return &threadRoster{remaining: threads, changed: make(chan struct{})}
+}
+
+// leave records that a thread finished, or will never start.
+func (r *threadRoster) leave() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.remaining--
+	close(r.changed)
+	r.changed = make(chan struct{})
+}
+
+// watch returns the threads remaining and a channel closed on the next change. A nil
+// roster, for groups whose thread count is open-ended, never changes.
+func (r *threadRoster) watch() (int, <-chan struct{}) {
+	if r == nil {
+		return math.MaxInt, nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.remaining, r.changed
+}
+
+// validateSyncTimers rejects SyncTimers under root that wait for more threads than
+// the thread group runs, which would never be released. field names the group's
+// thread count in the message.
+func validateSyncTimers(root core.TestElement, field string, users int) error {
+	for _, child := range root.GetChildren() {
+		if !child.Enabled() {
+			continue
+		}
+		if timer, ok := child.(*SyncTimer); ok && timer.GroupSize > users {
+			return fmt.Errorf("Sync Timer %q group size must be less than or equal to %s (%d)", timer.Name(), field, users)
+		}
+		if err := validateSyncTimers(child, field, users); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// poisson draws a Poisson-distributed value with the given mean. Small means use
+// Knuth's multiplication method; large means, where exp(-mean) loses precision, use
+// the normal approximation.
+func poisson(mean float64) int64 {
+	if mean <= 0 {
+		return 0
+	}
+	if mean > 500 {
+		v := math.Round(mean + rand.NormFloat64()*math.Sqrt(mean))
+		if v < 0 {
+			return 0
+		}
+		return int64(v)
+	}
+
+	limit := math.Exp(-mean)
+	var k int64
+	for p := rand.Float64(); p > limit; p *= rand.Float64() {
+		k++
+	}
+	return k
+}
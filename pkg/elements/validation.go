@@ -3,6 +3,7 @@ package elements
 import (
 	"fmt"
 	"math"
+	"strings"
 	"time"
 )
 
@@ -20,6 +21,47 @@ func ValidateIterations(value int) error {
 	return nil
 }
 
+func ValidateNonNegative(field string, value int) error {
+	if value < 0 {
+		return fmt.Errorf("%s must be greater than or equal to 0", field)
+	}
+	return nil
+}
+
+func ValidateVariableName(field, value string) error {
+	name := strings.TrimSpace(value)
+	if name == "" {
+		return fmt.Errorf("%s is required", field)
+	}
+	if strings.ContainsAny(name, "${} \t") {
+		return fmt.Errorf("%s must not contain spaces, '$', '{' or '}'", field)
+	}
+	return nil
+}
+
+// ValidateIntFormat checks that value, when set, formats one integer, like ORD-%06d.
+func ValidateIntFormat(field, value string) error {
+	if value == "" {
+		return nil
+	}
+	if out := fmt.Sprintf(value, int64(1)); strings.Contains(out, "%!") {
+		return fmt.Errorf("%s must contain one integer verb such as %%06d", field)
+	}
+	return nil
+}
+
+func ValidateOnSampleError(value string) error {
+	if value == "" {
+		return nil // Treated as OnSampleErrorContinue
+	}
+	for _, policy := range OnSampleErrorPolicies {
+		if value == policy {
+			return nil
+		}
+	}
+	return fmt.Errorf("On sample error must be one of %s", strings.Join(OnSampleErrorPolicies, ", "))
+}
+
 func ValidateRPS(field string, value float64) error {
 	if math.IsNaN(value) || math.IsInf(value, 0) {
 		return fmt.Errorf("%s must be a finite number", field)
@@ -30,6 +72,16 @@ func ValidateRPS(field string, value float64) error {
 	return nil
 }
 
+func ValidateWeight(field string, value float64) error {
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return fmt.Errorf("%s must be a finite number", field)
+	}
+	if value < 0 {
+		return fmt.Errorf("%s must be greater than or equal to 0", field)
+	}
+	return nil
+}
+
 func ValidateDuration(field string, value time.Duration) error {
 	if value < 0 {
 		return fmt.Errorf("%s must be greater than or equal to 0 ms", field)
@@ -293,12 +293,18 @@ func parsePrometheusSnapshot(r io.Reader) (AgentMetricsSnapshot, error) {
 				metric.RPS = value
 			case "perfolizer_avg_response_time_ms":
 				metric.AvgLatency = value
+			case "perfolizer_p95_response_time_ms":
+				metric.P95Latency = value
 			case "perfolizer_errors":
 				metric.Errors = int(value)
 			case "perfolizer_requests_total":
 				metric.TotalRequests = int(value)
 			case "perfolizer_errors_total":
 				metric.TotalErrors = int(value)
+			case "perfolizer_active_users":
+				metric.ActiveUsers = int(value)
+			case "perfolizer_sustainable_rps":
+				metric.SustainableRPS = value
 			}
 			out.Data[sampler] = metric
 		}
@@ -12,6 +12,9 @@ perfolizer_test_running 1
 perfolizer_rps{sampler="Total"} 7
 perfolizer_avg_response_time_ms{sampler="Total"} 507.14
 perfolizer_errors_total{sampler="Total"} 23
+perfolizer_active_users{sampler="Total"} 12
+perfolizer_p95_response_time_ms{sampler="Total"} 910.5
+perfolizer_sustainable_rps{sampler="Total"} 42
 perfolizer_rps{sampler="Home Page - Main URL (5 RPS)"} 5
 perfolizer_avg_response_time_ms{sampler="Home Page - Main URL (5 RPS)"} 430
 perfolizer_errors_total{sampler="Home Page - Main URL (5 RPS)"} 11
@@ -31,6 +34,12 @@ perfolizer_errors_total{sampler="Search Query - random perf check alpha (1 RPS)"
 	if len(snapshot.Data) != 3 {
 		t.Fatalf("expected Total plus 2 sampler series, got %d entries: %#v", len(snapshot.Data), snapshot.Data)
 	}
+	if snapshot.Data["Total"].ActiveUsers != 12 {
+		t.Fatalf("expected 12 active users on Total, got %#v", snapshot.Data["Total"])
+	}
+	if snapshot.Data["Total"].P95Latency != 910.5 || snapshot.Data["Total"].SustainableRPS != 42 {
+		t.Fatalf("expected p95 latency and sustainable rate on Total, got %#v", snapshot.Data["Total"])
+	}
 	if snapshot.Data["Home Page - Main URL (5 RPS)"].RPS != 5 {
 		t.Fatalf("expected home page sampler RPS 5, got %#v", snapshot.Data["Home Page - Main URL (5 RPS)"])
 	}
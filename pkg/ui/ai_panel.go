@@ -222,7 +222,7 @@ func (pa *PerfolizerApp) applyAIResult() {
 
 	switch {
 	case pa.lastAIDraft != nil:
-		root, err := aipkg.ValidateDraft(*pa.lastAIDraft)
+		root, err := aipkg.ValidateDraftInProject(*pa.lastAIDraft, pa.Project, pa.projectDir())
 		if err != nil {
 			dialog.ShowError(err, pa.Window)
 			return
@@ -232,7 +232,7 @@ func (pa *PerfolizerApp) applyAIResult() {
 		successMessage = "Preview added as a new test plan in the current project."
 	case pa.lastAIPatch != nil:
 		current := pa.Project.Plans[planIdx].Root
-		root, err := aipkg.ApplyPatch(current, *pa.lastAIPatch)
+		root, err := aipkg.ApplyPatchInProject(current, *pa.lastAIPatch, pa.Project, pa.projectDir())
 		if err != nil {
 			dialog.ShowError(err, pa.Window)
 			return
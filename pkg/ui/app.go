@@ -32,16 +32,47 @@ const defaultToggleEnabledKey = "Ctrl+E"
 
 const (
 	componentSimpleThreadGroup = "Simple Thread Group"
+	componentSetupGroup        = "Setup Thread Group"
+	componentTeardownGroup     = "Teardown Thread Group"
 	componentRPSThreadGroup    = "RPS Thread Group"
+	componentArrivalRateGroup  = "Arrival Rate Thread Group"
+	componentUltimateGroup     = "Ultimate Thread Group"
+	componentCapacityGroup     = "Capacity Thread Group"
 	componentHTTPSampler       = "HTTP Sampler"
 	componentLoopController    = "Loop Controller"
 	componentIfController      = "If Controller"
+	componentWhileController   = "While Controller"
+	componentTransaction       = "Transaction Controller"
+	componentRandomController  = "Random Controller"
+	componentWeightedSwitch    = "Weighted Switch Controller"
+	componentOnceOnly          = "Once Only Controller"
+	componentInterleave        = "Interleave Controller"
+	componentForEach           = "ForEach Controller"
+	componentParallel          = "Parallel Controller"
+	componentThroughput        = "Throughput Controller"
+	componentRuntime           = "Runtime Controller"
+	componentModule            = "Module Controller"
+	componentCriticalSection   = "Critical Section Controller"
 	componentPauseController   = "Pause Controller"
+	componentConstantTimer     = "Constant Timer"
+	componentUniformTimer      = "Uniform Random Timer"
+	componentGaussianTimer     = "Gaussian Random Timer"
+	componentPoissonTimer      = "Poisson Random Timer"
+	componentThroughputTimer   = "Constant Throughput Timer"
+	componentSyncTimer         = "Synchronizing Timer"
+	componentCSVDataSet        = "CSV Data Set"
+	componentCounter           = "Counter"
+	componentRandomVariable    = "Random Variable"
 )
 
 var threadGroupComponentTypes = []string{
 	componentSimpleThreadGroup,
 	componentRPSThreadGroup,
+	componentArrivalRateGroup,
+	componentUltimateGroup,
+	componentCapacityGroup,
+	componentSetupGroup,
+	componentTeardownGroup,
 }
 
 var samplerComponentTypes = []string{
@@ -51,9 +82,36 @@ var samplerComponentTypes = []string{
 var controllerComponentTypes = []string{
 	componentLoopController,
 	componentIfController,
+	componentWhileController,
+	componentTransaction,
+	componentRandomController,
+	componentWeightedSwitch,
+	componentOnceOnly,
+	componentInterleave,
+	componentForEach,
+	componentParallel,
+	componentThroughput,
+	componentRuntime,
+	componentModule,
+	componentCriticalSection,
 	componentPauseController,
 }
 
+var timerComponentTypes = []string{
+	componentConstantTimer,
+	componentUniformTimer,
+	componentGaussianTimer,
+	componentPoissonTimer,
+	componentThroughputTimer,
+	componentSyncTimer,
+}
+
+var configComponentTypes = []string{
+	componentCSVDataSet,
+	componentCounter,
+	componentRandomVariable,
+}
+
 // treeWithContextMenu wraps the tree so right-click shows Enable/Disable menu for the selected node.
 type treeWithContextMenu struct {
 	widget.BaseWidget
@@ -120,6 +178,7 @@ type PerfolizerApp struct {
 	DebugSearchEntry  *widget.Entry
 
 	Project       *core.Project // Project with multiple test plans
+	ProjectPath   string        // File the project was loaded from or saved to, if any
 	CurrentNodeID string        // Tree node ID: "plan:i" or "plan:i:elementId"
 
 	agentInitError error
@@ -255,10 +314,10 @@ func (pa *PerfolizerApp) Run() {
 
 func (pa *PerfolizerApp) setupTestPlan() {
 	pa.Project = core.NewProject("Project")
-	root := core.NewBaseElement("Test Plan")
+	root := core.NewTestPlan("Test Plan")
 	tg := elements.NewSimpleThreadGroup("Thread Group 1", 1, 1)
 	root.AddChild(tg)
-	pa.Project.AddPlan("Test Plan", &root)
+	pa.Project.AddPlan("Test Plan", root)
 }
 
 func (pa *PerfolizerApp) setupUI() {
@@ -574,6 +633,14 @@ func (pa *PerfolizerApp) getCurrentPlan() core.TestElement {
 	return pa.Project.Plans[idx].Root
 }
 
+// projectDir returns the directory relative file references are resolved against.
+func (pa *PerfolizerApp) projectDir() string {
+	if pa.ProjectPath == "" {
+		return ""
+	}
+	return filepath.Dir(pa.ProjectPath)
+}
+
 // resolveNode returns the plan index and the TestElement for the given tree node ID.
 // For "plan:i" element is nil (plan node). For "plan:i:elId" element is the element.
 func (pa *PerfolizerApp) resolveNode(nodeID string) (planIndex int, element core.TestElement) {
@@ -659,6 +726,13 @@ func (pa *PerfolizerApp) showPlanProperties(planIndex int) {
 		pa.Tree.RefreshItem(fmt.Sprintf("plan:%d", planIndex))
 	}
 	form := widget.NewForm(widget.NewFormItem("Plan name", nameEntry))
+	if plan, ok := pe.Root.(*core.TestPlan); ok {
+		sequentialCheck := widget.NewCheck("Run thread groups one after another", func(checked bool) {
+			plan.SequentialThreadGroups = checked
+		})
+		sequentialCheck.SetChecked(plan.SequentialThreadGroups)
+		form.Append("Thread groups", sequentialCheck)
+	}
 	pa.Content.Objects = []fyne.CanvasObject{container.NewVBox(widget.NewLabel("Test plan"), form)}
 	pa.Content.Refresh()
 }
@@ -777,6 +851,30 @@ func (pa *PerfolizerApp) showProperties(el core.TestElement) {
 		form.Append("Extract Parameters", extractContainer)
 
 	case *elements.SimpleThreadGroup:
+		pa.appendSimpleThreadGroupFields(form, v)
+
+	case *elements.SetupThreadGroup:
+		pa.appendSimpleThreadGroupFields(form, &v.SimpleThreadGroup)
+
+	case *elements.TeardownThreadGroup:
+		pa.appendSimpleThreadGroupFields(form, &v.SimpleThreadGroup)
+
+		timeoutEntry := pa.newValidatedInt64Entry(
+			"Timeout",
+			strconv.FormatInt(v.Timeout.Milliseconds(), 10),
+			func(s string) (int64, error) { return parsePositiveDurationMillisInput("Timeout", s) },
+			func(val int64) { v.Timeout = time.Duration(val) * time.Millisecond },
+		)
+		form.Append("Teardown timeout (ms)", timeoutEntry)
+
+	case *elements.RPSThreadGroup:
+		rpsEntry := pa.newValidatedFloatEntry(
+			"RPS",
+			strconv.FormatFloat(v.RPS, 'f', 2, 64),
+			func(s string) (float64, error) { return parseRPSInput("RPS", s) },
+			func(val float64) { v.RPS = val },
+		)
+
 		usersEntry := pa.newValidatedIntEntry(
 			"Users",
 			strconv.Itoa(v.Users),
@@ -784,11 +882,11 @@ func (pa *PerfolizerApp) showProperties(el core.TestElement) {
 			func(val int) { v.Users = val },
 		)
 
-		iterEntry := pa.newValidatedIntEntry(
-			"Iterations",
-			strconv.Itoa(v.Iterations),
-			parseIterationsInput,
-			func(val int) { v.Iterations = val },
+		gracefulEntry := pa.newValidatedInt64Entry(
+			"Graceful shutdown",
+			strconv.FormatInt(v.GracefulShutdown.Milliseconds(), 10),
+			func(s string) (int64, error) { return parseDurationMillisInput("Graceful shutdown", s) },
+			func(val int64) { v.GracefulShutdown = time.Duration(val) * time.Millisecond },
 		)
 
 		timeoutEntry := pa.newValidatedInt64Entry(
@@ -801,119 +899,129 @@ func (pa *PerfolizerApp) showProperties(el core.TestElement) {
 		keepAliveCheck := widget.NewCheck("", func(checked bool) { v.HTTPKeepAlive = checked })
 		keepAliveCheck.SetChecked(v.HTTPKeepAlive)
 
-		form.Append("Users", usersEntry)
-		form.Append("Iterations (-1 for infinite)", iterEntry)
+		form.Append("Target RPS", rpsEntry)
+		form.Append("Max Users", usersEntry)
+		form.Append("Profile blocks", pa.newProfileBlocksEditor(&v.ProfileBlocks))
+		form.Append("Graceful shutdown (ms)", gracefulEntry)
 		form.Append("HTTP timeout (ms)", timeoutEntry)
 		form.Append("HTTP keep-alive", keepAliveCheck)
+		pa.appendThreadGroupWindowFields(form, &v.StartDelay, &v.Duration)
+		form.Append("On sample error", newOnSampleErrorSelect(&v.OnSampleError))
 
-	case *elements.RPSThreadGroup:
-		rpsEntry := pa.newValidatedFloatEntry(
-			"RPS",
-			strconv.FormatFloat(v.RPS, 'f', 2, 64),
-			func(s string) (float64, error) { return parseRPSInput("RPS", s) },
-			func(val float64) { v.RPS = val },
+	case *elements.UltimateThreadGroup:
+		timeoutEntry := pa.newValidatedInt64Entry(
+			"HTTP request timeout",
+			strconv.FormatInt(v.HTTPRequestTimeout.Milliseconds(), 10),
+			func(s string) (int64, error) { return parsePositiveDurationMillisInput("HTTP request timeout", s) },
+			func(val int64) { v.HTTPRequestTimeout = time.Duration(val) * time.Millisecond },
 		)
 
-		usersEntry := pa.newValidatedIntEntry(
-			"Users",
-			strconv.Itoa(v.Users),
-			parseUsersInput,
-			func(val int) { v.Users = val },
+		keepAliveCheck := widget.NewCheck("", func(checked bool) { v.HTTPKeepAlive = checked })
+		keepAliveCheck.SetChecked(v.HTTPKeepAlive)
+
+		form.Append("Schedule", pa.newUserScheduleEditor(&v.Schedule))
+		form.Append("HTTP timeout (ms)", timeoutEntry)
+		form.Append("HTTP keep-alive", keepAliveCheck)
+		pa.appendThreadGroupWindowFields(form, &v.StartDelay, &v.Duration)
+		form.Append("On sample error", newOnSampleErrorSelect(&v.OnSampleError))
+
+	case *elements.ArrivalRateThreadGroup:
+		rateEntry := pa.newValidatedFloatEntry(
+			"Arrival rate",
+			strconv.FormatFloat(v.Rate, 'f', 2, 64),
+			func(s string) (float64, error) { return parseRPSInput("Arrival rate", s) },
+			func(val float64) { v.Rate = val },
 		)
 
-		if len(v.ProfileBlocks) == 0 {
-			v.ProfileBlocks = []elements.RPSProfileBlock{
-				{RampUp: 0, StepDuration: 60000 * time.Millisecond, ProfilePercent: 100},
-			}
-		}
+		preAllocatedEntry := pa.newValidatedIntEntry(
+			"Pre-allocated users",
+			strconv.Itoa(v.PreAllocatedUsers),
+			func(s string) (int, error) { return parseNonNegativeIntInput("Pre-allocated users", s) },
+			func(val int) { v.PreAllocatedUsers = val },
+		)
 
-		blocksRows := container.NewVBox()
-		var renderBlockRows func()
-		renderBlockRows = func() {
-			pa.clearPropertyValidationErrorsWithPrefix("Profile block ")
-			blocksRows.Objects = nil
-
-			for i := range v.ProfileBlocks {
-				index := i
-
-				rampEntry := pa.newValidatedInt64Entry(
-					fmt.Sprintf("Profile block %d ramp-up", index+1),
-					strconv.FormatInt(v.ProfileBlocks[index].RampUp.Milliseconds(), 10),
-					func(s string) (int64, error) {
-						return parseDurationMillisInput(fmt.Sprintf("Profile block %d ramp-up", index+1), s)
-					},
-					func(val int64) {
-						if index < len(v.ProfileBlocks) {
-							v.ProfileBlocks[index].RampUp = time.Duration(val) * time.Millisecond
-						}
-					},
-				)
-				rampEntry.SetPlaceHolder("Ramp-up ms")
-
-				stepEntry := pa.newValidatedInt64Entry(
-					fmt.Sprintf("Profile block %d step duration", index+1),
-					strconv.FormatInt(v.ProfileBlocks[index].StepDuration.Milliseconds(), 10),
-					func(s string) (int64, error) {
-						return parseDurationMillisInput(fmt.Sprintf("Profile block %d step duration", index+1), s)
-					},
-					func(val int64) {
-						if index < len(v.ProfileBlocks) {
-							v.ProfileBlocks[index].StepDuration = time.Duration(val) * time.Millisecond
-						}
-					},
-				)
-				stepEntry.SetPlaceHolder("Step ms")
-
-				profileEntry := widget.NewEntry()
-				profileEntry.SetPlaceHolder("Profile %")
-				profileEntry.SetText(strconv.FormatFloat(v.ProfileBlocks[index].ProfilePercent, 'f', -1, 64))
-				profileEntry.OnChanged = func(s string) {
-					if val, err := strconv.ParseFloat(strings.TrimSpace(s), 64); err == nil && index < len(v.ProfileBlocks) {
-						v.ProfileBlocks[index].ProfilePercent = val
-					}
-				}
+		maxUsersEntry := pa.newValidatedIntEntry(
+			"Max users",
+			strconv.Itoa(v.MaxUsers),
+			func(s string) (int, error) { return parsePositiveIntInput("Max users", s) },
+			func(val int) { v.MaxUsers = val },
+		)
 
-				removeButton := widget.NewButton("-", func() {
-					if len(v.ProfileBlocks) <= 1 {
-						return
-					}
-					v.ProfileBlocks = append(v.ProfileBlocks[:index], v.ProfileBlocks[index+1:]...)
-					renderBlockRows()
-				})
-				if len(v.ProfileBlocks) <= 1 {
-					removeButton.Disable()
-				}
+		gracefulEntry := pa.newValidatedInt64Entry(
+			"Graceful shutdown",
+			strconv.FormatInt(v.GracefulShutdown.Milliseconds(), 10),
+			func(s string) (int64, error) { return parseDurationMillisInput("Graceful shutdown", s) },
+			func(val int64) { v.GracefulShutdown = time.Duration(val) * time.Millisecond },
+		)
 
-				blocksRows.Add(container.NewGridWithColumns(4, rampEntry, stepEntry, profileEntry, removeButton))
-			}
+		timeoutEntry := pa.newValidatedInt64Entry(
+			"HTTP request timeout",
+			strconv.FormatInt(v.HTTPRequestTimeout.Milliseconds(), 10),
+			func(s string) (int64, error) { return parsePositiveDurationMillisInput("HTTP request timeout", s) },
+			func(val int64) { v.HTTPRequestTimeout = time.Duration(val) * time.Millisecond },
+		)
 
-			blocksRows.Refresh()
-		}
+		keepAliveCheck := widget.NewCheck("", func(checked bool) { v.HTTPKeepAlive = checked })
+		keepAliveCheck.SetChecked(v.HTTPKeepAlive)
 
-		renderBlockRows()
+		form.Append("Arrivals per second", rateEntry)
+		form.Append("Pre-allocated users", preAllocatedEntry)
+		form.Append("Max users", maxUsersEntry)
+		form.Append("Profile blocks", pa.newProfileBlocksEditor(&v.ProfileBlocks))
+		form.Append("Graceful shutdown (ms)", gracefulEntry)
+		form.Append("HTTP timeout (ms)", timeoutEntry)
+		form.Append("HTTP keep-alive", keepAliveCheck)
+		pa.appendThreadGroupWindowFields(form, &v.StartDelay, &v.Duration)
+		form.Append("On sample error", newOnSampleErrorSelect(&v.OnSampleError))
+
+	case *elements.CapacityThreadGroup:
+		startEntry := pa.newValidatedFloatEntry(
+			"Start RPS",
+			strconv.FormatFloat(v.StartRPS, 'f', 2, 64),
+			func(s string) (float64, error) { return parsePositiveRPSInput("Start RPS", s) },
+			func(val float64) { v.StartRPS = val },
+		)
 
-		blocksHeaders := container.NewGridWithColumns(
-			4,
-			widget.NewLabel("Ramp-up (ms)"),
-			widget.NewLabel("Step duration (ms)"),
-			widget.NewLabel("Profile percent"),
-			widget.NewLabel(""),
+		stepEntry := pa.newValidatedFloatEntry(
+			"Step RPS",
+			strconv.FormatFloat(v.StepRPS, 'f', 2, 64),
+			func(s string) (float64, error) { return parsePositiveRPSInput("Step RPS", s) },
+			func(val float64) { v.StepRPS = val },
 		)
 
-		addBlockButton := widget.NewButton("Add block", func() {
-			v.ProfileBlocks = append(v.ProfileBlocks, elements.RPSProfileBlock{
-				RampUp:         0,
-				StepDuration:   60000 * time.Millisecond,
-				ProfilePercent: 100,
-			})
-			renderBlockRows()
-		})
+		maxEntry := pa.newValidatedFloatEntry(
+			"Max RPS",
+			strconv.FormatFloat(v.MaxRPS, 'f', 2, 64),
+			func(s string) (float64, error) { return parsePositiveRPSInput("Max RPS", s) },
+			func(val float64) { v.MaxRPS = val },
+		)
 
-		gracefulEntry := pa.newValidatedInt64Entry(
-			"Graceful shutdown",
-			strconv.FormatInt(v.GracefulShutdown.Milliseconds(), 10),
-			func(s string) (int64, error) { return parseDurationMillisInput("Graceful shutdown", s) },
-			func(val int64) { v.GracefulShutdown = time.Duration(val) * time.Millisecond },
+		stepDurationEntry := pa.newValidatedInt64Entry(
+			"Step duration",
+			strconv.FormatInt(v.StepDuration.Milliseconds(), 10),
+			func(s string) (int64, error) { return parsePositiveDurationMillisInput("Step duration", s) },
+			func(val int64) { v.StepDuration = time.Duration(val) * time.Millisecond },
+		)
+
+		usersEntry := pa.newValidatedIntEntry(
+			"Users",
+			strconv.Itoa(v.Users),
+			parseUsersInput,
+			func(val int) { v.Users = val },
+		)
+
+		p95Entry := pa.newValidatedInt64Entry(
+			"Max p95 latency",
+			strconv.FormatInt(v.MaxP95Latency.Milliseconds(), 10),
+			func(s string) (int64, error) { return parsePositiveDurationMillisInput("Max p95 latency", s) },
+			func(val int64) { v.MaxP95Latency = time.Duration(val) * time.Millisecond },
+		)
+
+		errorPercentEntry := pa.newValidatedFloatEntry(
+			"Max error percent",
+			strconv.FormatFloat(v.MaxErrorPercent, 'f', 2, 64),
+			parsePercentInput,
+			func(val float64) { v.MaxErrorPercent = val },
 		)
 
 		timeoutEntry := pa.newValidatedInt64Entry(
@@ -926,12 +1034,254 @@ func (pa *PerfolizerApp) showProperties(el core.TestElement) {
 		keepAliveCheck := widget.NewCheck("", func(checked bool) { v.HTTPKeepAlive = checked })
 		keepAliveCheck.SetChecked(v.HTTPKeepAlive)
 
-		form.Append("Target RPS", rpsEntry)
+		form.Append("Start RPS", startEntry)
+		form.Append("Step RPS", stepEntry)
+		form.Append("Max RPS", maxEntry)
+		form.Append("Step duration (ms)", stepDurationEntry)
 		form.Append("Max Users", usersEntry)
-		form.Append("Profile blocks", container.NewVBox(blocksHeaders, blocksRows, addBlockButton))
-		form.Append("Graceful shutdown (ms)", gracefulEntry)
+		form.Append("SLO p95 latency (ms)", p95Entry)
+		form.Append("SLO error rate (%)", errorPercentEntry)
 		form.Append("HTTP timeout (ms)", timeoutEntry)
 		form.Append("HTTP keep-alive", keepAliveCheck)
+		pa.appendThreadGroupWindowFields(form, &v.StartDelay, &v.Duration)
+		form.Append("On sample error", newOnSampleErrorSelect(&v.OnSampleError))
+
+	case *elements.IfController:
+		conditionEntry := pa.newValidatedTextEntry(
+			"Condition",
+			v.Expression,
+			validateConditionInput,
+			func(s string) { v.Expression = s },
+		)
+		conditionEntry.SetPlaceHolder(`${status} == "200" && lastSampleOk`)
+
+		form.Append("Condition", conditionEntry)
+		form.Append("", widget.NewLabel("Operators: == != < <= > >= =~ !~ && || !  Identifiers: lastSampleOk, lastSampleCode, lastSampleMs"))
+
+	case *elements.WhileController:
+		conditionEntry := pa.newValidatedTextEntry(
+			"Condition",
+			v.Expression,
+			validateConditionInput,
+			func(s string) { v.Expression = s },
+		)
+		conditionEntry.SetPlaceHolder(`${jobStatus} != "DONE"`)
+
+		maxIterEntry := pa.newValidatedIntEntry(
+			"Max iterations",
+			strconv.Itoa(v.MaxIterations),
+			func(s string) (int, error) { return parseNonNegativeIntInput("Max iterations", s) },
+			func(val int) { v.MaxIterations = val },
+		)
+
+		timeoutEntry := pa.newValidatedInt64Entry(
+			"Timeout",
+			strconv.FormatInt(v.Timeout.Milliseconds(), 10),
+			func(s string) (int64, error) { return parseDurationMillisInput("Timeout", s) },
+			func(val int64) { v.Timeout = time.Duration(val) * time.Millisecond },
+		)
+
+		form.Append("Condition", conditionEntry)
+		form.Append("Max iterations (0 = unlimited)", maxIterEntry)
+		form.Append("Timeout (ms, 0 = none)", timeoutEntry)
+
+	case *elements.TransactionController:
+		includePausesCheck := widget.NewCheck("", func(checked bool) { v.IncludePauses = checked })
+		includePausesCheck.SetChecked(v.IncludePauses)
+
+		reportChildrenCheck := widget.NewCheck("", func(checked bool) { v.ReportChildren = checked })
+		reportChildrenCheck.SetChecked(v.ReportChildren)
+
+		form.Append("Include pauses in timing", includePausesCheck)
+		form.Append("Also report child samples", reportChildrenCheck)
+
+	case *elements.WeightedSwitchController:
+		weightsRows := container.NewVBox(container.NewGridWithColumns(2,
+			widget.NewLabel("Child"),
+			widget.NewLabel("Weight"),
+		))
+		for _, child := range v.GetChildren() {
+			childID := child.ID()
+			field := fmt.Sprintf("Weight of %q", child.Name())
+			weightEntry := pa.newValidatedFloatEntry(
+				field,
+				strconv.FormatFloat(v.WeightOf(childID), 'f', -1, 64),
+				func(s string) (float64, error) { return parseWeightInput(field, s) },
+				func(val float64) { v.SetWeight(childID, val) },
+			)
+			weightsRows.Add(container.NewGridWithColumns(2, widget.NewLabel(child.Name()), weightEntry))
+		}
+		if len(v.GetChildren()) == 0 {
+			weightsRows.Add(widget.NewLabel("Add child elements to assign weights"))
+		}
+
+		form.Append("Weights", weightsRows)
+
+	case *elements.InterleaveController:
+		sharedCheck := widget.NewCheck("", func(checked bool) { v.Shared = checked })
+		sharedCheck.SetChecked(v.Shared)
+
+		form.Append("Rotate across all threads", sharedCheck)
+
+	case *elements.ForEachController:
+		sourceSelect := widget.NewSelect([]string{elements.ForEachSourcePrefix, elements.ForEachSourceJSON}, func(s string) {
+			v.Source = s
+		})
+		sourceSelect.SetSelected(v.Source)
+
+		inputEntry := pa.newValidatedTextEntry(
+			"Input variable",
+			v.InputVariable,
+			func(s string) error { return elements.ValidateVariableName("Input variable", s) },
+			func(s string) { v.InputVariable = s },
+		)
+		inputEntry.SetPlaceHolder("userId")
+
+		outputEntry := pa.newValidatedTextEntry(
+			"Output variable",
+			v.OutputVariable,
+			func(s string) error { return elements.ValidateVariableName("Output variable", s) },
+			func(s string) { v.OutputVariable = s },
+		)
+		outputEntry.SetPlaceHolder("currentUserId")
+
+		indexEntry := widget.NewEntry()
+		indexEntry.SetText(v.IndexVariable)
+		indexEntry.SetPlaceHolder("<output>_index")
+		indexEntry.OnChanged = func(s string) { v.IndexVariable = s }
+
+		form.Append("Source", sourceSelect)
+		form.Append("Input variable", inputEntry)
+		form.Append("Output variable", outputEntry)
+		form.Append("Index variable", indexEntry)
+		form.Append("", widget.NewLabel("Prefix reads input_1..input_N; JSON reads an array stored in the input variable."))
+
+	case *elements.ParallelController:
+		maxConcurrencyEntry := pa.newValidatedIntEntry(
+			"Max concurrency",
+			strconv.Itoa(v.MaxConcurrency),
+			func(s string) (int, error) { return parseNonNegativeIntInput("Max concurrency", s) },
+			func(val int) { v.MaxConcurrency = val },
+		)
+
+		reportTimingCheck := widget.NewCheck("", func(checked bool) { v.ReportTiming = checked })
+		reportTimingCheck.SetChecked(v.ReportTiming)
+
+		form.Append("Max concurrency (0 = all children)", maxConcurrencyEntry)
+		form.Append("Report combined timing", reportTimingCheck)
+
+	case *elements.ThroughputController:
+		percentEntry := pa.newValidatedFloatEntry(
+			"Percent",
+			strconv.FormatFloat(v.Percent, 'f', -1, 64),
+			parsePercentInput,
+			func(val float64) { v.Percent = val },
+		)
+
+		executionsEntry := pa.newValidatedIntEntry(
+			"Executions",
+			strconv.Itoa(v.Executions),
+			func(s string) (int, error) { return parseNonNegativeIntInput("Executions", s) },
+			func(val int) { v.Executions = val },
+		)
+
+		syncModeFields := func() {
+			if v.Mode == elements.ThroughputModeTotal {
+				percentEntry.Disable()
+				executionsEntry.Enable()
+			} else {
+				percentEntry.Enable()
+				executionsEntry.Disable()
+			}
+		}
+		modeSelect := widget.NewSelect([]string{elements.ThroughputModePercent, elements.ThroughputModeTotal}, func(s string) {
+			v.Mode = s
+			syncModeFields()
+		})
+		modeSelect.SetSelected(v.Mode)
+		syncModeFields()
+
+		sharedCheck := widget.NewCheck("", func(checked bool) { v.Shared = checked })
+		sharedCheck.SetChecked(v.Shared)
+
+		form.Append("Mode", modeSelect)
+		form.Append("Percent of passes", percentEntry)
+		form.Append("Total executions", executionsEntry)
+		form.Append("Count across all threads", sharedCheck)
+
+	case *elements.RuntimeController:
+		durEntry := pa.newValidatedInt64Entry(
+			"Duration",
+			strconv.FormatInt(v.Duration.Milliseconds(), 10),
+			func(s string) (int64, error) { return parsePositiveDurationMillisInput("Duration", s) },
+			func(val int64) { v.Duration = time.Duration(val) * time.Millisecond },
+		)
+
+		form.Append("Duration (ms)", durEntry)
+
+	case *elements.CriticalSectionController:
+		lockEntry := widget.NewEntry()
+		lockEntry.SetText(v.LockName)
+		lockEntry.OnChanged = func(s string) { v.LockName = s }
+
+		reportCheck := widget.NewCheck("", func(b bool) { v.ReportWaitTime = b })
+		reportCheck.SetChecked(v.ReportWaitTime)
+
+		form.Append("Lock name", lockEntry)
+		form.Append("Report lock wait time", reportCheck)
+
+	case *elements.ModuleController:
+		fileEntry := widget.NewEntry()
+		fileEntry.SetText(v.File)
+		fileEntry.SetPlaceHolder("Leave empty to use this project")
+
+		planEntry := widget.NewSelectEntry(pa.projectPlanNames())
+		planEntry.SetText(v.PlanName)
+
+		elementIDs := map[string]string{}
+		elementSelect := widget.NewSelect(nil, func(label string) {
+			// Element IDs of external files cannot be listed; keep the persisted one
+			if strings.TrimSpace(v.File) != "" {
+				return
+			}
+			v.ElementID = elementIDs[label]
+		})
+		refreshElements := func() {
+			labels, ids := pa.moduleTargetOptions(v.PlanName)
+			elementIDs = ids
+			elementSelect.Options = labels
+			selected := labels[0]
+			for label, id := range ids {
+				if id == v.ElementID {
+					selected = label
+				}
+			}
+			elementSelect.SetSelected(selected)
+			if strings.TrimSpace(v.File) != "" {
+				elementSelect.Disable()
+			} else {
+				elementSelect.Enable()
+			}
+		}
+
+		fileEntry.OnChanged = func(s string) {
+			v.File = s
+			refreshElements()
+		}
+		planEntry.OnChanged = func(s string) {
+			if s == v.PlanName {
+				return
+			}
+			v.PlanName = s
+			v.ElementID = ""
+			refreshElements()
+		}
+		refreshElements()
+
+		form.Append("External file", fileEntry)
+		form.Append("Plan", planEntry)
+		form.Append("Element", elementSelect)
+		form.Append("", widget.NewLabel("The referenced steps are inlined when the plan is sent to the agent."))
 
 	case *elements.PauseController:
 		durEntry := pa.newValidatedInt64Entry(
@@ -942,6 +1292,168 @@ func (pa *PerfolizerApp) showProperties(el core.TestElement) {
 		)
 
 		form.Append("Duration (ms)", durEntry)
+
+	case *elements.ConstantTimer:
+		form.Append("Delay (ms)", pa.newDurationMillisEntry("Delay", &v.Delay))
+
+	case *elements.UniformRandomTimer:
+		form.Append("Delay offset (ms)", pa.newDurationMillisEntry("Delay", &v.Delay))
+		form.Append("Random range (ms)", pa.newDurationMillisEntry("Range", &v.Range))
+
+	case *elements.GaussianRandomTimer:
+		form.Append("Delay offset (ms)", pa.newDurationMillisEntry("Delay", &v.Delay))
+		form.Append("Deviation (ms)", pa.newDurationMillisEntry("Deviation", &v.Deviation))
+
+	case *elements.PoissonRandomTimer:
+		form.Append("Delay offset (ms)", pa.newDurationMillisEntry("Delay", &v.Delay))
+		form.Append("Lambda (ms)", pa.newDurationMillisEntry("Lambda", &v.Lambda))
+
+	case *elements.ConstantThroughputTimer:
+		rateEntry := pa.newValidatedFloatEntry(
+			"Samples per minute",
+			strconv.FormatFloat(v.SamplesPerMinute, 'f', -1, 64),
+			func(s string) (float64, error) { return parseRPSInput("Samples per minute", s) },
+			func(val float64) { v.SamplesPerMinute = val },
+		)
+
+		sharedCheck := widget.NewCheck("", func(checked bool) { v.Shared = checked })
+		sharedCheck.SetChecked(v.Shared)
+
+		form.Append("Target samples per minute", rateEntry)
+		form.Append("Pace all threads together", sharedCheck)
+
+	case *elements.SyncTimer:
+		groupEntry := pa.newValidatedIntEntry(
+			"Group size",
+			strconv.Itoa(v.GroupSize),
+			func(s string) (int, error) { return parsePositiveIntInput("Group size", s) },
+			func(val int) { v.GroupSize = val },
+		)
+
+		form.Append("Users to group", groupEntry)
+		form.Append("Timeout (ms, 0 = none)", pa.newDurationMillisEntry("Timeout", &v.Timeout))
+
+	case *elements.CSVDataSet:
+		fileEntry := pa.newValidatedTextEntry(
+			"Filename",
+			v.Filename,
+			func(s string) error {
+				if strings.TrimSpace(s) == "" {
+					return fmt.Errorf("Filename is required")
+				}
+				return nil
+			},
+			func(s string) { v.Filename = s },
+		)
+		fileEntry.SetPlaceHolder("data/users.csv")
+
+		delimiterEntry := widget.NewEntry()
+		delimiterEntry.SetText(v.Delimiter)
+		delimiterEntry.SetPlaceHolder(`, or \t`)
+		delimiterEntry.OnChanged = func(s string) { v.Delimiter = s }
+
+		namesEntry := widget.NewEntry()
+		namesEntry.SetText(v.VariableNames)
+		namesEntry.SetPlaceHolder("username,password")
+		namesEntry.OnChanged = func(s string) { v.VariableNames = s }
+
+		headerCheck := widget.NewCheck("", func(checked bool) { v.HeaderRow = checked })
+		headerCheck.SetChecked(v.HeaderRow)
+
+		sharingSelect := widget.NewSelect(elements.CSVSharingModes, func(s string) { v.Sharing = s })
+		sharingSelect.SetSelected(v.Sharing)
+
+		recycleCheck := widget.NewCheck("", func(checked bool) { v.RecycleOnEOF = checked })
+		recycleCheck.SetChecked(v.RecycleOnEOF)
+
+		stopCheck := widget.NewCheck("", func(checked bool) { v.StopThreadOnEOF = checked })
+		stopCheck.SetChecked(v.StopThreadOnEOF)
+
+		form.Append("Filename (relative to project)", fileEntry)
+		form.Append("Delimiter", delimiterEntry)
+		form.Append("Variable names", namesEntry)
+		form.Append("First row is a header", headerCheck)
+		form.Append("Sharing", sharingSelect)
+		form.Append("Recycle on end of file", recycleCheck)
+		form.Append("Stop thread on end of file", stopCheck)
+		form.Append("", widget.NewLabel("Reads the next row each time it runs; empty names take the header row."))
+
+	case *elements.Counter:
+		form.Append("Variable name", pa.newValidatedTextEntry(
+			"Variable name",
+			v.VariableName,
+			func(s string) error { return elements.ValidateVariableName("Variable name", s) },
+			func(s string) { v.VariableName = s },
+		))
+		form.Append("Start", pa.newValidatedInt64Entry(
+			"Start",
+			strconv.FormatInt(v.Start, 10),
+			func(s string) (int64, error) { return parseRequiredInt64("Start", s) },
+			func(val int64) { v.Start = val },
+		))
+		form.Append("Increment", pa.newValidatedInt64Entry(
+			"Increment",
+			strconv.FormatInt(v.Increment, 10),
+			func(s string) (int64, error) { return parseRequiredInt64("Increment", s) },
+			func(val int64) { v.Increment = val },
+		))
+		form.Append("Max (0 = none)", pa.newValidatedInt64Entry(
+			"Max",
+			strconv.FormatInt(v.Max, 10),
+			func(s string) (int64, error) { return parseRequiredInt64("Max", s) },
+			func(val int64) { v.Max = val },
+		))
+		formatEntry := pa.newValidatedTextEntry(
+			"Format",
+			v.Format,
+			func(s string) error { return elements.ValidateIntFormat("Format", s) },
+			func(s string) { v.Format = s },
+		)
+		formatEntry.SetPlaceHolder("ORD-%06d")
+		form.Append("Format", formatEntry)
+
+		perUserCheck := widget.NewCheck("", func(checked bool) { v.PerUser = checked })
+		perUserCheck.SetChecked(v.PerUser)
+		resetCheck := widget.NewCheck("", func(checked bool) { v.ResetPerIteration = checked })
+		resetCheck.SetChecked(v.ResetPerIteration)
+
+		form.Append("Count per user", perUserCheck)
+		form.Append("Reset each iteration (per user)", resetCheck)
+
+	case *elements.RandomVariable:
+		form.Append("Variable name", pa.newValidatedTextEntry(
+			"Variable name",
+			v.VariableName,
+			func(s string) error { return elements.ValidateVariableName("Variable name", s) },
+			func(s string) { v.VariableName = s },
+		))
+		form.Append("Min", pa.newValidatedInt64Entry(
+			"Min",
+			strconv.FormatInt(v.Min, 10),
+			func(s string) (int64, error) { return parseRequiredInt64("Min", s) },
+			func(val int64) { v.Min = val },
+		))
+		form.Append("Max", pa.newValidatedInt64Entry(
+			"Max",
+			strconv.FormatInt(v.Max, 10),
+			func(s string) (int64, error) { return parseRequiredInt64("Max", s) },
+			func(val int64) { v.Max = val },
+		))
+		formatEntry := pa.newValidatedTextEntry(
+			"Format",
+			v.Format,
+			func(s string) error { return elements.ValidateIntFormat("Format", s) },
+			func(s string) { v.Format = s },
+		)
+		formatEntry.SetPlaceHolder("%04d")
+		form.Append("Format", formatEntry)
+		form.Append("Seed (0 = random)", pa.newValidatedInt64Entry(
+			"Seed",
+			strconv.FormatUint(v.Seed, 10),
+			func(s string) (int64, error) { return parseNonNegativeInt64Input("Seed", s) },
+			func(val int64) { v.Seed = uint64(val) },
+		))
+		form.Append("", widget.NewLabel("Each thread draws from its own generator, seeded from Seed and the thread number."))
 	}
 
 	pa.Content.Objects = []fyne.CanvasObject{container.NewVBox(widget.NewLabel("Properties"), form)}
@@ -961,7 +1473,9 @@ func (pa *PerfolizerApp) saveTestPlan() {
 		path := uriPath(writer.URI())
 		if err := core.SaveProject(path, pa.Project); err != nil {
 			dialog.ShowError(err, pa.Window)
+			return
 		}
+		pa.ProjectPath = path
 	}, pa.Window)
 }
 
@@ -988,6 +1502,7 @@ func (pa *PerfolizerApp) loadTestPlan() {
 			proj.AddPlan(plan.Name(), plan)
 		}
 		pa.Project = proj
+		pa.ProjectPath = path
 		pa.Tree.RefreshItem("")
 		pa.CurrentNodeID = ""
 		pa.Content.Objects = nil
@@ -1027,21 +1542,32 @@ func (pa *PerfolizerApp) runTest() {
 	// Inject parameters into ThreadGroups (runtime binding)
 	if planIdx := pa.getCurrentPlanIndex(); planIdx >= 0 && planIdx < pa.Project.PlanCount() {
 		params := pa.Project.Plans[planIdx].Parameters
-		// Helper to inject into children (non-recursive for now as ThreadGroups are usually top-level)
+		// Thread groups are top-level children of the plan
 		for _, child := range plan.GetChildren() {
-			if tg, ok := child.(*elements.SimpleThreadGroup); ok {
-				tg.Parameters = params
-			} else if tg, ok := child.(*elements.RPSThreadGroup); ok {
-				tg.Parameters = params
+			if tg, ok := child.(core.ParameterizedThreadGroup); ok {
+				tg.SetParameters(params)
 			}
 		}
 	}
 
+	// Inline module references so the agent receives a self-contained plan
+	plan, err = elements.ResolveModules(plan, pa.Project, pa.projectDir())
+	if err != nil {
+		dialog.ShowError(err, pa.Window)
+		return
+	}
+
 	if err := core.ValidateTestPlan(plan); err != nil {
 		dialog.ShowError(err, pa.Window)
 		return
 	}
 
+	// The agent may run on another machine, so data files travel with the plan
+	if err := elements.BundleDataFiles(plan, pa.projectDir()); err != nil {
+		dialog.ShowError(err, pa.Window)
+		return
+	}
+
 	if err := client.RunTest(plan); err != nil {
 		pa.markAgentUnavailable(agentID, err)
 		dialog.ShowError(err, pa.Window)
@@ -1082,7 +1608,13 @@ func (pa *PerfolizerApp) runDebugTest() {
 
 	samplers := make([]*elements.HttpSampler, 0)
 	if plan := pa.getCurrentPlan(); plan != nil {
-		pa.collectHTTPSamplers(plan, &samplers)
+		// Requests of referenced modules are debugged in place, as a run executes them
+		resolved, err := elements.ResolveModules(plan, pa.Project, pa.projectDir())
+		if err != nil {
+			dialog.ShowError(err, pa.Window)
+			return
+		}
+		pa.collectHTTPSamplers(resolved, &samplers)
 	}
 	if len(samplers) == 0 {
 		dialog.ShowInformation("Debug run", "No HTTP samplers found in the test plan.", pa.Window)
@@ -1354,10 +1886,10 @@ func (pa *PerfolizerApp) findParent(root core.TestElement, childID string) core.
 }
 
 func (pa *PerfolizerApp) addPlan() {
-	root := core.NewBaseElement("Test Plan")
+	root := core.NewTestPlan("Test Plan")
 	tg := elements.NewSimpleThreadGroup("Thread Group 1", 1, 1)
 	root.AddChild(tg)
-	pa.Project.AddPlan("Test Plan", &root)
+	pa.Project.AddPlan("Test Plan", root)
 	pa.Tree.RefreshItem("")
 	pa.Tree.OpenBranch(fmt.Sprintf("plan:%d", pa.Project.PlanCount()-1))
 	pa.clearAIResult()
@@ -1370,22 +1902,410 @@ func (pa *PerfolizerApp) elementTypeName(el core.TestElement) string {
 		return componentSimpleThreadGroup
 	case *elements.RPSThreadGroup:
 		return componentRPSThreadGroup
+	case *elements.ArrivalRateThreadGroup:
+		return componentArrivalRateGroup
+	case *elements.UltimateThreadGroup:
+		return componentUltimateGroup
+	case *elements.CapacityThreadGroup:
+		return componentCapacityGroup
+	case *elements.SetupThreadGroup:
+		return componentSetupGroup
+	case *elements.TeardownThreadGroup:
+		return componentTeardownGroup
 	case *elements.HttpSampler:
 		return componentHTTPSampler
 	case *elements.LoopController:
 		return componentLoopController
 	case *elements.IfController:
 		return componentIfController
+	case *elements.WhileController:
+		return componentWhileController
+	case *elements.TransactionController:
+		return componentTransaction
+	case *elements.RandomController:
+		return componentRandomController
+	case *elements.WeightedSwitchController:
+		return componentWeightedSwitch
+	case *elements.OnceOnlyController:
+		return componentOnceOnly
+	case *elements.InterleaveController:
+		return componentInterleave
+	case *elements.ForEachController:
+		return componentForEach
+	case *elements.ParallelController:
+		return componentParallel
+	case *elements.ThroughputController:
+		return componentThroughput
+	case *elements.RuntimeController:
+		return componentRuntime
+	case *elements.ModuleController:
+		return componentModule
+	case *elements.CriticalSectionController:
+		return componentCriticalSection
 	case *elements.PauseController:
 		return componentPauseController
+	case *elements.ConstantTimer:
+		return componentConstantTimer
+	case *elements.UniformRandomTimer:
+		return componentUniformTimer
+	case *elements.GaussianRandomTimer:
+		return componentGaussianTimer
+	case *elements.PoissonRandomTimer:
+		return componentPoissonTimer
+	case *elements.ConstantThroughputTimer:
+		return componentThroughputTimer
+	case *elements.SyncTimer:
+		return componentSyncTimer
+	case *elements.CSVDataSet:
+		return componentCSVDataSet
+	case *elements.Counter:
+		return componentCounter
+	case *elements.RandomVariable:
+		return componentRandomVariable
 	default:
 		return "Test Plan"
 	}
 }
 
+// appendSimpleThreadGroupFields adds the fields shared by simple, setup and teardown thread groups.
+func (pa *PerfolizerApp) appendSimpleThreadGroupFields(form *widget.Form, v *elements.SimpleThreadGroup) {
+	usersEntry := pa.newValidatedIntEntry(
+		"Users",
+		strconv.Itoa(v.Users),
+		parseUsersInput,
+		func(val int) { v.Users = val },
+	)
+
+	iterEntry := pa.newValidatedIntEntry(
+		"Iterations",
+		strconv.Itoa(v.Iterations),
+		parseIterationsInput,
+		func(val int) { v.Iterations = val },
+	)
+
+	rampUpEntry := pa.newValidatedInt64Entry(
+		"Ramp-up",
+		strconv.FormatInt(v.RampUp.Milliseconds(), 10),
+		func(s string) (int64, error) { return parseDurationMillisInput("Ramp-up", s) },
+		func(val int64) { v.RampUp = time.Duration(val) * time.Millisecond },
+	)
+
+	holdEntry := pa.newValidatedInt64Entry(
+		"Hold",
+		strconv.FormatInt(v.Hold.Milliseconds(), 10),
+		func(s string) (int64, error) { return parseDurationMillisInput("Hold", s) },
+		func(val int64) { v.Hold = time.Duration(val) * time.Millisecond },
+	)
+
+	rampDownEntry := pa.newValidatedInt64Entry(
+		"Ramp-down",
+		strconv.FormatInt(v.RampDown.Milliseconds(), 10),
+		func(s string) (int64, error) { return parseDurationMillisInput("Ramp-down", s) },
+		func(val int64) { v.RampDown = time.Duration(val) * time.Millisecond },
+	)
+
+	timeoutEntry := pa.newValidatedInt64Entry(
+		"HTTP request timeout",
+		strconv.FormatInt(v.HTTPRequestTimeout.Milliseconds(), 10),
+		func(s string) (int64, error) { return parsePositiveDurationMillisInput("HTTP request timeout", s) },
+		func(val int64) { v.HTTPRequestTimeout = time.Duration(val) * time.Millisecond },
+	)
+
+	keepAliveCheck := widget.NewCheck("", func(checked bool) { v.HTTPKeepAlive = checked })
+	keepAliveCheck.SetChecked(v.HTTPKeepAlive)
+
+	form.Append("Users", usersEntry)
+	form.Append("Iterations (-1 for infinite)", iterEntry)
+	form.Append("Ramp-up (ms)", rampUpEntry)
+	form.Append("Hold (ms, 0 = by iterations)", holdEntry)
+	form.Append("Ramp-down (ms)", rampDownEntry)
+	form.Append("HTTP timeout (ms)", timeoutEntry)
+	form.Append("HTTP keep-alive", keepAliveCheck)
+	pa.appendThreadGroupWindowFields(form, &v.StartDelay, &v.Duration)
+	form.Append("On sample error", newOnSampleErrorSelect(&v.OnSampleError))
+}
+
+// appendThreadGroupWindowFields adds the start delay and duration every thread group has.
+func (pa *PerfolizerApp) appendThreadGroupWindowFields(form *widget.Form, startDelay, duration *time.Duration) {
+	startDelayEntry := pa.newValidatedInt64Entry(
+		"Start delay",
+		strconv.FormatInt(startDelay.Milliseconds(), 10),
+		func(s string) (int64, error) { return parseDurationMillisInput("Start delay", s) },
+		func(val int64) { *startDelay = time.Duration(val) * time.Millisecond },
+	)
+
+	durationEntry := pa.newValidatedInt64Entry(
+		"Duration",
+		strconv.FormatInt(duration.Milliseconds(), 10),
+		func(s string) (int64, error) { return parseDurationMillisInput("Duration", s) },
+		func(val int64) { *duration = time.Duration(val) * time.Millisecond },
+	)
+
+	form.Append("Start delay (ms)", startDelayEntry)
+	form.Append("Duration (ms, 0 = unlimited)", durationEntry)
+}
+
+// newProfileBlocksEditor edits a ramp profile in place, keeping at least one block.
+func (pa *PerfolizerApp) newProfileBlocksEditor(blocks *[]elements.RPSProfileBlock) fyne.CanvasObject {
+	if len(*blocks) == 0 {
+		*blocks = []elements.RPSProfileBlock{
+			{RampUp: 0, StepDuration: 60000 * time.Millisecond, ProfilePercent: 100},
+		}
+	}
+
+	blocksRows := container.NewVBox()
+	var renderBlockRows func()
+	renderBlockRows = func() {
+		pa.clearPropertyValidationErrorsWithPrefix("Profile block ")
+		blocksRows.Objects = nil
+
+		for i := range *blocks {
+			index := i
+
+			rampEntry := pa.newValidatedInt64Entry(
+				fmt.Sprintf("Profile block %d ramp-up", index+1),
+				strconv.FormatInt((*blocks)[index].RampUp.Milliseconds(), 10),
+				func(s string) (int64, error) {
+					return parseDurationMillisInput(fmt.Sprintf("Profile block %d ramp-up", index+1), s)
+				},
+				func(val int64) {
+					if index < len(*blocks) {
+						(*blocks)[index].RampUp = time.Duration(val) * time.Millisecond
+					}
+				},
+			)
+			rampEntry.SetPlaceHolder("Ramp-up ms")
+
+			stepEntry := pa.newValidatedInt64Entry(
+				fmt.Sprintf("Profile block %d step duration", index+1),
+				strconv.FormatInt((*blocks)[index].StepDuration.Milliseconds(), 10),
+				func(s string) (int64, error) {
+					return parseDurationMillisInput(fmt.Sprintf("Profile block %d step duration", index+1), s)
+				},
+				func(val int64) {
+					if index < len(*blocks) {
+						(*blocks)[index].StepDuration = time.Duration(val) * time.Millisecond
+					}
+				},
+			)
+			stepEntry.SetPlaceHolder("Step ms")
+
+			profileEntry := widget.NewEntry()
+			profileEntry.SetPlaceHolder("Profile %")
+			profileEntry.SetText(strconv.FormatFloat((*blocks)[index].ProfilePercent, 'f', -1, 64))
+			profileEntry.OnChanged = func(s string) {
+				if val, err := strconv.ParseFloat(strings.TrimSpace(s), 64); err == nil && index < len(*blocks) {
+					(*blocks)[index].ProfilePercent = val
+				}
+			}
+
+			removeButton := widget.NewButton("-", func() {
+				if len(*blocks) <= 1 {
+					return
+				}
+				*blocks = append((*blocks)[:index], (*blocks)[index+1:]...)
+				renderBlockRows()
+			})
+			if len(*blocks) <= 1 {
+				removeButton.Disable()
+			}
+
+			blocksRows.Add(container.NewGridWithColumns(4, rampEntry, stepEntry, profileEntry, removeButton))
+		}
+
+		blocksRows.Refresh()
+	}
+
+	renderBlockRows()
+
+	blocksHeaders := container.NewGridWithColumns(
+		4,
+		widget.NewLabel("Ramp-up (ms)"),
+		widget.NewLabel("Step duration (ms)"),
+		widget.NewLabel("Profile percent"),
+		widget.NewLabel(""),
+	)
+
+	addBlockButton := widget.NewButton("Add block", func() {
+		*blocks = append(*blocks, elements.RPSProfileBlock{
+			RampUp:         0,
+			StepDuration:   60000 * time.Millisecond,
+			ProfilePercent: 100,
+		})
+		renderBlockRows()
+	})
+
+	return container.NewVBox(blocksHeaders, blocksRows, addBlockButton)
+}
+
+// newUserScheduleEditor edits an Ultimate Thread Group schedule in place, keeping at least one row.
+func (pa *PerfolizerApp) newUserScheduleEditor(rows *[]elements.UserScheduleRow) fyne.CanvasObject {
+	if len(*rows) == 0 {
+		*rows = []elements.UserScheduleRow{{Users: 10, RampUp: 10 * time.Second, Hold: 60 * time.Second, RampDown: 10 * time.Second}}
+	}
+
+	durationEntry := func(index int, label string, get func(*elements.UserScheduleRow) *time.Duration) *widget.Entry {
+		field := fmt.Sprintf("Schedule row %d %s", index+1, label)
+		entry := pa.newValidatedInt64Entry(
+			field,
+			strconv.FormatInt(get(&(*rows)[index]).Milliseconds(), 10),
+			func(s string) (int64, error) { return parseDurationMillisInput(field, s) },
+			func(val int64) {
+				if index < len(*rows) {
+					*get(&(*rows)[index]) = time.Duration(val) * time.Millisecond
+				}
+			},
+		)
+		entry.SetPlaceHolder(label + " ms")
+		return entry
+	}
+
+	scheduleRows := container.NewVBox()
+	var renderScheduleRows func()
+	renderScheduleRows = func() {
+		pa.clearPropertyValidationErrorsWithPrefix("Schedule row ")
+		scheduleRows.Objects = nil
+
+		for i := range *rows {
+			index := i
+
+			usersEntry := pa.newValidatedIntEntry(
+				fmt.Sprintf("Schedule row %d users", index+1),
+				strconv.Itoa((*rows)[index].Users),
+				func(s string) (int, error) {
+					return parsePositiveIntInput(fmt.Sprintf("Schedule row %d users", index+1), s)
+				},
+				func(val int) {
+					if index < len(*rows) {
+						(*rows)[index].Users = val
+					}
+				},
+			)
+			usersEntry.SetPlaceHolder("Users")
+
+			removeButton := widget.NewButton("-", func() {
+				if len(*rows) <= 1 {
+					return
+				}
+				*rows = append((*rows)[:index], (*rows)[index+1:]...)
+				renderScheduleRows()
+			})
+			if len(*rows) <= 1 {
+				removeButton.Disable()
+			}
+
+			scheduleRows.Add(container.NewGridWithColumns(6,
+				durationEntry(index, "start delay", func(r *elements.UserScheduleRow) *time.Duration { return &r.StartDelay }),
+				usersEntry,
+				durationEntry(index, "ramp-up", func(r *elements.UserScheduleRow) *time.Duration { return &r.RampUp }),
+				durationEntry(index, "hold", func(r *elements.UserScheduleRow) *time.Duration { return &r.Hold }),
+				durationEntry(index, "ramp-down", func(r *elements.UserScheduleRow) *time.Duration { return &r.RampDown }),
+				removeButton,
+			))
+		}
+
+		scheduleRows.Refresh()
+	}
+
+	renderScheduleRows()
+
+	headers := container.NewGridWithColumns(
+		6,
+		widget.NewLabel("Start delay (ms)"),
+		widget.NewLabel("Users"),
+		widget.NewLabel("Ramp-up (ms)"),
+		widget.NewLabel("Hold (ms)"),
+		widget.NewLabel("Ramp-down (ms)"),
+		widget.NewLabel(""),
+	)
+
+	addRowButton := widget.NewButton("Add row", func() {
+		*rows = append(*rows, elements.UserScheduleRow{Users: 10, RampUp: 10 * time.Second, Hold: 60 * time.Second, RampDown: 10 * time.Second})
+		renderScheduleRows()
+	})
+
+	return container.NewVBox(headers, scheduleRows, addRowButton)
+}
+
+var onSampleErrorLabels = map[string]string{
+	elements.OnSampleErrorContinue:           "Continue",
+	elements.OnSampleErrorStartNextIteration: "Start next iteration",
+	elements.OnSampleErrorStopThread:         "Stop thread",
+	elements.OnSampleErrorStopTest:           "Stop test",
+}
+
+// newOnSampleErrorSelect edits a thread group's on-sample-error policy in place.
+func newOnSampleErrorSelect(policy *string) *widget.Select {
+	labels := make([]string, 0, len(elements.OnSampleErrorPolicies))
+	policies := make(map[string]string, len(elements.OnSampleErrorPolicies))
+	for _, p := range elements.OnSampleErrorPolicies {
+		labels = append(labels, onSampleErrorLabels[p])
+		policies[onSampleErrorLabels[p]] = p
+	}
+
+	sel := widget.NewSelect(labels, func(label string) { *policy = policies[label] })
+	current := *policy
+	if current == "" {
+		current = elements.OnSampleErrorContinue
+	}
+	sel.SetSelected(onSampleErrorLabels[current])
+	return sel
+}
+
+func (pa *PerfolizerApp) projectPlanNames() []string {
+	if pa.Project == nil {
+		return nil
+	}
+	names := make([]string, 0, pa.Project.PlanCount())
+	for _, plan := range pa.Project.Plans {
+		names = append(names, plan.Name)
+	}
+	return names
+}
+
+// moduleTargetOptions lists the elements of the named project plan a Module Controller
+// can reference, keyed by display label. The first label selects the whole plan.
+func (pa *PerfolizerApp) moduleTargetOptions(planName string) ([]string, map[string]string) {
+	const wholePlan = "(whole plan)"
+	labels := []string{wholePlan}
+	ids := map[string]string{wholePlan: ""}
+	if pa.Project == nil {
+		return labels, ids
+	}
+
+	var walk func(el core.TestElement, depth int)
+	walk = func(el core.TestElement, depth int) {
+		for _, child := range el.GetChildren() {
+			_, executable := child.(core.Executable)
+			_, threadGroup := child.(core.ThreadGroup)
+			if executable || threadGroup {
+				label := fmt.Sprintf("%s%s (%s)", strings.Repeat("  ", depth), child.Name(), pa.elementTypeName(child))
+				if _, exists := ids[label]; exists {
+					label = fmt.Sprintf("%s [%s]", label, child.ID())
+				}
+				labels = append(labels, label)
+				ids[label] = child.ID()
+			}
+			walk(child, depth+1)
+		}
+	}
+	for _, plan := range pa.Project.Plans {
+		if plan.Name == planName && plan.Root != nil {
+			walk(plan.Root, 0)
+			break
+		}
+	}
+	return labels, ids
+}
+
 func (pa *PerfolizerApp) canContainScenarioChildren(parent core.TestElement) bool {
 	switch parent.(type) {
-	case *elements.SimpleThreadGroup, *elements.RPSThreadGroup, *elements.LoopController, *elements.IfController:
+	case *elements.SimpleThreadGroup, *elements.RPSThreadGroup, *elements.ArrivalRateThreadGroup, *elements.UltimateThreadGroup,
+		*elements.CapacityThreadGroup, *elements.SetupThreadGroup, *elements.TeardownThreadGroup, *elements.LoopController, *elements.IfController,
+		*elements.WhileController, *elements.TransactionController, *elements.RandomController,
+		*elements.WeightedSwitchController, *elements.OnceOnlyController, *elements.InterleaveController,
+		*elements.ForEachController, *elements.ParallelController,
+		*elements.ThroughputController, *elements.RuntimeController, *elements.CriticalSectionController:
 		return true
 	default:
 		return false
@@ -1412,6 +2332,16 @@ func (pa *PerfolizerApp) allowedComponentTypes(planIdx int, parent core.TestElem
 		for _, typeName := range controllerComponentTypes {
 			allowed[typeName] = true
 		}
+		allowed[componentCSVDataSet] = true
+	}
+
+	// Timers, counters and random variables apply to the samplers in their parent's scope, or to their parent sampler
+	if _, isSampler := parent.(*elements.HttpSampler); isSampler || pa.canContainScenarioChildren(parent) {
+		for _, typeName := range timerComponentTypes {
+			allowed[typeName] = true
+		}
+		allowed[componentCounter] = true
+		allowed[componentRandomVariable] = true
 	}
 
 	return allowed
@@ -1466,6 +2396,8 @@ func (pa *PerfolizerApp) addElement() {
 			pa.newAddComponentSection(planIdx, parent, "Thread Groups", threadGroupComponentTypes, allowed),
 			pa.newAddComponentSection(planIdx, parent, "Samplers", samplerComponentTypes, allowed),
 			pa.newAddComponentSection(planIdx, parent, "Controllers", controllerComponentTypes, allowed),
+			pa.newAddComponentSection(planIdx, parent, "Timers", timerComponentTypes, allowed),
+			pa.newAddComponentSection(planIdx, parent, "Config Elements", configComponentTypes, allowed),
 		),
 		pa.Window,
 	)
@@ -1488,14 +2420,68 @@ func (pa *PerfolizerApp) doAddElement(planIdx int, parent core.TestElement, type
 		newEl = elements.NewSimpleThreadGroup("Thread Group", 1, 1)
 	case componentRPSThreadGroup:
 		newEl = elements.NewRPSThreadGroup("RPS Group", 10.0)
+	case componentArrivalRateGroup:
+		newEl = elements.NewArrivalRateThreadGroup("Arrival Rate Group", 10.0)
+	case componentUltimateGroup:
+		newEl = elements.NewUltimateThreadGroup("Ultimate Thread Group")
+	case componentCapacityGroup:
+		newEl = elements.NewCapacityThreadGroup("Capacity Thread Group")
+	case componentSetupGroup:
+		newEl = elements.NewSetupThreadGroup("Setup Thread Group")
+	case componentTeardownGroup:
+		newEl = elements.NewTeardownThreadGroup("Teardown Thread Group")
 	case componentHTTPSampler:
 		newEl = &elements.HttpSampler{BaseElement: core.NewBaseElement("HTTP Request"), Method: "GET", Url: "http://localhost"}
 	case componentLoopController:
 		newEl = elements.NewLoopController("Loop Controller", 1)
 	case componentIfController:
-		newEl = elements.NewIfController("If Controller", func(ctx *core.Context) bool { return true })
+		newEl = elements.NewExpressionIfController("If Controller", "lastSampleOk")
+	case componentWhileController:
+		w := elements.NewWhileController("While Controller", "lastSampleOk")
+		w.MaxIterations = 10
+		newEl = w
+	case componentTransaction:
+		newEl = elements.NewTransactionController("Transaction")
+	case componentRandomController:
+		newEl = elements.NewRandomController("Random Controller")
+	case componentWeightedSwitch:
+		newEl = elements.NewWeightedSwitchController("Weighted Switch")
+	case componentOnceOnly:
+		newEl = elements.NewOnceOnlyController("Once Only Controller")
+	case componentInterleave:
+		newEl = elements.NewInterleaveController("Interleave Controller")
+	case componentForEach:
+		newEl = elements.NewForEachController("ForEach Controller", "items", "item")
+	case componentParallel:
+		newEl = elements.NewParallelController("Parallel Controller", 6)
+	case componentThroughput:
+		newEl = elements.NewThroughputController("Throughput Controller", elements.ThroughputModePercent)
+	case componentRuntime:
+		newEl = elements.NewRuntimeController("Runtime Controller", time.Minute)
+	case componentModule:
+		newEl = elements.NewModuleController("Module Controller", "", "")
+	case componentCriticalSection:
+		newEl = elements.NewCriticalSectionController("Critical Section Controller", "global_lock")
 	case componentPauseController:
 		newEl = &elements.PauseController{BaseElement: core.NewBaseElement("Pause"), Duration: 1000}
+	case componentConstantTimer:
+		newEl = elements.NewConstantTimer("Constant Timer", 300*time.Millisecond)
+	case componentUniformTimer:
+		newEl = elements.NewUniformRandomTimer("Uniform Random Timer", 0, 100*time.Millisecond)
+	case componentGaussianTimer:
+		newEl = elements.NewGaussianRandomTimer("Gaussian Random Timer", 300*time.Millisecond, 100*time.Millisecond)
+	case componentPoissonTimer:
+		newEl = elements.NewPoissonRandomTimer("Poisson Random Timer", 100*time.Millisecond, 300*time.Millisecond)
+	case componentThroughputTimer:
+		newEl = elements.NewConstantThroughputTimer("Constant Throughput Timer", 60)
+	case componentSyncTimer:
+		newEl = elements.NewSyncTimer("Synchronizing Timer", 10, 5*time.Second)
+	case componentCSVDataSet:
+		newEl = elements.NewCSVDataSet("CSV Data Set", "")
+	case componentCounter:
+		newEl = elements.NewCounter("Counter", "counter")
+	case componentRandomVariable:
+		newEl = elements.NewRandomVariable("Random Variable", "random")
 	}
 
 	if newEl != nil {
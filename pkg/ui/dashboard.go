@@ -15,9 +15,11 @@ type DashboardWindow struct {
 	RpsChart *LineChart
 	LatChart *LineChart
 	ErrChart *LineChart
+	VUChart  *LineChart
 	RpsLabel *widget.Label
 	LatLabel *widget.Label
 	ErrLabel *widget.Label
+	VULabel  *widget.Label
 	Legend   *fyne.Container
 
 	seriesMap map[string]bool // To track existing checkboxes
@@ -30,10 +32,12 @@ func NewDashboardWindow(a fyne.App) *DashboardWindow {
 	rpsChart := NewLineChart(100)
 	latChart := NewLineChart(100)
 	errChart := NewLineChart(100)
+	vuChart := NewLineChart(100)
 
 	rpsLabel := widget.NewLabel("Total RPS: 0")
 	latLabel := widget.NewLabel("Avg Latency: 0 ms")
 	errLabel := widget.NewLabel("Errors (total): 0")
+	vuLabel := widget.NewLabel("Active users: 0")
 
 	legend := container.NewHBox(widget.NewLabel("Series:"))
 
@@ -44,6 +48,8 @@ func NewDashboardWindow(a fyne.App) *DashboardWindow {
 		container.NewPadded(latChart),
 		errLabel,
 		container.NewPadded(errChart),
+		vuLabel,
+		container.NewPadded(vuChart),
 		widget.NewLabel("Legend:"),
 		container.NewHScroll(legend),
 	)
@@ -56,9 +62,11 @@ func NewDashboardWindow(a fyne.App) *DashboardWindow {
 		RpsChart:  rpsChart,
 		LatChart:  latChart,
 		ErrChart:  errChart,
+		VUChart:   vuChart,
 		RpsLabel:  rpsLabel,
 		LatLabel:  latLabel,
 		ErrLabel:  errLabel,
+		VULabel:   vuLabel,
 		Legend:    legend,
 		seriesMap: make(map[string]bool),
 	}
@@ -71,11 +79,17 @@ func (d *DashboardWindow) Show() {
 func (d *DashboardWindow) Update(data map[string]core.Metric) {
 	totalRps := 0.0
 	totalLat := 0.0
+	totalP95 := 0.0
 	totalErr := 0
+	activeUsers := 0
+	sustainableRPS := 0.0
 	if t, ok := data["Total"]; ok {
 		totalRps = t.RPS
 		totalLat = t.AvgLatency
+		totalP95 = t.P95Latency
 		totalErr = t.TotalErrors
+		activeUsers = t.ActiveUsers
+		sustainableRPS = t.SustainableRPS
 	}
 
 	fyne.Do(func() {
@@ -104,8 +118,15 @@ func (d *DashboardWindow) Update(data map[string]core.Metric) {
 			d.ErrChart.Add(name, float64(m.TotalErrors))
 		}
 
-		d.RpsLabel.SetText(fmt.Sprintf("Total RPS: %.2f", totalRps))
-		d.LatLabel.SetText(fmt.Sprintf("Avg Latency: %.2f ms", totalLat))
+		d.VUChart.Add("Active users", float64(activeUsers))
+
+		if sustainableRPS > 0 {
+			d.RpsLabel.SetText(fmt.Sprintf("Total RPS: %.2f (max sustainable: %.2f)", totalRps, sustainableRPS))
+		} else {
+			d.RpsLabel.SetText(fmt.Sprintf("Total RPS: %.2f", totalRps))
+		}
+		d.LatLabel.SetText(fmt.Sprintf("Avg Latency: %.2f ms (p95: %.2f ms)", totalLat, totalP95))
 		d.ErrLabel.SetText(fmt.Sprintf("Errors (total): %d", totalErr))
+		d.VULabel.SetText(fmt.Sprintf("Active users: %d", activeUsers))
 	})
 }
@@ -34,12 +34,13 @@ func (pm *ParameterManager) setupUI() {
 			return len(pm.params)
 		},
 		func() fyne.CanvasObject {
-			// Create template:Grid with Type, Name, Value, Expression, and Action buttons
-			return container.NewGridWithColumns(5,
+			// Create template:Grid with Type, Name, Value, Expression, Scope, and Action buttons
+			return container.NewGridWithColumns(6,
 				widget.NewLabel(""), // Type
 				widget.NewLabel(""), // Name
 				widget.NewLabel(""), // Value
 				widget.NewLabel(""), // Expression
+				widget.NewLabel(""), // Scope
 				container.NewHBox( // Action buttons
 					widget.NewButtonWithIcon("", theme.DocumentCreateIcon(), nil),
 					widget.NewButtonWithIcon("", theme.DeleteIcon(), nil),
@@ -70,8 +71,12 @@ func (pm *ParameterManager) setupUI() {
 				exprLabel.SetText("-")
 			}
 
+			// Update Scope
+			scopeLabel := grid.Objects[4].(*widget.Label)
+			scopeLabel.SetText(parameterScope(p))
+
 			// Update Action buttons
-			btns := grid.Objects[4].(*fyne.Container)
+			btns := grid.Objects[5].(*fyne.Container)
 			editBtn := btns.Objects[0].(*widget.Button)
 			delBtn := btns.Objects[1].(*widget.Button)
 
@@ -89,11 +94,12 @@ func (pm *ParameterManager) setupUI() {
 	})
 
 	// Header
-	header := container.NewGridWithColumns(5,
+	header := container.NewGridWithColumns(6,
 		widget.NewLabel("Type"),
 		widget.NewLabel("Name"),
 		widget.NewLabel("Value / Default"),
 		widget.NewLabel("Expression"),
+		widget.NewLabel("Scope"),
 		widget.NewLabel("Action"),
 	)
 
@@ -133,6 +139,9 @@ func (pm *ParameterManager) showAddDialog() {
 	typeSelect := widget.NewSelect([]string{core.ParamTypeStatic, core.ParamTypeRegexp, core.ParamTypeJSON}, nil)
 	typeSelect.SetSelected(core.ParamTypeStatic) // Default to static
 
+	scopeSelect := widget.NewSelect(core.VarScopes, nil)
+	scopeSelect.SetSelected(core.VarScopeThread)
+
 	// Create form container
 	formContainer := container.NewVBox()
 
@@ -164,6 +173,10 @@ func (pm *ParameterManager) showAddDialog() {
 				exprEntry,
 			))
 		}
+		formContainer.Add(container.NewBorder(nil, nil,
+			widget.NewLabel("Scope:"), nil,
+			scopeSelect,
+		))
 		formContainer.Refresh()
 	}
 
@@ -205,6 +218,7 @@ func (pm *ParameterManager) showAddDialog() {
 			Type:       typeSelect.Selected,
 			Value:      valueEntry.Text,
 			Expression: exprEntry.Text,
+			Scope:      scopeSelect.Selected,
 		}
 		pm.App.Project.Plans[planIdx].Parameters = append(pm.App.Project.Plans[planIdx].Parameters, newParam)
 		pm.Refresh()
@@ -241,6 +255,9 @@ func (pm *ParameterManager) showEditDialog(index int) {
 	exprEntry.SetText(p.Expression)
 	exprEntry.SetPlaceHolder("Regex / JSON Path")
 
+	scopeSelect := widget.NewSelect(core.VarScopes, nil)
+	scopeSelect.SetSelected(parameterScope(p))
+
 	// Create form container
 	formContainer := container.NewVBox()
 
@@ -272,6 +289,10 @@ func (pm *ParameterManager) showEditDialog(index int) {
 				exprEntry,
 			))
 		}
+		formContainer.Add(container.NewBorder(nil, nil,
+			widget.NewLabel("Scope:"), nil,
+			scopeSelect,
+		))
 		formContainer.Refresh()
 	}
 
@@ -308,6 +329,7 @@ func (pm *ParameterManager) showEditDialog(index int) {
 		pm.App.Project.Plans[planIdx].Parameters[index].Type = typeSelect.Selected
 		pm.App.Project.Plans[planIdx].Parameters[index].Value = valueEntry.Text
 		pm.App.Project.Plans[planIdx].Parameters[index].Expression = exprEntry.Text
+		pm.App.Project.Plans[planIdx].Parameters[index].Scope = scopeSelect.Selected
 		pm.Refresh()
 	}, pm.App.Window)
 
@@ -324,3 +346,12 @@ func (pm *ParameterManager) deleteParameter(index int) {
 	pm.App.Project.Plans[planIdx].Parameters = append(params[:index], params[index+1:]...)
 	pm.Refresh()
 }
+
+// parameterScope returns the scope shown for p; parameters saved before scopes
+// existed are thread-local.
+func parameterScope(p core.Parameter) string {
+	if p.Scope == "" {
+		return core.VarScopeThread
+	}
+	return p.Scope
+}
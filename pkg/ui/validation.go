@@ -106,6 +106,31 @@ func (pa *PerfolizerApp) newValidatedFloatEntry(field, initialText string, parse
 	return entry
 }
 
+func (pa *PerfolizerApp) newValidatedTextEntry(field, initialText string, validate func(string) error, apply func(string)) *widget.Entry {
+	entry := widget.NewEntry()
+	entry.SetText(initialText)
+	pa.bindPropertyValidation(entry, field)
+	entry.OnChanged = func(s string) {
+		err := validate(s)
+		pa.setPropertyValidationError(field, err)
+		entry.SetValidationError(err)
+		if err == nil {
+			apply(s)
+		}
+	}
+	return entry
+}
+
+// newDurationMillisEntry edits a non-negative duration in milliseconds in place.
+func (pa *PerfolizerApp) newDurationMillisEntry(field string, target *time.Duration) *widget.Entry {
+	return pa.newValidatedInt64Entry(
+		field,
+		strconv.FormatInt(target.Milliseconds(), 10),
+		func(s string) (int64, error) { return parseDurationMillisInput(field, s) },
+		func(val int64) { *target = time.Duration(val) * time.Millisecond },
+	)
+}
+
 func parseRequiredInt(field, raw string) (int, error) {
 	trimmed := strings.TrimSpace(raw)
 	if trimmed == "" {
@@ -158,6 +183,36 @@ func parseIterationsInput(raw string) (int, error) {
 	return value, elements.ValidateIterations(value)
 }
 
+func parseNonNegativeIntInput(field, raw string) (int, error) {
+	value, err := parseRequiredInt(field, raw)
+	if err != nil {
+		return 0, err
+	}
+	return value, elements.ValidateNonNegative(field, value)
+}
+
+func parseNonNegativeInt64Input(field, raw string) (int64, error) {
+	value, err := parseRequiredInt64(field, raw)
+	if err != nil {
+		return 0, err
+	}
+	if value < 0 {
+		return 0, fmt.Errorf("%s must be greater than or equal to 0", field)
+	}
+	return value, nil
+}
+
+func parsePositiveIntInput(field, raw string) (int, error) {
+	value, err := parseRequiredInt(field, raw)
+	if err != nil {
+		return 0, err
+	}
+	if value < 1 {
+		return 0, fmt.Errorf("%s must be greater than 0", field)
+	}
+	return value, nil
+}
+
 func parseRPSInput(field, raw string) (float64, error) {
 	value, err := parseRequiredFloat(field, raw)
 	if err != nil {
@@ -166,6 +221,40 @@ func parseRPSInput(field, raw string) (float64, error) {
 	return value, elements.ValidateRPS(field, value)
 }
 
+func parsePositiveRPSInput(field, raw string) (float64, error) {
+	value, err := parseRPSInput(field, raw)
+	if err != nil {
+		return 0, err
+	}
+	if value <= 0 {
+		return 0, fmt.Errorf("%s must be greater than 0", field)
+	}
+	return value, nil
+}
+
+func validateConditionInput(raw string) error {
+	return elements.ValidateExpression("Condition", raw)
+}
+
+func parseWeightInput(field, raw string) (float64, error) {
+	value, err := parseRequiredFloat(field, raw)
+	if err != nil {
+		return 0, err
+	}
+	return value, elements.ValidateWeight(field, value)
+}
+
+func parsePercentInput(raw string) (float64, error) {
+	value, err := parseRequiredFloat("Percent", raw)
+	if err != nil {
+		return 0, err
+	}
+	if value < 0 || value > 100 {
+		return 0, fmt.Errorf("Percent must be between 0 and 100")
+	}
+	return value, nil
+}
+
 func parseDurationMillisInput(field, raw string) (int64, error) {
 	value, err := parseRequiredInt64(field, raw)
 	if err != nil {
@@ -0,0 +1,82 @@
+package agent_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"perfolizer/pkg/agent"
+	"perfolizer/pkg/core"
+	"perfolizer/pkg/elements"
+)
+
+func TestMetricsExportActiveUsersPerThreadGroupAndTotal(t *testing.T) {
+	release := make(chan struct{})
+	hold := func(ctx *core.Context) error {
+		select {
+		case <-release:
+		case <-ctx.Done():
+		}
+		return nil
+	}
+
+	browse := elements.NewSimpleThreadGroup("Browse", 2, 1)
+	browse.AddChild(newPhaseStep("Hold", hold))
+	buy := elements.NewSimpleThreadGroup("Buy", 1, 1)
+	buy.AddChild(newPhaseStep("Hold", hold))
+	root := core.NewBaseElement("Shop")
+	root.AddChild(browse)
+	root.AddChild(buy)
+
+	server := agent.NewServer(agent.ServerOptions{})
+	if err := server.Start(&root); err != nil {
+		t.Fatalf("Start returned error: %v", err)
+	}
+	defer waitUntilStopped(t, server)
+	defer close(release)
+
+	ts := httptest.NewServer(server.Handler())
+	defer ts.Close()
+
+	want := []string{
+		"perfolizer_active_users{thread_group=" + strconv.Quote(browse.ID()) + "} 2\n",
+		"perfolizer_active_users{thread_group=" + strconv.Quote(buy.ID()) + "} 1\n",
+		`perfolizer_active_users{thread_group="Total"} 3` + "\n",
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		body := fetchMetrics(t, ts.URL)
+		missing := ""
+		for _, line := range want {
+			if !strings.Contains(body, line) {
+				missing = line
+				break
+			}
+		}
+		if missing == "" {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected metrics to contain %q, got:\n%s", missing, body)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func fetchMetrics(t *testing.T, baseURL string) string {
+	t.Helper()
+	resp, err := http.Get(baseURL + "/metrics")
+	if err != nil {
+		t.Fatalf("GET /metrics: %v", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("read /metrics: %v", err)
+	}
+	return string(body)
+}
@@ -0,0 +1,397 @@
+package agent_test
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"perfolizer/pkg/agent"
+	"perfolizer/pkg/core"
+	"perfolizer/pkg/elements"
+)
+
+// phaseStep runs fn as a test plan step.
+type phaseStep struct {
+	core.BaseElement
+	fn func(ctx *core.Context) error
+}
+
+func newPhaseStep(name string, fn func(ctx *core.Context) error) *phaseStep {
+	return &phaseStep{BaseElement: core.NewBaseElement(name), fn: fn}
+}
+
+func (s *phaseStep) Clone() core.TestElement {
+	clone := *s
+	clone.BaseElement = core.NewBaseElement(s.Name())
+	return &clone
+}
+
+func (s *phaseStep) Execute(ctx *core.Context) error {
+	return s.fn(ctx)
+}
+
+type phaseLog struct {
+	mu      sync.Mutex
+	entries []string
+}
+
+func (l *phaseLog) add(entry string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.entries = append(l.entries, entry)
+}
+
+func (l *phaseLog) snapshot() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]string(nil), l.entries...)
+}
+
+func waitUntilStopped(t *testing.T, server *agent.Server) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if running, _ := server.Snapshot(); !running {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatal("timed out waiting for the run to finish")
+}
+
+func TestRunPlanOrdersSetupMainAndTeardownAndSharesSetupGlobals(t *testing.T) {
+	log := &phaseLog{}
+
+	setup := elements.NewSetupThreadGroup("Create data")
+	setup.AddChild(newPhaseStep("Login", func(ctx *core.Context) error {
+		time.Sleep(20 * time.Millisecond)
+		ctx.SetVar("token", "abc")
+		log.add("setup")
+		return nil
+	}))
+
+	main := elements.NewSimpleThreadGroup("Load", 2, 1)
+	main.Parameters = []core.Parameter{{Name: "token", Value: "default"}}
+	main.AddChild(newPhaseStep("Use token", func(ctx *core.Context) error {
+		log.add("main " + ctx.Substitute("${token}"))
+		ctx.SetVar("token", "changed by main")
+		return nil
+	}))
+
+	teardown := elements.NewTeardownThreadGroup("Clean up")
+	teardown.AddChild(newPhaseStep("Delete", func(ctx *core.Context) error {
+		log.add("teardown " + ctx.Substitute("${token}"))
+		return nil
+	}))
+
+	root := core.NewBaseElement("Plan")
+	root.AddChild(teardown)
+	root.AddChild(main)
+	root.AddChild(setup)
+
+	server := agent.NewServer(agent.ServerOptions{})
+	if err := server.Start(&root); err != nil {
+		t.Fatalf("Start returned error: %v", err)
+	}
+	waitUntilStopped(t, server)
+
+	got := log.snapshot()
+	want := []string{"setup", "main abc", "main abc", "teardown abc"}
+	if len(got) != len(want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("expected %v, got %v", want, got)
+		}
+	}
+}
+
+func TestRunPlanPublishesOnlyUserVariablesFromSetup(t *testing.T) {
+	setup := elements.NewSetupThreadGroup("Create data")
+	once := elements.NewOnceOnlyController("Once")
+	interleave := elements.NewInterleaveController("Alternate")
+	transaction := elements.NewTransactionController("Login")
+	transaction.AddChild(newPhaseStep("Login", func(ctx *core.Context) error {
+		ctx.SetVar("token", ctx.Substitute("abc${__counter(TRUE)}"))
+		ctx.SetLastSample(&core.SampleResult{SamplerName: "Login", EndTime: time.Now()})
+		return nil
+	}))
+	interleave.AddChild(transaction)
+	once.AddChild(interleave)
+	setup.AddChild(once)
+
+	internal := []string{
+		"Reporter", "PauseTracker", "SampleHooks", "OnSampleError", "ThreadRoster", "__counter",
+		"OnceOnly_" + once.ID(), "Interleave_" + interleave.ID(),
+	}
+	var mu sync.Mutex
+	var token interface{}
+	var published []string
+	main := elements.NewSimpleThreadGroup("Load", 1, 1)
+	main.AddChild(newPhaseStep("Check", func(ctx *core.Context) error {
+		exports := core.SetupExportsFromContext(ctx)
+		mu.Lock()
+		defer mu.Unlock()
+		token, _ = exports.Get("token")
+		for _, key := range internal {
+			if _, ok := exports.Get(key); ok {
+				published = append(published, key)
+			}
+		}
+		return nil
+	}))
+
+	root := core.NewBaseElement("Plan")
+	root.AddChild(setup)
+	root.AddChild(main)
+
+	server := agent.NewServer(agent.ServerOptions{})
+	if err := server.Start(&root); err != nil {
+		t.Fatalf("Start returned error: %v", err)
+	}
+	waitUntilStopped(t, server)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if token != "abc1" {
+		t.Fatalf("expected the setup token to be published, got %v", token)
+	}
+	if len(published) > 0 {
+		t.Fatalf("expected thread state to stay out of the globals, got %v", published)
+	}
+}
+
+func TestRunPlanRunsTeardownAfterStopWithItsOwnTimeout(t *testing.T) {
+	mainRunning := make(chan struct{})
+	var once sync.Once
+	main := elements.NewSimpleThreadGroup("Endless", 1, -1)
+	main.AddChild(newPhaseStep("Work", func(ctx *core.Context) error {
+		once.Do(func() { close(mainRunning) })
+		time.Sleep(2 * time.Millisecond)
+		return nil
+	}))
+
+	type teardownResult struct {
+		waited  time.Duration
+		stopped bool
+	}
+	done := make(chan teardownResult, 1)
+	teardown := elements.NewTeardownThreadGroup("Clean up")
+	teardown.Timeout = 80 * time.Millisecond
+	teardown.AddChild(newPhaseStep("Slow cleanup", func(ctx *core.Context) error {
+		start := time.Now()
+		select {
+		case <-ctx.Done():
+			done <- teardownResult{waited: time.Since(start), stopped: true}
+		case <-time.After(time.Second):
+			done <- teardownResult{waited: time.Since(start)}
+		}
+		return nil
+	}))
+
+	root := core.NewBaseElement("Plan")
+	root.AddChild(main)
+	root.AddChild(teardown)
+
+	server := agent.NewServer(agent.ServerOptions{})
+	if err := server.Start(&root); err != nil {
+		t.Fatalf("Start returned error: %v", err)
+	}
+	<-mainRunning
+	if wasRunning, _ := server.Stop(); !wasRunning {
+		t.Fatal("expected a running test to stop")
+	}
+
+	select {
+	case result := <-done:
+		if !result.stopped {
+			t.Fatal("expected the teardown timeout to cancel the teardown")
+		}
+		if result.waited < 60*time.Millisecond {
+			t.Fatalf("expected teardown to outlive /stop until its timeout, waited %v", result.waited)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected teardown to run after stop")
+	}
+}
+
+func TestServerStaysBusyUntilTeardownFinishesAfterStop(t *testing.T) {
+	mainRunning := make(chan struct{})
+	var once sync.Once
+	main := elements.NewSimpleThreadGroup("Endless", 1, -1)
+	main.AddChild(newPhaseStep("Work", func(ctx *core.Context) error {
+		once.Do(func() { close(mainRunning) })
+		time.Sleep(2 * time.Millisecond)
+		return nil
+	}))
+	teardown := elements.NewTeardownThreadGroup("Clean up")
+	teardown.AddChild(newPhaseStep("Slow cleanup", func(ctx *core.Context) error {
+		time.Sleep(100 * time.Millisecond)
+		return nil
+	}))
+
+	root := core.NewBaseElement("Plan")
+	root.AddChild(main)
+	root.AddChild(teardown)
+
+	server := agent.NewServer(agent.ServerOptions{})
+	if err := server.Start(&root); err != nil {
+		t.Fatalf("Start returned error: %v", err)
+	}
+	<-mainRunning
+	server.Stop()
+
+	if running, _ := server.Snapshot(); !running {
+		t.Fatal("expected the server to report the run until teardown finishes")
+	}
+	if err := server.Start(&root); !errors.Is(err, agent.ErrStopping) {
+		t.Fatalf("expected a new run to be rejected while stopping, got %v", err)
+	}
+
+	waitUntilStopped(t, server)
+	idle := core.NewBaseElement("Idle")
+	if err := server.Start(&idle); err != nil {
+		t.Fatalf("expected a new run once teardown finished, got %v", err)
+	}
+	waitUntilStopped(t, server)
+}
+
+func TestRunPlanRunsThreadGroupsSequentiallyWhenThePlanAsksForIt(t *testing.T) {
+	var mu sync.Mutex
+	spans := make(map[string][2]time.Time)
+	record := func(name string) func(ctx *core.Context) error {
+		return func(ctx *core.Context) error {
+			now := time.Now()
+			mu.Lock()
+			span, ok := spans[name]
+			if !ok {
+				span[0] = now
+			}
+			span[1] = now
+			spans[name] = span
+			mu.Unlock()
+			time.Sleep(2 * time.Millisecond)
+			return nil
+		}
+	}
+
+	warmUp := elements.NewSimpleThreadGroup("Warm up", 1, -1)
+	warmUp.Duration = 80 * time.Millisecond
+	warmUp.AddChild(newPhaseStep("Warm", record("warm up")))
+
+	load := elements.NewSimpleThreadGroup("Load", 1, 3)
+	load.AddChild(newPhaseStep("Load", record("load")))
+
+	plan := core.NewTestPlan("Plan")
+	plan.SequentialThreadGroups = true
+	plan.AddChild(warmUp)
+	plan.AddChild(load)
+
+	server := agent.NewServer(agent.ServerOptions{})
+	if err := server.Start(plan); err != nil {
+		t.Fatalf("Start returned error: %v", err)
+	}
+	waitUntilStopped(t, server)
+
+	mu.Lock()
+	defer mu.Unlock()
+	warm, loadSpan := spans["warm up"], spans["load"]
+	if warm[0].IsZero() || loadSpan[0].IsZero() {
+		t.Fatalf("expected both groups to run, got %v", spans)
+	}
+	if !loadSpan[0].After(warm[1]) {
+		t.Fatalf("expected the load group to start after the warm-up ended, warm-up %v, load %v", warm, loadSpan)
+	}
+	if gap := loadSpan[0].Sub(warm[0]); gap < 70*time.Millisecond {
+		t.Fatalf("expected the warm-up to run for its duration first, load started after %v", gap)
+	}
+}
+
+func TestRunPlanSharesGlobalAndThreadGroupVariables(t *testing.T) {
+	log := &phaseLog{}
+
+	writer := elements.NewSimpleThreadGroup("Writer", 2, 1)
+	writer.Parameters = []core.Parameter{
+		{Name: "batch", Value: "default", Scope: core.VarScopeGroup},
+		{Name: "seen", Value: "0", Scope: core.VarScopeGlobal},
+	}
+	writer.AddChild(newPhaseStep("Write", func(ctx *core.Context) error {
+		if ctx.ThreadID == 0 {
+			ctx.SetScopedVar(core.VarScopeGroup, "batch", "b-1")
+		}
+		if globals := core.GlobalsFromContext(ctx); globals != nil {
+			globals.Update("seen", func(old interface{}, ok bool) interface{} {
+				return old.(string) + "+"
+			})
+		}
+		ctx.SetScopedVar(core.VarScopeGlobal, "order", "o-42")
+		return nil
+	}))
+
+	reader := elements.NewSimpleThreadGroup("Reader", 1, 1)
+	reader.Parameters = []core.Parameter{{Name: "order", Value: "none", Scope: core.VarScopeGlobal}}
+	reader.AddChild(newPhaseStep("Read", func(ctx *core.Context) error {
+		log.add(ctx.Substitute("${order} ${seen} ${batch}"))
+		return nil
+	}))
+
+	plan := core.NewTestPlan("Plan")
+	plan.SequentialThreadGroups = true
+	plan.AddChild(writer)
+	plan.AddChild(reader)
+
+	server := agent.NewServer(agent.ServerOptions{})
+	if err := server.Start(plan); err != nil {
+		t.Fatalf("Start returned error: %v", err)
+	}
+	waitUntilStopped(t, server)
+
+	// The reader sees the writer's globals but not its thread group variables
+	got := log.snapshot()
+	if len(got) != 1 || got[0] != "o-42 0++ ${batch}" {
+		t.Fatalf("expected shared globals only, got %v", got)
+	}
+}
+
+func TestRunPlanKeepsSetupExportsReadOnly(t *testing.T) {
+	log := &phaseLog{}
+
+	setup := elements.NewSetupThreadGroup("Create data")
+	setup.AddChild(newPhaseStep("Create order", func(ctx *core.Context) error {
+		ctx.SetVar("order", "o-1")
+		return nil
+	}))
+
+	main := elements.NewSimpleThreadGroup("Load", 1, 1)
+	main.AddChild(newPhaseStep("Overwrite", func(ctx *core.Context) error {
+		ctx.SetScopedVar(core.VarScopeGlobal, "order", "o-2")
+		log.add("main " + ctx.Substitute("${order}"))
+		return nil
+	}))
+
+	teardown := elements.NewTeardownThreadGroup("Clean up")
+	teardown.AddChild(newPhaseStep("Delete order", func(ctx *core.Context) error {
+		log.add("teardown " + ctx.Substitute("${order}"))
+		return nil
+	}))
+
+	plan := core.NewTestPlan("Plan")
+	plan.AddChild(setup)
+	plan.AddChild(main)
+	plan.AddChild(teardown)
+
+	server := agent.NewServer(agent.ServerOptions{})
+	if err := server.Start(plan); err != nil {
+		t.Fatalf("Start returned error: %v", err)
+	}
+	waitUntilStopped(t, server)
+
+	// The writing thread keeps its own value; teardown still reads what setup created
+	got := log.snapshot()
+	want := []string{"main o-2", "teardown o-1"}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+}
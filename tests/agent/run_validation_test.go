@@ -48,3 +48,55 @@ func TestHandleRunRejectsInvalidPlanWithValidationMessage(t *testing.T) {
 		t.Fatal("expected server to remain stopped after invalid run request")
 	}
 }
+
+func TestHandleRunRejectsUnresolvedModuleController(t *testing.T) {
+	server := agent.NewServer(agent.ServerOptions{})
+
+	root := core.NewBaseElement("Test Plan")
+	tg := elements.NewSimpleThreadGroup("Users", 1, 1)
+	tg.AddChild(elements.NewModuleController("Loop back", "Test Plan", ""))
+	root.AddChild(tg)
+
+	body, err := core.MarshalTestPlan(&root)
+	if err != nil {
+		t.Fatalf("failed to marshal test plan: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/run", bytes.NewReader(body))
+	rec := httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected status %d, got %d", http.StatusBadRequest, rec.Code)
+	}
+	if message := rec.Body.String(); !strings.Contains(message, `Module Controller "Loop back": reference Test Plan is not resolved`) {
+		t.Fatalf("expected unresolved module message, got %q", message)
+	}
+}
+
+func TestHandleRunRejectsDataSetThatWasNotBundled(t *testing.T) {
+	server := agent.NewServer(agent.ServerOptions{})
+
+	root := core.NewBaseElement("Test Plan")
+	tg := elements.NewSimpleThreadGroup("Users", 1, 1)
+	data := elements.NewCSVDataSet("Users", "/etc/passwd")
+	data.VariableNames = "user"
+	tg.AddChild(data)
+	root.AddChild(tg)
+
+	body, err := core.MarshalTestPlan(&root)
+	if err != nil {
+		t.Fatalf("failed to marshal test plan: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/run", bytes.NewReader(body))
+	rec := httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected status %d, got %d", http.StatusBadRequest, rec.Code)
+	}
+	if message := rec.Body.String(); !strings.Contains(message, `CSV Data Set "Users": file /etc/passwd is not bundled`) {
+		t.Fatalf("expected data file message, got %q", message)
+	}
+}
@@ -1,6 +1,7 @@
 package ai_test
 
 import (
+	"errors"
 	"testing"
 	"time"
 
@@ -194,3 +195,34 @@ func TestValidateDraftNormalizesProviderStyleAliases(t *testing.T) {
 		t.Fatalf("expected 500ms pause, got %s", pause.Duration)
 	}
 }
+
+func TestApplyPatchInProjectRejectsModuleReferenceCycles(t *testing.T) {
+	project := core.NewProject("Shop")
+	root := core.NewBaseElement("Main")
+	tg := elements.NewSimpleThreadGroup("Users", 1, 1)
+	root.AddChild(tg)
+	project.AddPlan("Main", &root)
+
+	common := core.NewBaseElement("Common")
+	common.AddChild(elements.NewSimpleThreadGroup("Fragments", 1, 1))
+	project.AddPlan("Common", &common)
+
+	addModule := func(name, planName string) aipkg.PlanPatch {
+		dto := core.TestElementToDTO(elements.NewModuleController(name, planName, ""))
+		return aipkg.PlanPatch{Operations: []aipkg.PatchOperation{{
+			Type:     "add_child",
+			ParentID: tg.ID(),
+			Element:  &dto,
+		}}}
+	}
+
+	if _, err := aipkg.ApplyPatchInProject(&root, addModule("Common steps", "Common"), project, ""); err != nil {
+		t.Fatalf("ApplyPatchInProject returned error: %v", err)
+	}
+	if _, err := aipkg.ApplyPatchInProject(&root, addModule("Again", "Main"), project, ""); !errors.Is(err, elements.ErrModuleCycle) {
+		t.Fatalf("expected a module reference cycle error, got %v", err)
+	}
+	if _, err := aipkg.ApplyPatch(&root, addModule("Again", "Main")); !errors.Is(err, elements.ErrModuleCycle) {
+		t.Fatalf("expected a self-referencing module to be rejected without a project, got %v", err)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"time"
 
 	aipkg "perfolizer/pkg/ai"
+	"perfolizer/pkg/core"
 	"perfolizer/pkg/elements"
 )
 
@@ -40,6 +41,27 @@ func TestTryGenerateRuleBasedDraftUsesSimpleThreadGroupForIterations(t *testing.
 	}
 }
 
+func TestTryGenerateRuleBasedDraftBuildsTestPlanRoot(t *testing.T) {
+	draft, matched, err := aipkg.TryGenerateRuleBasedDraft(aipkg.WorkloadBrief{
+		URL:  "https://example.com/api/orders",
+		Goal: "Create a test plan with 10 iterations for this link",
+	})
+	if err != nil || !matched {
+		t.Fatalf("expected rule-based draft generation to match, got matched=%v err=%v", matched, err)
+	}
+	if _, ok := draft.Root.Props["SequentialThreadGroups"]; draft.Root.Type != "TestPlan" || !ok {
+		t.Fatalf("expected a TestPlan root with its props, got %s %v", draft.Root.Type, draft.Root.Props)
+	}
+
+	root, err := aipkg.ValidateDraft(draft)
+	if err != nil {
+		t.Fatalf("ValidateDraft returned error: %v", err)
+	}
+	if _, ok := root.(*core.TestPlan); !ok {
+		t.Fatalf("expected *core.TestPlan root, got %T", root)
+	}
+}
+
 func TestTryGenerateRuleBasedDraftUsesRPSThreadGroupForTargetRPS(t *testing.T) {
 	draft, matched, err := aipkg.TryGenerateRuleBasedDraft(aipkg.WorkloadBrief{
 		URL:  "https://example.com/api/search",
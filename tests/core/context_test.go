@@ -2,6 +2,7 @@ package core_test
 
 import (
 	"context"
+	"sync"
 	"testing"
 	"time"
 
@@ -94,3 +95,144 @@ func TestContextAccessorsAndSampleResultDuration(t *testing.T) {
 		t.Fatalf("expected duration %v, got %v", 350*time.Millisecond, got)
 	}
 }
+
+func TestContextForkJoinPublishesOnlyWrittenVariables(t *testing.T) {
+	parent := core.NewContext(context.Background(), 3)
+	parent.Iteration = 4
+	parent.SetVar("shared", "before")
+	parent.SetVar("untouched", "parent")
+
+	branch := parent.Fork()
+	if branch.ThreadID != 3 || branch.Iteration != 4 || branch.GetVar("shared") != "before" {
+		t.Fatalf("expected fork to copy thread state, got thread=%d iteration=%d shared=%v", branch.ThreadID, branch.Iteration, branch.GetVar("shared"))
+	}
+
+	parent.SetVar("untouched", "changed meanwhile")
+	branch.SetVar("shared", "after")
+	branch.SetVar("fresh", 1)
+	now := time.Now()
+	branch.SetLastSample(&core.SampleResult{SamplerName: "branch", EndTime: now})
+
+	parent.Join(branch)
+
+	if parent.GetVar("shared") != "after" || parent.GetVar("fresh") != 1 {
+		t.Fatalf("expected written variables to be joined, got shared=%v fresh=%v", parent.GetVar("shared"), parent.GetVar("fresh"))
+	}
+	if parent.GetVar("untouched") != "changed meanwhile" {
+		t.Fatalf("expected unwritten variable to keep parent value, got %v", parent.GetVar("untouched"))
+	}
+	if parent.LastSample() == nil || parent.LastSample().SamplerName != "branch" {
+		t.Fatalf("expected branch sample to become last sample, got %+v", parent.LastSample())
+	}
+}
+
+func TestContextUserVariablesLeaveOutThreadState(t *testing.T) {
+	parent := core.NewContext(context.Background(), 1)
+	parent.SetStateVar("Reporter", "runner")
+	parent.SetVar("token", "abc")
+
+	branch := parent.Fork()
+	branch.SetStateVar("OnceOnly_1", true)
+	branch.SetVar("user", "alice")
+	branch.SetVar("Reporter", "overridden")
+
+	exports := core.NewContext(context.Background(), 0)
+	exports.Join(branch)
+
+	got := exports.UserVariables()
+	if len(got) != 2 || got["user"] != "alice" || got["Reporter"] != "overridden" {
+		t.Fatalf("expected only user variables to be listed, got %v", got)
+	}
+	if exports.GetVar("OnceOnly_1") != true {
+		t.Fatalf("expected thread state to still be joined, got %v", exports.GetVar("OnceOnly_1"))
+	}
+}
+
+func TestContextFallsBackToGlobals(t *testing.T) {
+	source := map[string]interface{}{"token": "abc", "region": "eu"}
+	globals := core.NewGlobals(source)
+	source["token"] = "mutated"
+
+	ctx := core.NewContext(core.WithGlobals(context.Background(), globals), 1)
+	ctx.SetVar("region", "us")
+
+	if got := ctx.Substitute("${token}/${region}/${missing}"); got != "abc/us/${missing}" {
+		t.Fatalf("expected thread vars to win over globals, got %q", got)
+	}
+	if ctx.GetVar("token") != "abc" {
+		t.Fatalf("expected GetVar to fall back to globals, got %v", ctx.GetVar("token"))
+	}
+
+	ctx.SetVar("token", "local")
+	if value, _ := globals.Get("token"); value != "abc" {
+		t.Fatalf("expected thread vars not to write globals, got %v", value)
+	}
+	if _, ok := (*core.Globals)(nil).Get("token"); ok {
+		t.Fatal("expected nil globals to report no value")
+	}
+}
+
+func TestContextScopedVariablesPrecedence(t *testing.T) {
+	globals := core.NewGlobals(nil)
+	group := core.NewGlobals(nil)
+	run := core.WithGlobals(context.Background(), globals)
+	first := core.NewContext(core.WithGroupVariables(run, group), 1)
+	second := core.NewContext(core.WithGroupVariables(run, group), 2)
+	otherGroup := core.NewContext(core.WithGroupVariables(run, core.NewGlobals(nil)), 3)
+
+	first.SetScopedVar(core.VarScopeGlobal, "name", "global")
+	if got := otherGroup.Substitute("${name}"); got != "global" {
+		t.Fatalf("expected a global written by one thread to reach every group, got %q", got)
+	}
+
+	first.SetScopedVar(core.VarScopeGroup, "name", "group")
+	if got := second.Substitute("${name}"); got != "group" {
+		t.Fatalf("expected group variables to win over globals, got %q", got)
+	}
+	if got := otherGroup.Substitute("${name}"); got != "global" {
+		t.Fatalf("expected group variables to stay in their group, got %q", got)
+	}
+
+	first.SetScopedVar(core.VarScopeThread, "name", "thread")
+	if first.GetVar("name") != "thread" || second.GetVar("name") != "group" {
+		t.Fatalf("expected thread variables to win only in their thread, got %v and %v", first.GetVar("name"), second.GetVar("name"))
+	}
+
+	if first.SetScopedVarIfAbsent(core.VarScopeGlobal, "name", "default") {
+		t.Fatal("expected a default not to replace a visible value")
+	}
+	if !second.SetScopedVarIfAbsent(core.VarScopeGroup, "fresh", "default") || second.SetScopedVarIfAbsent(core.VarScopeGroup, "fresh", "again") {
+		t.Fatal("expected a shared default to be stored once")
+	}
+	if first.GetVar("fresh") != "default" {
+		t.Fatalf("expected the group default to be shared, got %v", first.GetVar("fresh"))
+	}
+
+	standalone := core.NewContext(context.Background(), 1)
+	standalone.SetScopedVar(core.VarScopeGlobal, "name", "kept")
+	if standalone.GetVar("name") != "kept" {
+		t.Fatalf("expected a scoped variable without a store to stay with the thread, got %v", standalone.GetVar("name"))
+	}
+}
+
+func TestGlobalsUpdateIsAtomic(t *testing.T) {
+	globals := core.NewGlobals(nil)
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				globals.Update("hits", func(old interface{}, ok bool) interface{} {
+					n, _ := old.(int)
+					return n + 1
+				})
+			}
+		}()
+	}
+	wg.Wait()
+
+	if hits, _ := globals.Get("hits"); hits != 2000 {
+		t.Fatalf("expected 2000 updates, got %v", hits)
+	}
+}
@@ -0,0 +1,125 @@
+package core_test
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"perfolizer/pkg/core"
+)
+
+func TestSubstituteBuiltInFunctions(t *testing.T) {
+	ctx := core.NewContext(context.Background(), 3)
+	ctx.Iteration = 5
+	ctx.SetVar("user", "alice")
+	ctx.SetVar("pass", "s3cret")
+	t.Setenv("PERFOLIZER_TEST_HOST", "example.com")
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"${__threadNum()}-${__iteration()}", "3-5"},
+		{"${__base64(${user}:${pass})}", base64.StdEncoding.EncodeToString([]byte("alice:s3cret"))},
+		{"${__base64(a,b)}", base64.StdEncoding.EncodeToString([]byte("a,b"))},
+		{"q=${__urlencode(a b&c)}", "q=a+b%26c"},
+		{"${__urlencode(${__base64(??)})}", "Pz8%3D"},
+		{"https://${__env(PERFOLIZER_TEST_HOST)}/", "https://example.com/"},
+		{"${__env(PERFOLIZER_TEST_MISSING,fallback)}", "fallback"},
+		{"${__randomInt(7,7)}", "7"},
+		{"${__randomString(4,x)}", "xxxx"},
+		{"${__nope()}", "${__nope()}"},
+		{"${__randomInt(9,1)}", "${__randomInt(9,1)}"},
+		{"${__randomInt(a\\,b)}", "${__randomInt(a\\,b)}"},
+	}
+	for _, tc := range tests {
+		if got := ctx.Substitute(tc.input); got != tc.expected {
+			t.Fatalf("Substitute(%q) = %q; want %q", tc.input, got, tc.expected)
+		}
+	}
+}
+
+func TestSubstituteNestedVariableNames(t *testing.T) {
+	ctx := core.NewContext(context.Background(), 2)
+	ctx.SetVar("user_2", "bob")
+	ctx.SetVar("n", 2)
+
+	if got := ctx.Substitute("${user_${n}}/${user_${__threadNum()}}/${user_${missing}}"); got != "bob/bob/${user_${missing}}" {
+		t.Fatalf("unexpected nested substitution %q", got)
+	}
+}
+
+func TestSubstituteRandomAndTimeFunctions(t *testing.T) {
+	ctx := core.NewContext(context.Background(), 1)
+
+	uuid := ctx.Substitute("${__uuid()}")
+	if !regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`).MatchString(uuid) {
+		t.Fatalf("expected a version 4 UUID, got %q", uuid)
+	}
+	if ctx.Substitute("${__uuid()}") == uuid {
+		t.Fatal("expected a new UUID on every call")
+	}
+
+	for i := 0; i < 50; i++ {
+		n, err := strconv.Atoi(ctx.Substitute("${__randomInt(1, 100)}"))
+		if err != nil || n < 1 || n > 100 {
+			t.Fatalf("expected an integer between 1 and 100, got %d (%v)", n, err)
+		}
+	}
+	// Ranges wider than MaxInt64 must not overflow
+	for _, input := range []string{"${__randomInt(-1,9223372036854775807)}", "${__randomInt(-9223372036854775808,9223372036854775807)}"} {
+		if _, err := strconv.ParseInt(ctx.Substitute(input), 10, 64); err != nil {
+			t.Fatalf("expected %s to produce an integer, got %v", input, err)
+		}
+	}
+	if s := ctx.Substitute("${__randomString(12,abc)}"); len(s) != 12 || strings.Trim(s, "abc") != "" {
+		t.Fatalf("expected 12 characters from abc, got %q", s)
+	}
+
+	before := time.Now().UnixMilli()
+	ms, err := strconv.ParseInt(ctx.Substitute("${__time(unix_ms)}"), 10, 64)
+	if err != nil || ms < before || ms > time.Now().UnixMilli() {
+		t.Fatalf("expected the current unix time in ms, got %d (%v)", ms, err)
+	}
+	if got := ctx.Substitute("${__time(2006)}"); got != strconv.Itoa(time.Now().Year()) {
+		t.Fatalf("expected a Go layout to format the time, got %q", got)
+	}
+}
+
+func TestSubstituteCounterIsRunWideOrPerThread(t *testing.T) {
+	run := core.WithRunStore(context.Background(), core.NewRunStore())
+	first := core.NewContext(run, 1)
+	second := core.NewContext(run, 2)
+
+	got := []string{
+		first.Substitute("${__counter()}"),
+		second.Substitute("${__counter(FALSE)}"),
+		first.Substitute("${__counter()}"),
+		first.Substitute("${__counter(TRUE)}"),
+		second.Substitute("${__counter(TRUE)}"),
+		first.Substitute("${__counter(TRUE)}"),
+	}
+	if strings.Join(got, ",") != "1,2,3,1,1,2" {
+		t.Fatalf("unexpected counter values %v", got)
+	}
+}
+
+func TestRegisterFunctionAddsCustomFunctions(t *testing.T) {
+	core.RegisterFunction("testJoin", func(ctx *core.Context, args []string) (string, error) {
+		return fmt.Sprintf("%d:%s", len(args), strings.Join(args, "|")), nil
+	})
+	ctx := core.NewContext(context.Background(), 1)
+	ctx.SetVar("x", "1,2")
+
+	if got := ctx.Substitute("${__testJoin(a\\,b,${x},${__testJoin(c,d)})}"); got != "3:a,b|1,2|2:c|d" {
+		t.Fatalf("unexpected custom function result %q", got)
+	}
+	if got := ctx.Substitute("${__testJoin()}"); got != "0:" {
+		t.Fatalf("expected no arguments for an empty call, got %q", got)
+	}
+}
@@ -81,6 +81,7 @@ func TestWriteReadProjectRoundTrip(t *testing.T) {
 		Name:  "token",
 		Type:  core.ParamTypeStatic,
 		Value: "abc",
+		Scope: core.VarScopeGlobal,
 	}}
 
 	var buf bytes.Buffer
@@ -131,6 +132,9 @@ func TestWriteReadProjectRoundTrip(t *testing.T) {
 	if loaded.Plans[0].Parameters[0].Name != "token" {
 		t.Fatalf("expected parameter name %q, got %q", "token", loaded.Plans[0].Parameters[0].Name)
 	}
+	if loaded.Plans[0].Parameters[0].Scope != core.VarScopeGlobal {
+		t.Fatalf("expected parameter scope %q, got %q", core.VarScopeGlobal, loaded.Plans[0].Parameters[0].Scope)
+	}
 }
 
 func TestSaveAndLoadProjectFromFile(t *testing.T) {
@@ -227,6 +231,102 @@ func TestThreadGroupHTTPSettingsPersistAcrossMarshalRoundTrip(t *testing.T) {
 	}
 }
 
+func TestSimpleThreadGroupLoadShapePersistsAcrossMarshalRoundTrip(t *testing.T) {
+	root := core.NewBaseElement("Test Plan")
+	tg := elements.NewSimpleThreadGroup("TG", 20, -1)
+	tg.RampUp = 30 * time.Second
+	tg.Hold = 5 * time.Minute
+	tg.RampDown = 10 * time.Second
+	root.AddChild(tg)
+
+	payload, err := core.MarshalTestPlan(&root)
+	if err != nil {
+		t.Fatalf("MarshalTestPlan failed: %v", err)
+	}
+
+	loaded, err := core.UnmarshalTestPlan(payload)
+	if err != nil {
+		t.Fatalf("UnmarshalTestPlan failed: %v", err)
+	}
+
+	loadedTG, ok := loaded.GetChildren()[0].(*elements.SimpleThreadGroup)
+	if !ok {
+		t.Fatalf("expected simple thread group, got %T", loaded.GetChildren()[0])
+	}
+	if loadedTG.RampUp != 30*time.Second || loadedTG.Hold != 5*time.Minute || loadedTG.RampDown != 10*time.Second {
+		t.Fatalf("expected ramp-up/hold/ramp-down to survive round-trip, got %v/%v/%v", loadedTG.RampUp, loadedTG.Hold, loadedTG.RampDown)
+	}
+}
+
+func TestSetupAndTeardownThreadGroupsPersistAcrossMarshalRoundTrip(t *testing.T) {
+	root := core.NewBaseElement("Test Plan")
+	setup := elements.NewSetupThreadGroup("Setup")
+	setup.Users = 2
+	root.AddChild(setup)
+	teardown := elements.NewTeardownThreadGroup("Teardown")
+	teardown.Timeout = 45 * time.Second
+	root.AddChild(teardown)
+
+	payload, err := core.MarshalTestPlan(&root)
+	if err != nil {
+		t.Fatalf("MarshalTestPlan failed: %v", err)
+	}
+
+	loaded, err := core.UnmarshalTestPlan(payload)
+	if err != nil {
+		t.Fatalf("UnmarshalTestPlan failed: %v", err)
+	}
+
+	loadedSetup, ok := loaded.GetChildren()[0].(*elements.SetupThreadGroup)
+	if !ok {
+		t.Fatalf("expected setup thread group, got %T", loaded.GetChildren()[0])
+	}
+	if loadedSetup.Users != 2 || core.ThreadGroupRunPhase(loadedSetup) != core.RunPhaseSetup {
+		t.Fatalf("unexpected setup thread group %+v", loadedSetup)
+	}
+	loadedTeardown, ok := loaded.GetChildren()[1].(*elements.TeardownThreadGroup)
+	if !ok {
+		t.Fatalf("expected teardown thread group, got %T", loaded.GetChildren()[1])
+	}
+	if loadedTeardown.Timeout != 45*time.Second || core.ThreadGroupRunPhase(loadedTeardown) != core.RunPhaseTeardown {
+		t.Fatalf("unexpected teardown thread group %+v", loadedTeardown)
+	}
+}
+
+func TestTestPlanSettingsAndThreadGroupWindowsPersistAcrossMarshalRoundTrip(t *testing.T) {
+	plan := core.NewTestPlan("Test Plan")
+	plan.SequentialThreadGroups = true
+	tg := elements.NewRPSThreadGroup("Load", 10)
+	tg.StartDelay = 2 * time.Minute
+	tg.Duration = 10 * time.Minute
+	plan.AddChild(tg)
+
+	payload, err := core.MarshalTestPlan(plan)
+	if err != nil {
+		t.Fatalf("MarshalTestPlan failed: %v", err)
+	}
+
+	loaded, err := core.UnmarshalTestPlan(payload)
+	if err != nil {
+		t.Fatalf("UnmarshalTestPlan failed: %v", err)
+	}
+	if !core.RunsThreadGroupsSequentially(loaded) {
+		t.Fatalf("expected sequential thread groups to survive round-trip, got %T %+v", loaded, loaded)
+	}
+	loadedTG, ok := loaded.GetChildren()[0].(*elements.RPSThreadGroup)
+	if !ok {
+		t.Fatalf("expected RPS thread group, got %T", loaded.GetChildren()[0])
+	}
+	if loadedTG.StartDelay != 2*time.Minute || loadedTG.Duration != 10*time.Minute {
+		t.Fatalf("expected start delay/duration to survive round-trip, got %v/%v", loadedTG.StartDelay, loadedTG.Duration)
+	}
+
+	legacy := core.NewBaseElement("Legacy")
+	if core.RunsThreadGroupsSequentially(&legacy) {
+		t.Fatal("expected plain roots to start thread groups together")
+	}
+}
+
 func TestSaveAndLoadTestPlanFromFile(t *testing.T) {
 	root := core.NewBaseElement("Plan Root")
 	path := filepath.Join(t.TempDir(), "plan.json")
@@ -261,6 +361,7 @@ func TestPropertyGetterHelpers(t *testing.T) {
 		"b_true":          true,
 		"map_interface":   map[string]interface{}{"a": "1", "skip": 2},
 		"map_string":      map[string]string{"b": "2"},
+		"map_float":       map[string]interface{}{"w": float64(70), "n": 3, "skip": "x"},
 		"slice_interface": []interface{}{"x", 2, "y"},
 		"slice_string":    []string{"k", "v"},
 		"params_interface": []interface{}{
@@ -270,6 +371,7 @@ func TestPropertyGetterHelpers(t *testing.T) {
 				"Value":      "abc",
 				"Type":       core.ParamTypeJSON,
 				"Expression": "$.token",
+				"Scope":      core.VarScopeGroup,
 			},
 		},
 		"params_typed": []core.Parameter{{ID: "p2", Name: "id", Type: core.ParamTypeStatic, Value: "42"}},
@@ -320,6 +422,14 @@ func TestPropertyGetterHelpers(t *testing.T) {
 		t.Fatalf("GetStringMap(missing) expected nil, got %#v", got)
 	}
 
+	f1 := core.GetFloatMap(props, "map_float")
+	if len(f1) != 2 || f1["w"] != 70 || f1["n"] != 3 {
+		t.Fatalf("GetFloatMap(interface) returned %#v", f1)
+	}
+	if got := core.GetFloatMap(props, "missing"); got != nil {
+		t.Fatalf("GetFloatMap(missing) expected nil, got %#v", got)
+	}
+
 	s1 := core.GetStringSlice(props, "slice_interface")
 	if len(s1) != 2 || s1[0] != "x" || s1[1] != "y" {
 		t.Fatalf("GetStringSlice(interface) returned %#v", s1)
@@ -333,7 +443,7 @@ func TestPropertyGetterHelpers(t *testing.T) {
 	}
 
 	p1 := core.GetParameters(props, "params_interface")
-	if len(p1) != 1 || p1[0].Expression != "$.token" {
+	if len(p1) != 1 || p1[0].Expression != "$.token" || p1[0].Scope != core.VarScopeGroup {
 		t.Fatalf("GetParameters(interface) returned %#v", p1)
 	}
 	p2 := core.GetParameters(props, "params_typed")
@@ -76,3 +76,210 @@ func TestStatsRunnerPublishesMetrics(t *testing.T) {
 		t.Fatalf("expected snapshot copy to include total requests 2, got %d", copied["Total"].TotalRequests)
 	}
 }
+
+func TestStatsRunnerExcludesTransactionSamplesFromTotal(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	updates := make(chan map[string]core.Metric, 4)
+	runner := core.NewStatsRunner(ctx, func(data map[string]core.Metric) {
+		select {
+		case updates <- data:
+		default:
+		}
+	})
+
+	start := time.Now()
+	runner.ReportResult(&core.SampleResult{SamplerName: "Cart", StartTime: start, EndTime: start.Add(10 * time.Millisecond), Success: true})
+	runner.ReportResult(&core.SampleResult{SamplerName: "Pay", StartTime: start, EndTime: start.Add(10 * time.Millisecond), Success: true})
+	runner.ReportResult(&core.SampleResult{SamplerName: "Checkout", StartTime: start, EndTime: start.Add(20 * time.Millisecond), Success: true, Kind: core.SampleTransaction})
+
+	var snapshot map[string]core.Metric
+	select {
+	case snapshot = <-updates:
+	case <-time.After(2500 * time.Millisecond):
+		t.Fatal("timed out waiting for stats update")
+	}
+
+	if got := snapshot["Checkout"].TotalRequests; got != 1 {
+		t.Fatalf("expected transaction series with 1 request, got %d", got)
+	}
+	if got := snapshot["Total"].TotalRequests; got != 2 {
+		t.Fatalf("expected transaction sample to be excluded from total, got %d", got)
+	}
+}
+
+func TestStatsRunnerCountsDroppedIterationsAsTotalErrors(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	updates := make(chan map[string]core.Metric, 4)
+	runner := core.NewStatsRunner(ctx, func(data map[string]core.Metric) {
+		select {
+		case updates <- data:
+		default:
+		}
+	})
+
+	start := time.Now()
+	runner.ReportResult(&core.SampleResult{SamplerName: "Home", StartTime: start, EndTime: start.Add(10 * time.Millisecond), Success: true})
+	runner.ReportResult(&core.SampleResult{SamplerName: "Open dropped iterations", StartTime: start, EndTime: start, Error: errors.New("iteration dropped"), Kind: core.SampleDroppedIteration})
+	runner.ReportResult(&core.SampleResult{SamplerName: "Checkout lock wait", StartTime: start, EndTime: start.Add(5 * time.Millisecond), Success: true, Kind: core.SampleLockWait})
+
+	var snapshot map[string]core.Metric
+	select {
+	case snapshot = <-updates:
+	case <-time.After(2500 * time.Millisecond):
+		t.Fatal("timed out waiting for stats update")
+	}
+
+	total := snapshot["Total"]
+	if total.TotalRequests != 1 || total.TotalErrors != 1 || total.Errors != 1 {
+		t.Fatalf("expected one request and one dropped iteration error in total, got %+v", total)
+	}
+}
+
+func TestStatsRunnerPublishesActiveUsersGaugeOnTotal(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	updates := make(chan map[string]core.Metric, 4)
+	runner := core.NewStatsRunner(ctx, func(data map[string]core.Metric) {
+		select {
+		case updates <- data:
+		default:
+		}
+	})
+
+	var reporter core.ActiveUsersReporter = runner
+	reporter.SetActiveUsers("group-a", 4)
+	reporter.SetActiveUsers("group-b", 3)
+	reporter.SetActiveUsers("group-a", 2)
+
+	if got := runner.ActiveUsers(); len(got) != 2 || got["group-a"] != 2 || got["group-b"] != 3 {
+		t.Fatalf("expected active users by group, got %v", got)
+	}
+
+	select {
+	case snapshot := <-updates:
+		if snapshot["Total"].ActiveUsers != 5 {
+			t.Fatalf("expected 5 active users across groups, got %d", snapshot["Total"].ActiveUsers)
+		}
+	case <-time.After(2500 * time.Millisecond):
+		t.Fatal("timed out waiting for stats update")
+	}
+}
+
+func TestStatsRunnerPublishesP95LatencyAndSustainableRate(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	updates := make(chan map[string]core.Metric, 4)
+	runner := core.NewStatsRunner(ctx, func(data map[string]core.Metric) {
+		select {
+		case updates <- data:
+		default:
+		}
+	})
+
+	start := time.Now()
+	for i := 1; i <= 20; i++ {
+		runner.ReportResult(&core.SampleResult{SamplerName: "Fast", StartTime: start, EndTime: start.Add(time.Duration(i) * time.Millisecond), Success: true})
+	}
+	runner.ReportResult(&core.SampleResult{SamplerName: "Slow", StartTime: start, EndTime: start.Add(500 * time.Millisecond), Success: true})
+
+	var reporter core.SustainableRateReporter = runner
+	reporter.SetSustainableRPS("group-a", 120)
+	reporter.SetSustainableRPS("group-b", 80)
+
+	var stats core.LiveStatsProvider = runner
+	select {
+	case <-updates:
+	case <-time.After(2500 * time.Millisecond):
+		t.Fatal("timed out waiting for stats update")
+	}
+	snapshot := stats.Snapshot()
+
+	if got := snapshot["Fast"].P95Latency; got != 19 {
+		t.Fatalf("expected nearest-rank p95 of 19ms, got %v", got)
+	}
+	if got := snapshot["Total"].P95Latency; got != 20 {
+		t.Fatalf("expected total p95 of 20ms over 21 samples, got %v", got)
+	}
+	if got := snapshot["Total"].SustainableRPS; got != 120 {
+		t.Fatalf("expected the highest sustainable rate on Total, got %v", got)
+	}
+}
+
+func TestStatsRunnerPublishesRequestSeriesPerThreadGroup(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	updates := make(chan map[string]core.Metric, 4)
+	runner := core.NewStatsRunner(ctx, func(data map[string]core.Metric) {
+		select {
+		case updates <- data:
+		default:
+		}
+	})
+
+	start := time.Now()
+	runner.ReportResult(&core.SampleResult{SamplerName: "Login", StartTime: start, EndTime: start.Add(10 * time.Millisecond), Success: true, ThreadGroup: "a"})
+	runner.ReportResult(&core.SampleResult{SamplerName: "Login", StartTime: start, EndTime: start.Add(10 * time.Millisecond), Success: true, ThreadGroup: "a"})
+	runner.ReportResult(&core.SampleResult{SamplerName: "Flow", StartTime: start, EndTime: start.Add(30 * time.Millisecond), Success: true, ThreadGroup: "a", Kind: core.SampleTransaction})
+	runner.ReportResult(&core.SampleResult{SamplerName: "Login", StartTime: start, EndTime: start.Add(900 * time.Millisecond), Success: false, ThreadGroup: "b"})
+
+	select {
+	case <-updates:
+	case <-time.After(2500 * time.Millisecond):
+		t.Fatal("timed out waiting for stats update")
+	}
+
+	var stats core.LiveStatsProvider = runner
+	a := stats.GroupSnapshot("a")
+	if len(a) != 1 || a["Login"].TotalRequests != 2 || a["Login"].TotalErrors != 0 || a["Login"].P95Latency != 10 {
+		t.Fatalf("expected only group a's requests in its series, got %+v", a)
+	}
+	b := stats.GroupSnapshot("b")
+	if b["Login"].TotalRequests != 1 || b["Login"].TotalErrors != 1 || b["Login"].P95Latency != 900 {
+		t.Fatalf("expected group b's failed request in its series, got %+v", b)
+	}
+	if got := stats.Snapshot()["Login"].TotalRequests; got != 3 {
+		t.Fatalf("expected the run-wide series to keep every request, got %d", got)
+	}
+	if len(stats.GroupSnapshot("missing")) != 0 {
+		t.Fatal("expected no series for an unknown group")
+	}
+}
+
+func TestStatsRunnerCountsEachSampleByItsOwnKind(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	updates := make(chan map[string]core.Metric, 4)
+	runner := core.NewStatsRunner(ctx, func(data map[string]core.Metric) {
+		select {
+		case updates <- data:
+		default:
+		}
+	})
+
+	// A sampler and a transaction share a name; the transaction reports last
+	start := time.Now()
+	runner.ReportResult(&core.SampleResult{SamplerName: "Login", StartTime: start, EndTime: start.Add(10 * time.Millisecond), Success: true})
+	runner.ReportResult(&core.SampleResult{SamplerName: "Login", StartTime: start, EndTime: start.Add(20 * time.Millisecond), Success: true, Kind: core.SampleTransaction})
+
+	var snapshot map[string]core.Metric
+	select {
+	case snapshot = <-updates:
+	case <-time.After(2500 * time.Millisecond):
+		t.Fatal("timed out waiting for stats update")
+	}
+
+	if got := snapshot["Login"].TotalRequests; got != 2 {
+		t.Fatalf("expected both samples in the Login series, got %d", got)
+	}
+	if got := snapshot["Total"].TotalRequests; got != 1 {
+		t.Fatalf("expected only the request in the total, got %d", got)
+	}
+}
@@ -48,6 +48,33 @@ func TestValidateTestPlanRejectsInvalidValues(t *testing.T) {
 			child:    elements.NewSimpleThreadGroup("Broken Iterations", 1, -2),
 			contains: []string{`Simple Thread Group "Broken Iterations"`, "Iterations must be greater than or equal to -1"},
 		},
+		{
+			name: "simple ramp-down without hold",
+			child: func() core.TestElement {
+				tg := elements.NewSimpleThreadGroup("Ramp Down Only", 1, -1)
+				tg.RampDown = time.Second
+				return tg
+			}(),
+			contains: []string{`Simple Thread Group "Ramp Down Only"`, "Ramp-down requires a hold duration"},
+		},
+		{
+			name: "negative thread group start delay",
+			child: func() core.TestElement {
+				tg := elements.NewUltimateThreadGroup("Late")
+				tg.StartDelay = -time.Second
+				return tg
+			}(),
+			contains: []string{`Ultimate Thread Group "Late"`, "Start delay must be greater than or equal to 0"},
+		},
+		{
+			name: "zero teardown timeout",
+			child: func() core.TestElement {
+				tg := elements.NewTeardownThreadGroup("Cleanup")
+				tg.Timeout = 0
+				return tg
+			}(),
+			contains: []string{`Teardown Thread Group "Cleanup"`, "Timeout must be greater than 0 ms"},
+		},
 		{
 			name: "negative sampler rps",
 			child: func() core.TestElement {
@@ -0,0 +1,422 @@
+package elements_test
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"perfolizer/pkg/core"
+	"perfolizer/pkg/elements"
+)
+
+// rowRecorder returns an element that records the value of vars on every run.
+func rowRecorder(vars ...string) (*countingElement, func() []string) {
+	var mu sync.Mutex
+	var rows []string
+	el := newCountingElement("Record")
+	el.onRun = func(ctx *core.Context) error {
+		values := make([]string, len(vars))
+		for i, name := range vars {
+			values[i] = ctx.Substitute("${" + name + "}")
+		}
+		mu.Lock()
+		rows = append(rows, strings.Join(values, "|"))
+		mu.Unlock()
+		return nil
+	}
+	return el, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), rows...)
+	}
+}
+
+func TestCSVDataSetReadsRowsWithHeaderAndRecycles(t *testing.T) {
+	data := elements.NewCSVDataSet("Users", "users.csv")
+	data.Delimiter = ";"
+	data.HeaderRow = true
+	data.Data = "user;pass\nalice;\"a;1\"\nbob;b2\n"
+	data.Bundled = true
+
+	record, rows := rowRecorder("user", "pass")
+	tg := elements.NewSimpleThreadGroup("Group", 1, 3)
+	tg.AddChild(data)
+	tg.AddChild(record)
+	tg.Start(context.Background(), noopRunner{})
+
+	want := []string{"alice|a;1", "bob|b2", "alice|a;1"}
+	if got := rows(); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("expected rows %v, got %v", want, got)
+	}
+}
+
+func TestCSVDataSetSharingModes(t *testing.T) {
+	cases := []struct {
+		sharing string
+		want    []string
+	}{
+		{elements.CSVSharingAll, []string{"1", "2", "3", "4"}},
+		{elements.CSVSharingGroup, []string{"1", "1", "2", "2"}},
+		{elements.CSVSharingThread, []string{"1", "1", "1", "1"}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.sharing, func(t *testing.T) {
+			plan := core.NewTestPlan("Plan")
+			var collect []func() []string
+			for _, name := range []string{"A", "B"} {
+				data := elements.NewCSVDataSet("IDs", "ids.csv")
+				data.VariableNames = "id"
+				data.Sharing = tc.sharing
+				data.Data = "1\n2\n3\n4\n"
+				data.Bundled = true
+				record, rows := rowRecorder("id")
+				tg := elements.NewSimpleThreadGroup(name, 2, 1)
+				tg.AddChild(data)
+				tg.AddChild(record)
+				plan.AddChild(tg)
+				collect = append(collect, rows)
+			}
+
+			ctx := core.WithRunStore(context.Background(), core.NewRunStore())
+			for _, child := range plan.GetChildren() {
+				child.(core.ThreadGroup).Start(ctx, noopRunner{})
+			}
+
+			var got []string
+			for _, rows := range collect {
+				got = append(got, rows()...)
+			}
+			sort.Strings(got)
+			if strings.Join(got, ",") != strings.Join(tc.want, ",") {
+				t.Fatalf("expected rows %v, got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestCSVDataSetSharesRunCursorOnlyWithTheSameParsing(t *testing.T) {
+	plan := core.NewTestPlan("Plan")
+	var collect []func() []string
+	for _, header := range []bool{true, false} {
+		data := elements.NewCSVDataSet("IDs", "ids.csv")
+		data.VariableNames = "id"
+		data.HeaderRow = header
+		data.Data = "id\n1\n2\n"
+		data.Bundled = true
+		record, rows := rowRecorder("id")
+		tg := elements.NewSimpleThreadGroup("Users", 1, 1)
+		tg.AddChild(data)
+		tg.AddChild(record)
+		plan.AddChild(tg)
+		collect = append(collect, rows)
+	}
+
+	ctx := core.WithRunStore(context.Background(), core.NewRunStore())
+	for _, child := range plan.GetChildren() {
+		child.(core.ThreadGroup).Start(ctx, noopRunner{})
+	}
+
+	// Row 0 is "1" with a header row and "id" without one, so each keeps its own cursor
+	withHeader, withoutHeader := collect[0](), collect[1]()
+	if len(withHeader) != 1 || withHeader[0] != "1" || len(withoutHeader) != 1 || withoutHeader[0] != "id" {
+		t.Fatalf("expected each data set to start at its first row, got %v and %v", withHeader, withoutHeader)
+	}
+}
+
+func TestCSVDataSetEndOfFile(t *testing.T) {
+	data := elements.NewCSVDataSet("IDs", "ids.csv")
+	data.VariableNames = "id"
+	data.RecycleOnEOF = false
+	data.Data = "1\n2\n"
+	data.Bundled = true
+
+	record, rows := rowRecorder("id")
+	tg := elements.NewSimpleThreadGroup("Group", 1, 3)
+	tg.AddChild(data)
+	tg.AddChild(record)
+	tg.Start(context.Background(), noopRunner{})
+
+	if got := strings.Join(rows(), ","); got != "1,2,<EOF>" {
+		t.Fatalf("expected the variables to read <EOF> after the last row, got %s", got)
+	}
+
+	data = elements.NewCSVDataSet("IDs", "ids.csv")
+	data.VariableNames = "id"
+	data.RecycleOnEOF = false
+	data.StopThreadOnEOF = true
+	data.Data = "1\n2\n"
+	data.Bundled = true
+
+	record, rows = rowRecorder("id")
+	tg = elements.NewSimpleThreadGroup("Group", 1, -1)
+	tg.AddChild(data)
+	tg.AddChild(record)
+	tg.Start(context.Background(), noopRunner{})
+
+	if got := strings.Join(rows(), ","); got != "1,2" {
+		t.Fatalf("expected the thread to stop at the end of the file, got %s", got)
+	}
+}
+
+func TestBundleDataFilesEmbedsProjectRelativeFiles(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "data"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "data", "users.csv"), []byte("alice\nbob\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	data := elements.NewCSVDataSet("Users", filepath.Join("data", "users.csv"))
+	data.VariableNames = "user"
+	tg := elements.NewSimpleThreadGroup("Group", 1, 1)
+	tg.AddChild(data)
+	plan := core.NewTestPlan("Plan")
+	plan.AddChild(tg)
+
+	if err := elements.BundleDataFiles(plan, dir); err != nil {
+		t.Fatalf("BundleDataFiles returned error: %v", err)
+	}
+	loaded, ok := roundTripElement(t, data).(*elements.CSVDataSet)
+	if !ok || loaded.Data != "alice\nbob\n" || !loaded.Bundled {
+		t.Fatalf("expected the file contents to travel with the plan, got %+v", loaded)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "empty.csv"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	empty := elements.NewCSVDataSet("Empty", filepath.Join(dir, "empty.csv"))
+	empty.VariableNames = "user"
+	if err := elements.BundleDataFiles(empty, dir); err != nil {
+		t.Fatalf("BundleDataFiles returned error: %v", err)
+	}
+	empty, ok = roundTripElement(t, empty).(*elements.CSVDataSet)
+	if !ok || !empty.Bundled || empty.Data != "" {
+		t.Fatalf("expected an empty file to travel as bundled, got %+v", empty)
+	}
+	if err := os.Remove(filepath.Join(dir, "empty.csv")); err != nil {
+		t.Fatal(err)
+	}
+	record, rows := rowRecorder("user")
+	tg = elements.NewSimpleThreadGroup("Group", 1, 1)
+	tg.AddChild(empty)
+	tg.AddChild(record)
+	tg.Start(context.Background(), noopRunner{})
+	if got := strings.Join(rows(), ","); got != "<EOF>" {
+		t.Fatalf("expected the bundled empty file to be read without the disk, got %s", got)
+	}
+
+	missing := elements.NewCSVDataSet("Missing", "missing.csv")
+	if err := elements.BundleDataFiles(missing, dir); err == nil || !strings.Contains(err.Error(), "Missing") {
+		t.Fatalf("expected an error naming the data set, got %v", err)
+	}
+}
+
+func TestCSVDataSetPersistsAndValidates(t *testing.T) {
+	data := elements.NewCSVDataSet("Users", "users.csv")
+	data.Delimiter = `\t`
+	data.VariableNames = "user,pass"
+	data.Sharing = elements.CSVSharingThread
+	data.RecycleOnEOF = false
+	data.StopThreadOnEOF = true
+
+	loaded, ok := roundTripElement(t, data).(*elements.CSVDataSet)
+	if !ok {
+		t.Fatalf("expected CSVDataSet, got %T", roundTripElement(t, data))
+	}
+	if loaded.Filename != "users.csv" || loaded.Delimiter != `\t` || loaded.VariableNames != "user,pass" ||
+		loaded.Sharing != elements.CSVSharingThread || loaded.RecycleOnEOF || !loaded.StopThreadOnEOF || loaded.Data != "" || loaded.Bundled {
+		t.Fatalf("unexpected round-trip result %+v", loaded)
+	}
+	if err := loaded.Validate(); err != nil {
+		t.Fatalf("expected a valid data set, got %v", err)
+	}
+
+	loaded.Delimiter = ";;"
+	if err := loaded.Validate(); err == nil || !strings.Contains(err.Error(), "Delimiter") {
+		t.Fatalf("expected delimiter validation error, got %v", err)
+	}
+	loaded.Delimiter = ","
+	loaded.VariableNames = ""
+	if err := loaded.Validate(); err == nil || !strings.Contains(err.Error(), "Variable names are required") {
+		t.Fatalf("expected variable names validation error, got %v", err)
+	}
+	loaded.HeaderRow = true
+	loaded.Sharing = "Everyone"
+	if err := loaded.Validate(); err == nil || !strings.Contains(err.Error(), "Sharing must be one of") {
+		t.Fatalf("expected sharing validation error, got %v", err)
+	}
+}
+
+func TestCounterUpdatesVariablesBeforeEachSamplerInScope(t *testing.T) {
+	var mu sync.Mutex
+	var ids []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		ids = append(ids, r.URL.Query().Get("id"))
+		mu.Unlock()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	counter := elements.NewCounter("Order IDs", "order")
+	counter.Start = 8
+	counter.Increment = 2
+	counter.Max = 12
+	counter.Format = "ORD-%03d"
+
+	tg := elements.NewSimpleThreadGroup("Users", 1, 2)
+	tg.AddChild(counter)
+	tg.AddChild(&elements.HttpSampler{BaseElement: core.NewBaseElement("Create"), Method: "GET", Url: server.URL + "/?id=${order}"})
+	tg.AddChild(&elements.HttpSampler{BaseElement: core.NewBaseElement("Pay"), Method: "GET", Url: server.URL + "/?id=${order}"})
+	tg.Start(context.Background(), noopRunner{})
+
+	mu.Lock()
+	defer mu.Unlock()
+	if got := strings.Join(ids, ","); got != "ORD-008,ORD-010,ORD-012,ORD-008" {
+		t.Fatalf("expected the counter to step and wrap per sampler, got %s", got)
+	}
+}
+
+func TestCounterGlobalOrPerUser(t *testing.T) {
+	global := elements.NewCounter("Global", "n")
+	first, second := core.NewContext(context.Background(), 1), core.NewContext(context.Background(), 2)
+	var got []string
+	for _, ctx := range []*core.Context{first, second, first} {
+		if err := global.BeforeSample(ctx); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, ctx.Substitute("${n}"))
+	}
+	if strings.Join(got, ",") != "1,2,3" {
+		t.Fatalf("expected a global counter to be shared by threads, got %v", got)
+	}
+
+	perUser := elements.NewCounter("Per user", "n")
+	perUser.PerUser = true
+	perUser.ResetPerIteration = true
+	first, second = core.NewContext(context.Background(), 1), core.NewContext(context.Background(), 2)
+	got = nil
+	for _, step := range []struct {
+		ctx       *core.Context
+		iteration int
+	}{{first, 0}, {first, 0}, {second, 0}, {first, 1}} {
+		step.ctx.Iteration = step.iteration
+		if err := perUser.BeforeSample(step.ctx); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, step.ctx.Substitute("${n}"))
+	}
+	if strings.Join(got, ",") != "1,2,1,1" {
+		t.Fatalf("expected per-user counters reset each iteration, got %v", got)
+	}
+}
+
+func TestRandomVariableIsSeededPerThread(t *testing.T) {
+	draw := func(r *elements.RandomVariable, threadID int) []string {
+		ctx := core.NewContext(context.Background(), threadID)
+		var out []string
+		for i := 0; i < 20; i++ {
+			if err := r.BeforeSample(ctx); err != nil {
+				t.Fatal(err)
+			}
+			out = append(out, ctx.Substitute("${pick}"))
+		}
+		return out
+	}
+
+	r := elements.NewRandomVariable("Pick", "pick")
+	r.Min = 5
+	r.Max = 9
+	r.Format = "%02d"
+	r.Seed = 42
+	first := draw(r, 1)
+	for _, value := range first {
+		if value < "05" || value > "09" || len(value) != 2 {
+			t.Fatalf("expected formatted values between 05 and 09, got %v", first)
+		}
+	}
+	if again := draw(r, 1); strings.Join(again, ",") != strings.Join(first, ",") {
+		t.Fatalf("expected a seeded thread to repeat its sequence, got %v and %v", first, again)
+	}
+	if other := draw(r, 2); strings.Join(other, ",") == strings.Join(first, ",") {
+		t.Fatalf("expected another thread to draw its own sequence, got %v", other)
+	}
+}
+
+func TestRandomVariableDrawsFromTheFullInt64Range(t *testing.T) {
+	r := elements.NewRandomVariable("Pick", "pick")
+	r.Min = math.MinInt64
+	r.Max = math.MaxInt64
+	ctx := core.NewContext(context.Background(), 1)
+	for i := 0; i < 20; i++ {
+		if err := r.BeforeSample(ctx); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := strconv.ParseInt(ctx.Substitute("${pick}"), 10, 64); err != nil {
+			t.Fatalf("expected an int64, got %v", err)
+		}
+	}
+}
+
+func TestCounterAndRandomVariablePersistAndValidate(t *testing.T) {
+	counter := elements.NewCounter("IDs", "id")
+	counter.Start = 100
+	counter.Increment = -5
+	counter.Max = 50
+	counter.Format = "ID-%d"
+	counter.PerUser = true
+	counter.ResetPerIteration = true
+
+	loadedCounter, ok := roundTripElement(t, counter).(*elements.Counter)
+	if !ok {
+		t.Fatalf("expected Counter, got %T", roundTripElement(t, counter))
+	}
+	if loadedCounter.VariableName != "id" || loadedCounter.Start != 100 || loadedCounter.Increment != -5 || loadedCounter.Max != 50 ||
+		loadedCounter.Format != "ID-%d" || !loadedCounter.PerUser || !loadedCounter.ResetPerIteration {
+		t.Fatalf("unexpected round-trip result %+v", loadedCounter)
+	}
+	if err := loadedCounter.Validate(); err != nil {
+		t.Fatalf("expected a valid counter, got %v", err)
+	}
+	loadedCounter.Max = 150
+	if err := loadedCounter.Validate(); err == nil || !strings.Contains(err.Error(), "Max must be less than or equal to start") {
+		t.Fatalf("expected max validation error, got %v", err)
+	}
+	loadedCounter.Max = 0
+	loadedCounter.Format = "ID-%s"
+	if err := loadedCounter.Validate(); err == nil || !strings.Contains(err.Error(), "Format must contain one integer verb") {
+		t.Fatalf("expected format validation error, got %v", err)
+	}
+
+	random := elements.NewRandomVariable("Pick", "pick")
+	random.Min = -3
+	random.Max = 3
+	random.Format = "%+d"
+	random.Seed = 7
+
+	loadedRandom, ok := roundTripElement(t, random).(*elements.RandomVariable)
+	if !ok {
+		t.Fatalf("expected RandomVariable, got %T", roundTripElement(t, random))
+	}
+	if loadedRandom.VariableName != "pick" || loadedRandom.Min != -3 || loadedRandom.Max != 3 || loadedRandom.Format != "%+d" || loadedRandom.Seed != 7 {
+		t.Fatalf("unexpected round-trip result %+v", loadedRandom)
+	}
+	loadedRandom.Max = -4
+	if err := loadedRandom.Validate(); err == nil || !strings.Contains(err.Error(), "Max must be greater than or equal to min") {
+		t.Fatalf("expected min/max validation error, got %v", err)
+	}
+	loadedRandom.Max = 3
+	loadedRandom.VariableName = ""
+	if err := loadedRandom.Validate(); err == nil || !strings.Contains(err.Error(), "Variable name is required") {
+		t.Fatalf("expected variable name validation error, got %v", err)
+	}
+}
@@ -0,0 +1,1052 @@
+package elements_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"perfolizer/pkg/core"
+	"perfolizer/pkg/elements"
+)
+
+type countingElement struct {
+	core.BaseElement
+	mu    sync.Mutex
+	count int
+	onRun func(ctx *core.Context) error
+}
+
+func newCountingElement(name string) *countingElement {
+	return &countingElement{BaseElement: core.NewBaseElement(name)}
+}
+
+func (e *countingElement) Clone() core.TestElement {
+	return newCountingElement(e.Name())
+}
+
+func (e *countingElement) Execute(ctx *core.Context) error {
+	e.mu.Lock()
+	e.count++
+	e.mu.Unlock()
+	if e.onRun != nil {
+		return e.onRun(ctx)
+	}
+	return nil
+}
+
+func (e *countingElement) Count() int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.count
+}
+
+func roundTripElement(t *testing.T, el core.TestElement) core.TestElement {
+	t.Helper()
+	payload, err := core.MarshalTestPlan(el)
+	if err != nil {
+		t.Fatalf("MarshalTestPlan failed: %v", err)
+	}
+	loaded, err := core.UnmarshalTestPlan(payload)
+	if err != nil {
+		t.Fatalf("UnmarshalTestPlan failed: %v", err)
+	}
+	return loaded
+}
+
+func TestIfControllerExpressionPersistsAndControlsChildren(t *testing.T) {
+	ctrl := elements.NewExpressionIfController("Only OK", `${status} == "200"`)
+
+	loaded, ok := roundTripElement(t, ctrl).(*elements.IfController)
+	if !ok {
+		t.Fatalf("expected IfController after round-trip, got %T", loaded)
+	}
+	if loaded.Expression != `${status} == "200"` {
+		t.Fatalf("expected expression to survive round-trip, got %q", loaded.Expression)
+	}
+
+	child := newCountingElement("Child")
+	loaded.AddChild(child)
+
+	ctx := core.NewContext(context.Background(), 1)
+	ctx.SetVar("status", "500")
+	if err := loaded.Execute(ctx); err != nil {
+		t.Fatalf("Execute returned error: %v", err)
+	}
+	if child.Count() != 0 {
+		t.Fatalf("expected false condition to skip children, got %d run(s)", child.Count())
+	}
+
+	ctx.SetVar("status", "200")
+	if err := loaded.Execute(ctx); err != nil {
+		t.Fatalf("Execute returned error: %v", err)
+	}
+	if child.Count() != 1 {
+		t.Fatalf("expected true condition to run children once, got %d", child.Count())
+	}
+}
+
+func TestIfControllerWithoutPersistedExpressionDefaultsToTrue(t *testing.T) {
+	const raw = `{"type":"IfController","name":"Legacy","props":{}}`
+	loaded, err := core.ReadTestPlan(strings.NewReader(raw))
+	if err != nil {
+		t.Fatalf("ReadTestPlan failed: %v", err)
+	}
+	ctrl := loaded.(*elements.IfController)
+	if ctrl.Expression != "true" {
+		t.Fatalf("expected legacy expression %q, got %q", "true", ctrl.Expression)
+	}
+	if err := ctrl.Validate(); err != nil {
+		t.Fatalf("expected legacy If Controller to validate, got %v", err)
+	}
+}
+
+func TestIfControllerDefaultConditionRunsBeforeTheFirstSample(t *testing.T) {
+	ctrl := elements.NewExpressionIfController("After OK", "lastSampleOk")
+	child := newCountingElement("Child")
+	ctrl.AddChild(child)
+
+	ctx := core.NewContext(context.Background(), 1)
+	if err := ctrl.Execute(ctx); err != nil {
+		t.Fatalf("Execute returned error: %v", err)
+	}
+	if child.Count() != 1 {
+		t.Fatalf("expected children to run before the thread's first sample, got %d run(s)", child.Count())
+	}
+
+	now := time.Now()
+	ctx.SetLastSample(&core.SampleResult{StartTime: now, EndTime: now, Success: false})
+	if err := ctrl.Execute(ctx); err != nil {
+		t.Fatalf("Execute returned error: %v", err)
+	}
+	if child.Count() != 1 {
+		t.Fatalf("expected a failed last sample to skip children, got %d run(s)", child.Count())
+	}
+}
+
+func TestValidateTestPlanRejectsInvalidIfExpression(t *testing.T) {
+	root := core.NewBaseElement("Test Plan")
+	tg := elements.NewSimpleThreadGroup("TG", 1, 1)
+	tg.AddChild(elements.NewExpressionIfController("Broken", `${a} ==`))
+	root.AddChild(tg)
+
+	err := core.ValidateTestPlan(&root)
+	if err == nil {
+		t.Fatal("expected validation error")
+	}
+	if !strings.Contains(err.Error(), `If Controller "Broken"`) || !strings.Contains(err.Error(), "Condition is invalid") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestIfControllerReturnsRuntimeExpressionErrors(t *testing.T) {
+	ctrl := elements.NewExpressionIfController("Dynamic", `${body} =~ ${pattern}`)
+	child := newCountingElement("Child")
+	ctrl.AddChild(child)
+
+	ctx := core.NewContext(context.Background(), 1)
+	ctx.SetVar("body", "token=1")
+	ctx.SetVar("pattern", "token=(")
+	err := ctrl.Execute(ctx)
+	if err == nil || !strings.Contains(err.Error(), `If Controller "Dynamic"`) || !strings.Contains(err.Error(), "invalid regex") {
+		t.Fatalf("expected the condition error to be returned, got %v", err)
+	}
+	if child.Count() != 0 {
+		t.Fatalf("expected children to be skipped, got %d run(s)", child.Count())
+	}
+}
+
+func TestWhileControllerRepeatsUntilConditionIsFalse(t *testing.T) {
+	ctx := core.NewContext(context.Background(), 1)
+	ctx.SetVar("jobStatus", "PENDING")
+
+	ctrl := elements.NewWhileController("Poll", `${jobStatus} != "DONE"`)
+	child := newCountingElement("Check")
+	child.onRun = func(ctx *core.Context) error {
+		if child.Count() >= 3 {
+			ctx.SetVar("jobStatus", "DONE")
+		}
+		return nil
+	}
+	ctrl.AddChild(child)
+
+	if err := ctrl.Execute(ctx); err != nil {
+		t.Fatalf("Execute returned error: %v", err)
+	}
+	if child.Count() != 3 {
+		t.Fatalf("expected 3 passes, got %d", child.Count())
+	}
+}
+
+func TestWhileControllerStopsAtMaxIterations(t *testing.T) {
+	ctrl := elements.NewWhileController("Forever", "true")
+	ctrl.MaxIterations = 5
+	child := newCountingElement("Child")
+	ctrl.AddChild(child)
+
+	if err := ctrl.Execute(core.NewContext(context.Background(), 1)); err != nil {
+		t.Fatalf("Execute returned error: %v", err)
+	}
+	if child.Count() != 5 {
+		t.Fatalf("expected 5 passes, got %d", child.Count())
+	}
+}
+
+func TestWhileControllerStopsAtTimeout(t *testing.T) {
+	ctrl := elements.NewWhileController("Forever", "true")
+	ctrl.Timeout = 30 * time.Millisecond
+	ctrl.AddChild(elements.NewPauseController("Pause", 5*time.Millisecond))
+
+	done := make(chan error, 1)
+	go func() {
+		done <- ctrl.Execute(core.NewContext(context.Background(), 1))
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Execute returned error: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected timeout to stop the loop")
+	}
+}
+
+func TestWhileControllerRespectsCancellation(t *testing.T) {
+	runCtx, cancel := context.WithCancel(context.Background())
+	ctx := core.NewContext(runCtx, 1)
+
+	ctrl := elements.NewWhileController("Forever", "true")
+	child := newCountingElement("Child")
+	child.onRun = func(*core.Context) error {
+		if child.Count() == 2 {
+			cancel()
+		}
+		return nil
+	}
+	ctrl.AddChild(child)
+
+	if err := ctrl.Execute(ctx); err != context.Canceled {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+}
+
+func TestWhileControllerReturnsRuntimeExpressionErrors(t *testing.T) {
+	ctrl := elements.NewWhileController("Dynamic", `${body} !~ ${pattern}`)
+	ctrl.AddChild(newCountingElement("Child"))
+
+	ctx := core.NewContext(context.Background(), 1)
+	ctx.SetVar("body", "PENDING")
+	ctx.SetVar("pattern", "[DONE")
+	err := ctrl.Execute(ctx)
+	if err == nil || !strings.Contains(err.Error(), `While Controller "Dynamic"`) || !strings.Contains(err.Error(), "invalid regex") {
+		t.Fatalf("expected the condition error to be returned, got %v", err)
+	}
+}
+
+func TestWhileControllerPersistsGuards(t *testing.T) {
+	ctrl := elements.NewWhileController("Poll", `${jobStatus} != "DONE"`)
+	ctrl.MaxIterations = 20
+	ctrl.Timeout = 1500 * time.Millisecond
+
+	loaded, ok := roundTripElement(t, ctrl).(*elements.WhileController)
+	if !ok {
+		t.Fatalf("expected WhileController after round-trip, got %T", loaded)
+	}
+	if loaded.Expression != ctrl.Expression || loaded.MaxIterations != 20 || loaded.Timeout != 1500*time.Millisecond {
+		t.Fatalf("unexpected round-trip result: %+v", loaded)
+	}
+
+	loaded.MaxIterations = -1
+	if err := loaded.Validate(); err == nil || !strings.Contains(err.Error(), "Max iterations must be greater than or equal to 0") {
+		t.Fatalf("expected max iterations validation error, got %v", err)
+	}
+}
+
+type collectingRunner struct {
+	mu      sync.Mutex
+	results []*core.SampleResult
+}
+
+func (r *collectingRunner) ReportResult(result *core.SampleResult) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.results = append(r.results, result)
+}
+
+func (r *collectingRunner) byName(name string) []*core.SampleResult {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := make([]*core.SampleResult, 0)
+	for _, result := range r.results {
+		if result.SamplerName == name {
+			out = append(out, result)
+		}
+	}
+	return out
+}
+
+func newSampleEmitter(name string, success bool, took time.Duration) *countingElement {
+	el := newCountingElement(name)
+	el.onRun = func(ctx *core.Context) error {
+		start := time.Now()
+		time.Sleep(took)
+		if reporter, ok := ctx.GetVar("Reporter").(core.Runner); ok {
+			reporter.ReportResult(&core.SampleResult{SamplerName: name, StartTime: start, EndTime: time.Now(), Success: success})
+		}
+		return nil
+	}
+	return el
+}
+
+func TestTransactionControllerRestoresTheReporterWhenChildrenPanic(t *testing.T) {
+	runner := &collectingRunner{}
+	ctx := core.NewContext(context.Background(), 1)
+	ctx.SetVar("Reporter", runner)
+	tx := elements.NewTransactionController("Checkout")
+	boom := newCountingElement("Boom")
+	boom.onRun = func(ctx *core.Context) error {
+		panic("boom")
+	}
+	tx.AddChild(boom)
+
+	func() {
+		defer func() { _ = recover() }()
+		_ = tx.Execute(ctx)
+	}()
+
+	if got := ctx.GetVar("Reporter"); got != runner {
+		t.Fatalf("expected the thread's reporter to be restored, got %T", got)
+	}
+	if got := ctx.GetVar("PauseTracker"); got != nil {
+		t.Fatalf("expected the pause tracker to be restored, got %T", got)
+	}
+}
+
+func TestTransactionControllerReportsParentSample(t *testing.T) {
+	runner := &collectingRunner{}
+	ctx := core.NewContext(context.Background(), 1)
+	ctx.SetVar("Reporter", runner)
+
+	tx := elements.NewTransactionController("Checkout")
+	tx.AddChild(newSampleEmitter("Cart", true, 5*time.Millisecond))
+	tx.AddChild(elements.NewPauseController("Think", 40*time.Millisecond))
+	tx.AddChild(newSampleEmitter("Pay", true, 5*time.Millisecond))
+
+	if err := tx.Execute(ctx); err != nil {
+		t.Fatalf("Execute returned error: %v", err)
+	}
+
+	parents := runner.byName("Checkout")
+	if len(parents) != 1 {
+		t.Fatalf("expected one transaction sample, got %d", len(parents))
+	}
+	parent := parents[0]
+	if !parent.Success || parent.Kind != core.SampleTransaction {
+		t.Fatalf("expected successful parent sample, got %+v", parent)
+	}
+	if parent.Duration() >= 40*time.Millisecond {
+		t.Fatalf("expected pauses to be excluded, got %v", parent.Duration())
+	}
+	if len(runner.byName("Cart")) != 1 || len(runner.byName("Pay")) != 1 {
+		t.Fatal("expected child samples to be reported as well")
+	}
+	if got := ctx.GetVar("Reporter"); got != runner {
+		t.Fatalf("expected original reporter to be restored, got %#v", got)
+	}
+	if last := ctx.LastSample(); last != parent {
+		t.Fatal("expected transaction to become the last sample")
+	}
+}
+
+func TestTransactionControllerFailsWhenChildFailsAndCanHideChildren(t *testing.T) {
+	runner := &collectingRunner{}
+	ctx := core.NewContext(context.Background(), 1)
+	ctx.SetVar("Reporter", runner)
+
+	tx := elements.NewTransactionController("Login")
+	tx.IncludePauses = true
+	tx.ReportChildren = false
+	tx.AddChild(newSampleEmitter("Form", true, 0))
+	tx.AddChild(elements.NewPauseController("Think", 20*time.Millisecond))
+	tx.AddChild(newSampleEmitter("Submit", false, 0))
+
+	if err := tx.Execute(ctx); err != nil {
+		t.Fatalf("Execute returned error: %v", err)
+	}
+
+	if len(runner.byName("Form")) != 0 || len(runner.byName("Submit")) != 0 {
+		t.Fatal("expected child samples to be hidden")
+	}
+	parents := runner.byName("Login")
+	if len(parents) != 1 {
+		t.Fatalf("expected one transaction sample, got %d", len(parents))
+	}
+	if parents[0].Success || parents[0].Kind != core.SampleRequest {
+		t.Fatalf("expected failed transaction sample counted as a request, got %+v", parents[0])
+	}
+	if parents[0].Duration() < 20*time.Millisecond {
+		t.Fatalf("expected pauses to be included, got %v", parents[0].Duration())
+	}
+
+	loaded, ok := roundTripElement(t, tx).(*elements.TransactionController)
+	if !ok || !loaded.IncludePauses || loaded.ReportChildren {
+		t.Fatalf("expected options to survive round-trip, got %+v", loaded)
+	}
+}
+
+func TestWeightedSwitchControllerRunsOneChildPerPassByWeight(t *testing.T) {
+	ctrl := elements.NewWeightedSwitchController("Mix")
+	search := newCountingElement("Search")
+	browse := newCountingElement("Browse")
+	never := newCountingElement("Never")
+	ctrl.AddChild(search)
+	ctrl.AddChild(browse)
+	ctrl.AddChild(never)
+	ctrl.SetWeight(search.ID(), 70)
+	ctrl.SetWeight(browse.ID(), 30)
+	ctrl.SetWeight(never.ID(), 0)
+
+	if err := ctrl.Validate(); err != nil {
+		t.Fatalf("expected weights to validate, got %v", err)
+	}
+
+	ctx := core.NewContext(context.Background(), 1)
+	const passes = 2000
+	for i := 0; i < passes; i++ {
+		if err := ctrl.Execute(ctx); err != nil {
+			t.Fatalf("Execute returned error: %v", err)
+		}
+	}
+
+	if total := search.Count() + browse.Count() + never.Count(); total != passes {
+		t.Fatalf("expected exactly one child per pass, got %d runs for %d passes", total, passes)
+	}
+	if never.Count() != 0 {
+		t.Fatalf("expected zero-weight child to never run, got %d", never.Count())
+	}
+	if share := float64(search.Count()) / passes; share < 0.62 || share > 0.78 {
+		t.Fatalf("expected search share near 0.70, got %.2f", share)
+	}
+
+	loaded, ok := roundTripElement(t, ctrl).(*elements.WeightedSwitchController)
+	if !ok {
+		t.Fatalf("expected WeightedSwitchController after round-trip, got %T", loaded)
+	}
+	if loaded.WeightOf(search.ID()) != 70 || loaded.WeightOf(never.ID()) != 0 {
+		t.Fatalf("expected weights to survive round-trip, got %#v", loaded.Weights)
+	}
+}
+
+func TestWeightedSwitchControllerRejectsInvalidWeights(t *testing.T) {
+	ctrl := elements.NewWeightedSwitchController("Mix")
+	child := newCountingElement("Only")
+	ctrl.AddChild(child)
+
+	ctrl.SetWeight(child.ID(), -1)
+	if err := ctrl.Validate(); err == nil || !strings.Contains(err.Error(), `Weight of "Only" must be greater than or equal to 0`) {
+		t.Fatalf("expected negative weight error, got %v", err)
+	}
+
+	ctrl.SetWeight(child.ID(), 0)
+	if err := ctrl.Validate(); err == nil || !strings.Contains(err.Error(), "at least one child weight must be greater than 0") {
+		t.Fatalf("expected zero total error, got %v", err)
+	}
+}
+
+func TestRandomControllerRunsExactlyOneEnabledChild(t *testing.T) {
+	ctrl := elements.NewRandomController("Random")
+	a := newCountingElement("A")
+	b := newCountingElement("B")
+	disabled := newCountingElement("Disabled")
+	disabled.SetEnabled(false)
+	ctrl.AddChild(a)
+	ctrl.AddChild(b)
+	ctrl.AddChild(disabled)
+
+	ctx := core.NewContext(context.Background(), 1)
+	for i := 0; i < 200; i++ {
+		if err := ctrl.Execute(ctx); err != nil {
+			t.Fatalf("Execute returned error: %v", err)
+		}
+	}
+
+	if a.Count()+b.Count() != 200 || disabled.Count() != 0 {
+		t.Fatalf("unexpected distribution a=%d b=%d disabled=%d", a.Count(), b.Count(), disabled.Count())
+	}
+	if a.Count() == 0 || b.Count() == 0 {
+		t.Fatalf("expected both children to be picked, got a=%d b=%d", a.Count(), b.Count())
+	}
+}
+
+// recordLastSample stands in for a sampler that sent a request.
+func recordLastSample(ctx *core.Context) error {
+	now := time.Now()
+	ctx.SetLastSample(&core.SampleResult{StartTime: now, EndTime: now, Success: true})
+	return nil
+}
+
+func TestOnceOnlyControllerRunsOncePerThreadInSimpleThreadGroup(t *testing.T) {
+	tg := elements.NewSimpleThreadGroup("Users", 3, 4)
+	once := elements.NewOnceOnlyController("Login")
+	login := newCountingElement("Login request")
+	login.onRun = recordLastSample
+	once.AddChild(login)
+
+	loop := elements.NewLoopController("Loop", 2)
+	nestedOnce := elements.NewOnceOnlyController("Nested")
+	nested := newCountingElement("Nested request")
+	nested.onRun = recordLastSample
+	nestedOnce.AddChild(nested)
+	loop.AddChild(nestedOnce)
+
+	work := newCountingElement("Work")
+	tg.AddChild(once)
+	tg.AddChild(loop)
+	tg.AddChild(work)
+
+	tg.Start(context.Background(), noopRunner{})
+
+	if login.Count() != 3 {
+		t.Fatalf("expected one login per user, got %d", login.Count())
+	}
+	if nested.Count() != 3 {
+		t.Fatalf("expected nested once-only to run once per user, got %d", nested.Count())
+	}
+	if work.Count() != 12 {
+		t.Fatalf("expected work on every iteration, got %d", work.Count())
+	}
+}
+
+func TestOnceOnlyControllerRunsOncePerThreadInRPSThreadGroup(t *testing.T) {
+	tg := elements.NewRPSThreadGroup("RPS", 10)
+	tg.Users = 2
+	tg.ProfileBlocks = []elements.RPSProfileBlock{
+		{RampUp: 0, StepDuration: 60 * time.Millisecond, ProfilePercent: 100},
+	}
+	once := elements.NewOnceOnlyController("Login")
+	login := newCountingElement("Login request")
+	login.onRun = recordLastSample
+	once.AddChild(login)
+	work := newCountingElement("Work")
+	tg.AddChild(once)
+	tg.AddChild(work)
+
+	tg.Start(context.Background(), noopRunner{})
+
+	if login.Count() != 2 {
+		t.Fatalf("expected one login per worker, got %d", login.Count())
+	}
+	if work.Count() <= 2 {
+		t.Fatalf("expected workers to keep iterating, got %d", work.Count())
+	}
+}
+
+func TestOnceOnlyControllerRetriesRequestsSkippedByRPSLimiter(t *testing.T) {
+	var mu sync.Mutex
+	logins := make(map[string]int)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/login" {
+			mu.Lock()
+			logins[r.URL.Query().Get("user")]++
+			mu.Unlock()
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	tg := elements.NewRPSThreadGroup("RPS", 20)
+	tg.Users = 4
+	tg.ProfileBlocks = []elements.RPSProfileBlock{
+		{RampUp: 0, StepDuration: 600 * time.Millisecond, ProfilePercent: 100},
+	}
+	once := elements.NewOnceOnlyController("Login")
+	once.AddChild(&elements.HttpSampler{BaseElement: core.NewBaseElement("Login"), Method: "GET", Url: server.URL + "/login?user=${__threadNum()}"})
+	tg.AddChild(once)
+	tg.AddChild(&elements.HttpSampler{BaseElement: core.NewBaseElement("Work"), Method: "GET", Url: server.URL + "/work"})
+
+	tg.Start(context.Background(), noopRunner{})
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(logins) != 4 {
+		t.Fatalf("expected every user to log in, got %v", logins)
+	}
+	for user, n := range logins {
+		if n != 1 {
+			t.Fatalf("expected user %s to log in once, got %d", user, n)
+		}
+	}
+}
+
+func TestInterleaveControllerRotatesPerThreadOrShared(t *testing.T) {
+	perThread := elements.NewInterleaveController("Per thread")
+	a := newCountingElement("A")
+	b := newCountingElement("B")
+	perThread.AddChild(a)
+	perThread.AddChild(b)
+
+	first := core.NewContext(context.Background(), 1)
+	second := core.NewContext(context.Background(), 2)
+	for _, ctx := range []*core.Context{first, second, first} {
+		if err := perThread.Execute(ctx); err != nil {
+			t.Fatalf("Execute returned error: %v", err)
+		}
+	}
+	if a.Count() != 2 || b.Count() != 1 {
+		t.Fatalf("expected per-thread rotation a=2 b=1, got a=%d b=%d", a.Count(), b.Count())
+	}
+
+	shared := elements.NewInterleaveController("Shared")
+	shared.Shared = true
+	c := newCountingElement("C")
+	d := newCountingElement("D")
+	shared.AddChild(c)
+	shared.AddChild(d)
+
+	for _, ctx := range []*core.Context{first, second, first, second} {
+		if err := shared.Execute(ctx); err != nil {
+			t.Fatalf("Execute returned error: %v", err)
+		}
+	}
+	if c.Count() != 2 || d.Count() != 2 {
+		t.Fatalf("expected shared rotation c=2 d=2, got c=%d d=%d", c.Count(), d.Count())
+	}
+
+	loaded, ok := roundTripElement(t, shared).(*elements.InterleaveController)
+	if !ok || !loaded.Shared {
+		t.Fatalf("expected Shared to survive round-trip, got %+v", loaded)
+	}
+}
+
+func TestForEachControllerIteratesPrefixedVariables(t *testing.T) {
+	ctx := core.NewContext(context.Background(), 1)
+	ctx.SetVar("id_1", "a")
+	ctx.SetVar("id_2", "b")
+	ctx.SetVar("id_3", "c")
+
+	forEach := elements.NewForEachController("Each id", "id", "current")
+	var seen []string
+	child := newCountingElement("Use id")
+	child.onRun = func(ctx *core.Context) error {
+		seen = append(seen, fmt.Sprintf("%v:%v", ctx.GetVar("current_index"), ctx.GetVar("current")))
+		return nil
+	}
+	forEach.AddChild(child)
+
+	if err := forEach.Execute(ctx); err != nil {
+		t.Fatalf("Execute returned error: %v", err)
+	}
+	if got := strings.Join(seen, ","); got != "1:a,2:b,3:c" {
+		t.Fatalf("unexpected iteration order %q", got)
+	}
+}
+
+func TestForEachControllerIteratesJSONArray(t *testing.T) {
+	ctx := core.NewContext(context.Background(), 1)
+	ctx.SetVar("orders", `[101, "x", {"k":1}]`)
+
+	forEach := elements.NewForEachController("Each order", "orders", "order")
+	forEach.Source = elements.ForEachSourceJSON
+	forEach.IndexVariable = "n"
+	var seen []string
+	child := newCountingElement("Use order")
+	child.onRun = func(ctx *core.Context) error {
+		seen = append(seen, fmt.Sprintf("%v=%v", ctx.GetVar("n"), ctx.GetVar("order")))
+		return nil
+	}
+	forEach.AddChild(child)
+
+	if err := forEach.Execute(ctx); err != nil {
+		t.Fatalf("Execute returned error: %v", err)
+	}
+	if got := strings.Join(seen, " "); got != `1=101 2=x 3={"k":1}` {
+		t.Fatalf("unexpected items %q", got)
+	}
+
+	ctx.SetVar("orders", "not json")
+	if err := forEach.Execute(ctx); err == nil || !strings.Contains(err.Error(), `ForEach Controller "Each order" cannot read "orders"`) {
+		t.Fatalf("expected malformed input to be returned as an error, got %v", err)
+	}
+	if child.Count() != 3 {
+		t.Fatalf("expected no extra iterations for malformed input, got %d", child.Count())
+	}
+
+	loaded, ok := roundTripElement(t, forEach).(*elements.ForEachController)
+	if !ok {
+		t.Fatalf("expected ForEachController after round-trip, got %T", loaded)
+	}
+	if loaded.Source != elements.ForEachSourceJSON || loaded.InputVariable != "orders" ||
+		loaded.OutputVariable != "order" || loaded.IndexVariable != "n" {
+		t.Fatalf("unexpected round-trip result %+v", loaded)
+	}
+}
+
+func TestForEachControllerValidation(t *testing.T) {
+	forEach := elements.NewForEachController("Each", "", "item")
+	if err := forEach.Validate(); err == nil || !strings.Contains(err.Error(), "Input variable is required") {
+		t.Fatalf("expected missing input error, got %v", err)
+	}
+	forEach.InputVariable = "${items}"
+	if err := forEach.Validate(); err == nil {
+		t.Fatal("expected substitution syntax in variable name to be rejected")
+	}
+	forEach.InputVariable = "items"
+	forEach.Source = "XML"
+	if err := forEach.Validate(); err == nil || !strings.Contains(err.Error(), "Source") {
+		t.Fatalf("expected source error, got %v", err)
+	}
+}
+
+func TestParallelControllerRunsChildrenConcurrentlyWithCap(t *testing.T) {
+	parallel := elements.NewParallelController("Resources", 2)
+	parallel.ReportTiming = true
+
+	var mu sync.Mutex
+	running, peak := 0, 0
+	for i := 0; i < 4; i++ {
+		name := fmt.Sprintf("Resource %d", i)
+		child := newSampleEmitter(name, true, 0)
+		inner := child.onRun
+		child.onRun = func(ctx *core.Context) error {
+			mu.Lock()
+			running++
+			if running > peak {
+				peak = running
+			}
+			mu.Unlock()
+
+			time.Sleep(30 * time.Millisecond)
+			ctx.SetVar(name, "done")
+
+			mu.Lock()
+			running--
+			mu.Unlock()
+			return inner(ctx)
+		}
+		parallel.AddChild(child)
+	}
+
+	runner := &collectingRunner{}
+	ctx := core.NewContext(context.Background(), 1)
+	ctx.SetVar("Reporter", runner)
+
+	start := time.Now()
+	if err := parallel.Execute(ctx); err != nil {
+		t.Fatalf("Execute returned error: %v", err)
+	}
+	elapsed := time.Since(start)
+
+	if peak != 2 {
+		t.Fatalf("expected at most 2 concurrent children, peak was %d", peak)
+	}
+	if elapsed >= 110*time.Millisecond {
+		t.Fatalf("expected children to overlap, took %v", elapsed)
+	}
+	for i := 0; i < 4; i++ {
+		if got := ctx.GetVar(fmt.Sprintf("Resource %d", i)); got != "done" {
+			t.Fatalf("expected variable from child %d to be joined, got %v", i, got)
+		}
+	}
+	if ctx.GetVar("Reporter") != runner {
+		t.Fatal("expected Reporter to be restored")
+	}
+
+	combined := runner.byName("Resources")
+	if len(combined) != 1 || combined[0].Kind != core.SampleTransaction || !combined[0].Success {
+		t.Fatalf("expected one successful parent sample, got %+v", combined)
+	}
+	if len(runner.byName("Resource 0")) != 1 {
+		t.Fatal("expected child samples to be forwarded")
+	}
+	if ctx.LastSample() == nil || ctx.LastSample().SamplerName != "Resources" {
+		t.Fatalf("expected combined sample to be the last sample, got %+v", ctx.LastSample())
+	}
+
+	loaded, ok := roundTripElement(t, parallel).(*elements.ParallelController)
+	if !ok || loaded.MaxConcurrency != 2 || !loaded.ReportTiming {
+		t.Fatalf("unexpected round-trip result %+v", loaded)
+	}
+}
+
+func TestParallelControllerIsolatesNestedTransactions(t *testing.T) {
+	parallel := elements.NewParallelController("Parallel", 0)
+	for _, name := range []string{"Tx A", "Tx B"} {
+		tx := elements.NewTransactionController(name)
+		tx.AddChild(newSampleEmitter(name+" request", true, 20*time.Millisecond))
+		parallel.AddChild(tx)
+	}
+
+	runner := &collectingRunner{}
+	ctx := core.NewContext(context.Background(), 1)
+	ctx.SetVar("Reporter", runner)
+
+	if err := parallel.Execute(ctx); err != nil {
+		t.Fatalf("Execute returned error: %v", err)
+	}
+	if ctx.GetVar("Reporter") != runner {
+		t.Fatal("expected Reporter to survive nested transactions")
+	}
+	for _, name := range []string{"Tx A", "Tx B"} {
+		if got := len(runner.byName(name)); got != 1 {
+			t.Fatalf("expected one %s sample, got %d", name, got)
+		}
+	}
+}
+
+func TestThroughputControllerPercentModeSpreadsExecutions(t *testing.T) {
+	throughput := elements.NewThroughputController("Checkout mix", elements.ThroughputModePercent)
+	throughput.Percent = 25
+	child := newCountingElement("Checkout")
+	throughput.AddChild(child)
+
+	ctx := core.NewContext(context.Background(), 1)
+	for i := 0; i < 8; i++ {
+		if err := throughput.Execute(ctx); err != nil {
+			t.Fatalf("Execute returned error: %v", err)
+		}
+		if want := (i + 1) / 4; child.Count() != want {
+			t.Fatalf("after %d passes expected %d executions, got %d", i+1, want, child.Count())
+		}
+	}
+}
+
+func TestThroughputControllerTotalModePerThreadAndShared(t *testing.T) {
+	perThread := elements.NewThroughputController("Per thread", elements.ThroughputModeTotal)
+	perThread.Executions = 2
+	a := newCountingElement("A")
+	perThread.AddChild(a)
+
+	shared := elements.NewThroughputController("Shared", elements.ThroughputModeTotal)
+	shared.Executions = 2
+	shared.Shared = true
+	b := newCountingElement("B")
+	shared.AddChild(b)
+
+	for threadID := 1; threadID <= 3; threadID++ {
+		ctx := core.NewContext(context.Background(), threadID)
+		for pass := 0; pass < 5; pass++ {
+			if err := perThread.Execute(ctx); err != nil {
+				t.Fatalf("Execute returned error: %v", err)
+			}
+			if err := shared.Execute(ctx); err != nil {
+				t.Fatalf("Execute returned error: %v", err)
+			}
+		}
+	}
+
+	if a.Count() != 6 {
+		t.Fatalf("expected 2 executions per thread, got %d", a.Count())
+	}
+	if b.Count() != 2 {
+		t.Fatalf("expected 2 executions across the group, got %d", b.Count())
+	}
+
+	loaded, ok := roundTripElement(t, shared).(*elements.ThroughputController)
+	if !ok || loaded.Mode != elements.ThroughputModeTotal || loaded.Executions != 2 || !loaded.Shared {
+		t.Fatalf("unexpected round-trip result %+v", loaded)
+	}
+
+	shared.Mode = elements.ThroughputModePercent
+	shared.Percent = 120
+	if err := shared.Validate(); err == nil || !strings.Contains(err.Error(), "between 0 and 100") {
+		t.Fatalf("expected percent range error, got %v", err)
+	}
+}
+
+func TestRuntimeControllerRepeatsUntilDurationElapses(t *testing.T) {
+	runtime := elements.NewRuntimeController("Soak", 80*time.Millisecond)
+	first := newCountingElement("First")
+	first.onRun = func(ctx *core.Context) error {
+		time.Sleep(10 * time.Millisecond)
+		return nil
+	}
+	second := newCountingElement("Second")
+	runtime.AddChild(first)
+	runtime.AddChild(second)
+
+	start := time.Now()
+	if err := runtime.Execute(core.NewContext(context.Background(), 1)); err != nil {
+		t.Fatalf("Execute returned error: %v", err)
+	}
+	elapsed := time.Since(start)
+
+	if elapsed < 80*time.Millisecond || elapsed > 200*time.Millisecond {
+		t.Fatalf("expected to run for about 80ms, ran %v", elapsed)
+	}
+	if first.Count() < 4 {
+		t.Fatalf("expected several passes, got %d", first.Count())
+	}
+	if diff := first.Count() - second.Count(); diff < 0 || diff > 1 {
+		t.Fatalf("expected deadline to be checked between children, got first=%d second=%d", first.Count(), second.Count())
+	}
+
+	loaded, ok := roundTripElement(t, runtime).(*elements.RuntimeController)
+	if !ok || loaded.Duration != 80*time.Millisecond {
+		t.Fatalf("unexpected round-trip result %+v", loaded)
+	}
+}
+
+func TestRuntimeControllerDoesNotSpinWhenPassesProduceNoSample(t *testing.T) {
+	runtime := elements.NewRuntimeController("Soak", 100*time.Millisecond)
+	skipped := newCountingElement("Skipped")
+	runtime.AddChild(elements.NewExpressionIfController("Never", "false"))
+	runtime.AddChild(skipped)
+
+	start := time.Now()
+	if err := runtime.Execute(core.NewContext(context.Background(), 1)); err != nil {
+		t.Fatalf("Execute returned error: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond || elapsed > 300*time.Millisecond {
+		t.Fatalf("expected to run for about 100ms, ran %v", elapsed)
+	}
+	if skipped.Count() > 20 {
+		t.Fatalf("expected empty passes to be paced, got %d passes", skipped.Count())
+	}
+}
+
+func TestRuntimeControllerStopsOnCancellation(t *testing.T) {
+	runtime := elements.NewRuntimeController("Soak", time.Hour)
+	child := newCountingElement("Work")
+	child.onRun = func(ctx *core.Context) error {
+		time.Sleep(5 * time.Millisecond)
+		return nil
+	}
+	runtime.AddChild(child)
+
+	base, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	err := runtime.Execute(core.NewContext(base, 1))
+	if err == nil {
+		t.Fatal("expected cancellation error")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("expected prompt stop on cancellation, took %v", elapsed)
+	}
+
+	runtime.Duration = 0
+	if err := runtime.Validate(); err == nil {
+		t.Fatal("expected zero duration to be rejected")
+	}
+}
+
+func TestCriticalSectionControllerSerializesSectionsSharingLockName(t *testing.T) {
+	runCtx := core.WithRunStore(context.Background(), core.NewRunStore())
+	runner := &collectingRunner{}
+
+	var mu sync.Mutex
+	inside, maxInside := 0, 0
+	newSection := func(name string) *elements.CriticalSectionController {
+		section := elements.NewCriticalSectionController(name, "cart")
+		work := newCountingElement(name + " work")
+		work.onRun = func(ctx *core.Context) error {
+			mu.Lock()
+			inside++
+			if inside > maxInside {
+				maxInside = inside
+			}
+			mu.Unlock()
+			time.Sleep(5 * time.Millisecond)
+			mu.Lock()
+			inside--
+			mu.Unlock()
+			return nil
+		}
+		section.AddChild(work)
+		return section
+	}
+	sections := []*elements.CriticalSectionController{newSection("Checkout"), newSection("Refund")}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 6; i++ {
+		wg.Add(1)
+		go func(threadNum int) {
+			defer wg.Done()
+			ctx := core.NewContext(runCtx, threadNum)
+			ctx.SetVar("Reporter", runner)
+			if err := sections[threadNum%2].Execute(ctx); err != nil {
+				t.Errorf("Execute returned error: %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if maxInside != 1 {
+		t.Fatalf("expected one thread inside the sections at a time, got %d", maxInside)
+	}
+	waits := append(runner.byName("Checkout lock wait"), runner.byName("Refund lock wait")...)
+	if len(waits) != 6 {
+		t.Fatalf("expected one lock wait sample per entry, got %d", len(waits))
+	}
+	var longest time.Duration
+	for _, w := range waits {
+		if w.Kind != core.SampleLockWait || !w.Success {
+			t.Fatalf("expected successful parent lock wait sample, got %+v", w)
+		}
+		if d := w.EndTime.Sub(w.StartTime); d > longest {
+			longest = d
+		}
+	}
+	if longest < 10*time.Millisecond {
+		t.Fatalf("expected queued threads to report waiting, longest wait %v", longest)
+	}
+
+	loaded, ok := roundTripElement(t, sections[0]).(*elements.CriticalSectionController)
+	if !ok || loaded.LockName != "cart" || !loaded.ReportWaitTime {
+		t.Fatalf("unexpected round-trip result %+v", loaded)
+	}
+}
+
+func TestCriticalSectionControllerReleasesOnCancellation(t *testing.T) {
+	runCtx := core.WithRunStore(context.Background(), core.NewRunStore())
+	holder := elements.NewCriticalSectionController("Holder", "db")
+	holderCtx, stopHolder := context.WithCancel(runCtx)
+	entered := make(chan struct{})
+	block := newCountingElement("Block")
+	block.onRun = func(ctx *core.Context) error {
+		close(entered)
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	holder.AddChild(block)
+
+	holderDone := make(chan error, 1)
+	go func() { holderDone <- holder.Execute(core.NewContext(holderCtx, 1)) }()
+	<-entered
+
+	waiter := elements.NewCriticalSectionController("Waiter", "db")
+	waiter.ReportWaitTime = false
+	waiterCtx, cancelWaiter := context.WithTimeout(runCtx, 20*time.Millisecond)
+	defer cancelWaiter()
+	if err := waiter.Execute(core.NewContext(waiterCtx, 2)); err == nil {
+		t.Fatal("expected waiting thread to give up on cancellation")
+	}
+
+	stopHolder()
+	if err := <-holderDone; err == nil {
+		t.Fatal("expected holder to return its cancellation error")
+	}
+
+	next := elements.NewCriticalSectionController("Next", "db")
+	after := newCountingElement("After")
+	next.AddChild(after)
+	nextCtx, cancelNext := context.WithTimeout(runCtx, time.Second)
+	defer cancelNext()
+	if err := next.Execute(core.NewContext(nextCtx, 3)); err != nil {
+		t.Fatalf("expected lock to be free after cancellation, got %v", err)
+	}
+	if after.Count() != 1 {
+		t.Fatalf("expected section children to run, got %d", after.Count())
+	}
+
+	next.LockName = "  "
+	if err := next.Validate(); err == nil {
+		t.Fatal("expected empty lock name to be rejected")
+	}
+}
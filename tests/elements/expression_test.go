@@ -0,0 +1,85 @@
+package elements_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"perfolizer/pkg/core"
+	"perfolizer/pkg/elements"
+)
+
+func TestExpressionEvaluatesAgainstContext(t *testing.T) {
+	ctx := core.NewContext(context.Background(), 1)
+	ctx.SetVar("status", "200")
+	ctx.SetVar("retries", 2)
+	ctx.SetVar("body", `{"token":"abc123"}`)
+	ctx.SetVar("flag", true)
+
+	start := time.Now()
+	ctx.SetLastSample(&core.SampleResult{
+		StartTime:    start,
+		EndTime:      start.Add(120 * time.Millisecond),
+		ResponseCode: "404 Not Found",
+		Success:      false,
+	})
+
+	tests := []struct {
+		expr     string
+		expected bool
+	}{
+		{`${status} == "200" && ${retries} < 3`, true},
+		{`${status} == "200" && ${retries} >= 3`, false},
+		{`${retries} < 10`, true},
+		{`${status} != 200`, false},
+		{`${body} =~ "token\":\"\w+"`, true},
+		{`${body} !~ 'token'`, false},
+		{`${missing} == ""`, true},
+		{`${flag}`, true},
+		{`!lastSampleOk`, true},
+		{`lastSampleCode == 404 && lastSampleMs >= 100`, true},
+		{`(${retries} > 5 || ${status} == "200") && !(lastSampleOk)`, true},
+		{`"abc" < "abd"`, true},
+		{`false || 0`, false},
+	}
+
+	for _, tc := range tests {
+		expr, err := elements.CompileExpression(tc.expr)
+		if err != nil {
+			t.Fatalf("CompileExpression(%q) returned error: %v", tc.expr, err)
+		}
+		got, err := expr.Eval(ctx)
+		if err != nil {
+			t.Fatalf("Eval(%q) returned error: %v", tc.expr, err)
+		}
+		if got != tc.expected {
+			t.Fatalf("Eval(%q) = %t; want %t", tc.expr, got, tc.expected)
+		}
+	}
+}
+
+func TestExpressionRejectsMalformedInput(t *testing.T) {
+	tests := []struct {
+		expr     string
+		contains string
+	}{
+		{``, "Condition is required"},
+		{`${status} ==`, "unexpected end of expression"},
+		{`(${a} == 1`, "missing closing parenthesis"},
+		{`"open`, "unterminated string"},
+		{`${a} =~ "("`, "invalid regex"},
+		{`unknownThing`, "unknown identifier"},
+		{`${a} == 1 1`, "unexpected"},
+	}
+
+	for _, tc := range tests {
+		err := elements.ValidateExpression("Condition", tc.expr)
+		if err == nil {
+			t.Fatalf("expected %q to be rejected", tc.expr)
+		}
+		if !strings.Contains(err.Error(), tc.contains) {
+			t.Fatalf("expected error for %q to contain %q, got %v", tc.expr, tc.contains, err)
+		}
+	}
+}
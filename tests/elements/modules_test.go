@@ -0,0 +1,235 @@
+package elements_test
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"perfolizer/pkg/core"
+	"perfolizer/pkg/elements"
+)
+
+func newModuleProject() (*core.Project, *elements.TransactionController) {
+	project := core.NewProject("Shop")
+
+	common := core.NewBaseElement("Common")
+	tg := elements.NewSimpleThreadGroup("Fragments", 1, 1)
+	login := elements.NewTransactionController("Login")
+	login.AddChild(&elements.HttpSampler{BaseElement: core.NewBaseElement("POST /login"), Method: "POST", Url: "http://localhost/login"})
+	tg.AddChild(login)
+	common.AddChild(tg)
+	project.AddPlan("Common", &common)
+
+	return project, login
+}
+
+func TestResolveModulesInlinesProjectFragment(t *testing.T) {
+	project, login := newModuleProject()
+
+	main := core.NewBaseElement("Main")
+	tg := elements.NewSimpleThreadGroup("Users", 1, 1)
+	module := elements.NewModuleController("Do login", "Common", login.ID())
+	tg.AddChild(module)
+	main.AddChild(tg)
+	project.AddPlan("Main", &main)
+
+	resolved, err := elements.ResolveModules(&main, project, "")
+	if err != nil {
+		t.Fatalf("ResolveModules returned error: %v", err)
+	}
+	if len(module.GetChildren()) != 0 {
+		t.Fatal("expected the edited plan to stay untouched")
+	}
+
+	payload, err := core.MarshalTestPlan(resolved)
+	if err != nil {
+		t.Fatalf("MarshalTestPlan returned error: %v", err)
+	}
+	loaded, err := core.UnmarshalTestPlan(payload)
+	if err != nil {
+		t.Fatalf("UnmarshalTestPlan returned error: %v", err)
+	}
+
+	loadedModule, ok := loaded.GetChildren()[0].GetChildren()[0].(*elements.ModuleController)
+	if !ok {
+		t.Fatalf("expected ModuleController in resolved plan, got %T", loaded.GetChildren()[0].GetChildren()[0])
+	}
+	if loadedModule.PlanName != "Common" || loadedModule.ElementID != login.ID() {
+		t.Fatalf("expected reference props to round-trip, got %+v", loadedModule)
+	}
+	children := loadedModule.GetChildren()
+	if len(children) != 1 || children[0].Name() != "Login" || len(children[0].GetChildren()) != 1 {
+		t.Fatalf("expected inlined Login transaction, got %d child(ren)", len(children))
+	}
+}
+
+func TestResolveModulesUsesWholePlanScenario(t *testing.T) {
+	project, _ := newModuleProject()
+	main := core.NewBaseElement("Main")
+	module := elements.NewModuleController("Common steps", "Common", "")
+	main.AddChild(module)
+
+	resolved, err := elements.ResolveModules(&main, project, "")
+	if err != nil {
+		t.Fatalf("ResolveModules returned error: %v", err)
+	}
+	children := resolved.GetChildren()[0].GetChildren()
+	if len(children) != 1 || children[0].Name() != "Login" {
+		t.Fatalf("expected thread group steps to be inlined, got %d child(ren)", len(children))
+	}
+}
+
+func TestResolveModulesDetectsCycles(t *testing.T) {
+	project := core.NewProject("Loops")
+
+	a := core.NewBaseElement("A")
+	aSteps := elements.NewTransactionController("A steps")
+	aSteps.AddChild(elements.NewModuleController("To B", "B", ""))
+	a.AddChild(aSteps)
+	project.AddPlan("A", &a)
+
+	b := core.NewBaseElement("B")
+	b.AddChild(elements.NewModuleController("To A", "A", aSteps.ID()))
+	project.AddPlan("B", &b)
+
+	_, err := elements.ResolveModules(&a, project, "")
+	if err == nil {
+		t.Fatal("expected cycle to be rejected")
+	}
+	var validationErr *core.ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("expected ValidationError, got %T", err)
+	}
+	if !strings.Contains(err.Error(), "reference cycle") {
+		t.Fatalf("expected cycle message, got %v", err)
+	}
+
+	self := core.NewBaseElement("Self")
+	self.AddChild(elements.NewModuleController("Me", "Self", ""))
+	project.AddPlan("Self", &self)
+	if _, err := elements.ResolveModules(&self, project, ""); err == nil || !strings.Contains(err.Error(), "reference cycle") {
+		t.Fatalf("expected self reference to be rejected, got %v", err)
+	}
+}
+
+func TestResolveModulesLoadsExternalFileRelativeToProject(t *testing.T) {
+	dir := t.TempDir()
+	external, login := newModuleProject()
+	if err := core.SaveProject(filepath.Join(dir, "common.json"), external); err != nil {
+		t.Fatalf("SaveProject returned error: %v", err)
+	}
+
+	main := core.NewBaseElement("Main")
+	module := elements.NewModuleController("External login", "", login.ID())
+	module.File = "common.json"
+	main.AddChild(module)
+
+	resolved, err := elements.ResolveModules(&main, core.NewProject("Other"), dir)
+	if err != nil {
+		t.Fatalf("ResolveModules returned error: %v", err)
+	}
+	children := resolved.GetChildren()[0].GetChildren()
+	if len(children) != 1 || children[0].Name() != "Login" {
+		t.Fatalf("expected external Login fragment, got %d child(ren)", len(children))
+	}
+
+	module.ElementID = "missing"
+	if _, err := elements.ResolveModules(&main, nil, dir); err == nil || !strings.Contains(err.Error(), `element "missing" not found`) {
+		t.Fatalf("expected missing element error, got %v", err)
+	}
+
+	module.File = "nope.json"
+	if _, err := elements.ResolveModules(&main, nil, dir); err == nil || !strings.Contains(err.Error(), "nope.json") {
+		t.Fatalf("expected missing file error, got %v", err)
+	}
+}
+
+func TestResolveModulesGivesEachInlinedCopyNewIDs(t *testing.T) {
+	project := core.NewProject("Shop")
+	main := core.NewBaseElement("Main")
+	tg := elements.NewSimpleThreadGroup("Users", 1, 1)
+	pick := elements.NewWeightedSwitchController("Pick")
+	browse := newCountingElement("Browse")
+	buy := newCountingElement("Buy")
+	pick.AddChild(browse)
+	pick.AddChild(buy)
+	pick.SetWeight(buy.ID(), 3)
+	tg.AddChild(pick)
+	tg.AddChild(elements.NewModuleController("Pick again", "Main", pick.ID()))
+	tg.AddChild(elements.NewModuleController("And again", "Main", pick.ID()))
+	main.AddChild(tg)
+	project.AddPlan("Main", &main)
+
+	resolved, err := elements.ResolveModules(&main, project, "")
+	if err != nil {
+		t.Fatalf("ResolveModules returned error: %v", err)
+	}
+
+	seen := make(map[string]string)
+	var walk func(el core.TestElement)
+	walk = func(el core.TestElement) {
+		if other, ok := seen[el.ID()]; ok {
+			t.Fatalf("expected unique IDs, %q and %q share %s", other, el.Name(), el.ID())
+		}
+		seen[el.ID()] = el.Name()
+		for _, child := range el.GetChildren() {
+			walk(child)
+		}
+	}
+	walk(resolved)
+
+	for _, module := range resolved.GetChildren()[0].GetChildren()[1:] {
+		copied := module.GetChildren()[0].(*elements.WeightedSwitchController)
+		copiedBuy := copied.GetChildren()[1]
+		if copied.WeightOf(copiedBuy.ID()) != 3 || copied.WeightOf(copied.GetChildren()[0].ID()) != elements.DefaultChildWeight {
+			t.Fatalf("expected weights to follow the copied children, got %v", copied.Weights)
+		}
+	}
+}
+
+func TestValidateResolvedModulesRejectsUnresolvedReferences(t *testing.T) {
+	project, login := newModuleProject()
+	main := core.NewBaseElement("Main")
+	tg := elements.NewSimpleThreadGroup("Users", 1, 1)
+	tg.AddChild(elements.NewModuleController("Do login", "Common", login.ID()))
+	main.AddChild(tg)
+
+	err := elements.ValidateResolvedModules(&main)
+	var validationErr *core.ValidationError
+	if !errors.As(err, &validationErr) || !strings.Contains(err.Error(), `Module Controller "Do login": reference Common#`+login.ID()+" is not resolved") {
+		t.Fatalf("expected unresolved reference error, got %v", err)
+	}
+
+	resolved, err := elements.ResolveModules(&main, project, "")
+	if err != nil {
+		t.Fatalf("ResolveModules returned error: %v", err)
+	}
+	if err := elements.ValidateResolvedModules(roundTripElement(t, resolved)); err != nil {
+		t.Fatalf("expected the resolved plan to validate after a round-trip, got %v", err)
+	}
+}
+
+func TestValidateModuleReferencesRejectsCyclesWithoutResolving(t *testing.T) {
+	project, login := newModuleProject()
+	main := core.NewBaseElement("Main")
+	tg := elements.NewSimpleThreadGroup("Users", 1, 1)
+	tg.AddChild(elements.NewModuleController("Do login", "Common", login.ID()))
+	main.AddChild(tg)
+	project.AddPlan("Main", &main)
+
+	if err := elements.ValidateModuleReferences(&main, project, ""); err != nil {
+		t.Fatalf("ValidateModuleReferences returned error: %v", err)
+	}
+
+	loop := elements.NewModuleController("Again", "Main", "")
+	tg.AddChild(loop)
+	err := elements.ValidateModuleReferences(&main, project, "")
+	var validationErr *core.ValidationError
+	if !errors.As(err, &validationErr) || !errors.Is(err, elements.ErrModuleCycle) {
+		t.Fatalf("expected a reference cycle ValidationError, got %v", err)
+	}
+	if len(loop.GetChildren()) != 0 || loop.Resolved {
+		t.Fatal("expected validation to leave the plan unresolved")
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"io"
 	"net/http"
+	"net/http/httptest"
 	"strings"
 	"testing"
 	"time"
@@ -168,3 +169,29 @@ func newHTTPResponse(req *http.Request, statusCode int, body string) *http.Respo
 		Request:    req,
 	}
 }
+
+func TestHttpSamplerStoresExtractedValuesInTheParameterScope(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, `{"token":"t-1","order":"o-7"}`)
+	}))
+	defer server.Close()
+
+	globals := core.NewGlobals(nil)
+	ctx := core.NewContext(core.WithGlobals(context.Background(), globals), 1)
+	ctx.SetVar("Reporter", &sampleCaptureRunner{results: make(chan *core.SampleResult, 1)})
+	ctx.ParameterDefinitions["token"] = core.Parameter{Name: "token", Type: core.ParamTypeJSON, Expression: "token", Scope: core.VarScopeGlobal}
+	ctx.ParameterDefinitions["order"] = core.Parameter{Name: "order", Type: core.ParamTypeJSON, Expression: "order"}
+
+	sampler := elements.NewHttpSampler("Login", http.MethodGet, server.URL)
+	sampler.ExtractVars = []string{"token", "order"}
+	if err := sampler.Execute(ctx); err != nil {
+		t.Fatalf("Execute returned unexpected error: %v", err)
+	}
+
+	if value, _ := globals.Get("token"); value != "t-1" {
+		t.Fatalf("expected the global parameter to be extracted into globals, got %v", value)
+	}
+	if _, ok := globals.Get("order"); ok || ctx.GetVar("order") != "o-7" {
+		t.Fatalf("expected the thread parameter to stay with the thread, got %v", ctx.GetVar("order"))
+	}
+}
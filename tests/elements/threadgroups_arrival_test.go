@@ -0,0 +1,168 @@
+package elements_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"perfolizer/pkg/core"
+	"perfolizer/pkg/elements"
+)
+
+// concurrencyProbe records how many threads ran it and the peak concurrency.
+type concurrencyProbe struct {
+	mu      sync.Mutex
+	active  int
+	peak    int
+	threads map[int]bool
+}
+
+func (p *concurrencyProbe) element(name string, took time.Duration) *countingElement {
+	el := newCountingElement(name)
+	el.onRun = func(ctx *core.Context) error {
+		p.mu.Lock()
+		p.active++
+		if p.active > p.peak {
+			p.peak = p.active
+		}
+		if p.threads == nil {
+			p.threads = make(map[int]bool)
+		}
+		p.threads[ctx.ThreadID] = true
+		p.mu.Unlock()
+
+		time.Sleep(took)
+
+		p.mu.Lock()
+		p.active--
+		p.mu.Unlock()
+		return nil
+	}
+	return el
+}
+
+func TestArrivalRateThreadGroupStartsIterationsAtConfiguredRate(t *testing.T) {
+	tg := elements.NewArrivalRateThreadGroup("Open", 100)
+	tg.ProfileBlocks = []elements.RPSProfileBlock{{StepDuration: 400 * time.Millisecond, ProfilePercent: 100}}
+	tg.PreAllocatedUsers = 2
+	tg.GracefulShutdown = time.Second
+
+	probe := &concurrencyProbe{}
+	work := probe.element("Work", time.Millisecond)
+	tg.AddChild(work)
+
+	runner := &collectingRunner{}
+	start := time.Now()
+	tg.Start(context.Background(), runner)
+	elapsed := time.Since(start)
+
+	if elapsed < 400*time.Millisecond || elapsed > time.Second {
+		t.Fatalf("expected the group to run for its profile, ran %v", elapsed)
+	}
+	if got := work.Count(); got < 30 || got > 45 {
+		t.Fatalf("expected about 40 iterations at 100/s for 400ms, got %d", got)
+	}
+	if len(probe.threads) > 4 {
+		t.Fatalf("expected fast iterations to reuse a small pool, used %d users", len(probe.threads))
+	}
+	if dropped := runner.byName("Open dropped iterations"); len(dropped) != 0 {
+		t.Fatalf("expected no dropped iterations, got %d", len(dropped))
+	}
+}
+
+func TestArrivalRateThreadGroupGrowsPoolAndReportsDroppedIterations(t *testing.T) {
+	tg := elements.NewArrivalRateThreadGroup("Open", 100)
+	tg.ProfileBlocks = []elements.RPSProfileBlock{{StepDuration: 300 * time.Millisecond, ProfilePercent: 100}}
+	tg.PreAllocatedUsers = 0
+	tg.MaxUsers = 3
+	tg.GracefulShutdown = time.Second
+
+	probe := &concurrencyProbe{}
+	work := probe.element("Slow", 200*time.Millisecond)
+	tg.AddChild(work)
+
+	runner := &collectingRunner{}
+	tg.Start(context.Background(), runner)
+
+	if probe.peak != 3 || len(probe.threads) != 3 {
+		t.Fatalf("expected the pool to grow to exactly 3 users, peak=%d users=%d", probe.peak, len(probe.threads))
+	}
+	dropped := runner.byName("Open dropped iterations")
+	if len(dropped) < 15 {
+		t.Fatalf("expected most arrivals to be dropped, got %d", len(dropped))
+	}
+	if got := len(dropped) + work.Count(); got < 25 || got > 35 {
+		t.Fatalf("expected about 30 arrivals in total, got %d", got)
+	}
+	for _, result := range dropped {
+		if result.Success || result.Kind != core.SampleDroppedIteration || !errors.Is(result.Error, elements.ErrIterationDropped) {
+			t.Fatalf("unexpected dropped iteration sample %+v", result)
+		}
+	}
+}
+
+func TestArrivalRateThreadGroupFollowsProfileRamp(t *testing.T) {
+	tg := elements.NewArrivalRateThreadGroup("Ramp", 200)
+	tg.ProfileBlocks = []elements.RPSProfileBlock{
+		{StepDuration: 200 * time.Millisecond, ProfilePercent: 0},
+		{StepDuration: 200 * time.Millisecond, ProfilePercent: 50},
+	}
+	tg.GracefulShutdown = time.Second
+
+	work := newCountingElement("Work")
+	tg.AddChild(work)
+	tg.Start(context.Background(), noopRunner{})
+
+	if got := work.Count(); got < 12 || got > 25 {
+		t.Fatalf("expected about 20 iterations at 100/s for the second block only, got %d", got)
+	}
+}
+
+func TestArrivalRateThreadGroupStopsOnCancellation(t *testing.T) {
+	tg := elements.NewArrivalRateThreadGroup("Open", 50)
+	tg.ProfileBlocks = []elements.RPSProfileBlock{{StepDuration: time.Hour, ProfilePercent: 100}}
+	tg.AddChild(newCountingElement("Work"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	tg.Start(ctx, noopRunner{})
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("expected prompt stop on cancellation, took %v", elapsed)
+	}
+}
+
+func TestArrivalRateThreadGroupPersistsAndValidates(t *testing.T) {
+	tg := elements.NewArrivalRateThreadGroup("Open", 25)
+	tg.PreAllocatedUsers = 5
+	tg.MaxUsers = 40
+	tg.GracefulShutdown = 3 * time.Second
+	tg.ProfileBlocks = []elements.RPSProfileBlock{{RampUp: time.Second, StepDuration: 10 * time.Second, ProfilePercent: 80}}
+	tg.OnSampleError = elements.OnSampleErrorStopThread
+
+	loaded, ok := roundTripElement(t, tg).(*elements.ArrivalRateThreadGroup)
+	if !ok {
+		t.Fatalf("expected ArrivalRateThreadGroup, got %T", roundTripElement(t, tg))
+	}
+	if loaded.Rate != 25 || loaded.PreAllocatedUsers != 5 || loaded.MaxUsers != 40 ||
+		loaded.GracefulShutdown != 3*time.Second || loaded.OnSampleError != elements.OnSampleErrorStopThread {
+		t.Fatalf("unexpected round-trip result %+v", loaded)
+	}
+	if len(loaded.ProfileBlocks) != 1 || loaded.ProfileBlocks[0] != tg.ProfileBlocks[0] {
+		t.Fatalf("expected profile blocks to round-trip, got %+v", loaded.ProfileBlocks)
+	}
+
+	loaded.PreAllocatedUsers = 41
+	if err := loaded.Validate(); err == nil || !strings.Contains(err.Error(), "Pre-allocated users must not exceed max users") {
+		t.Fatalf("expected pre-allocated users validation error, got %v", err)
+	}
+	loaded.PreAllocatedUsers = 0
+	loaded.MaxUsers = 0
+	if err := loaded.Validate(); err == nil || !strings.Contains(err.Error(), "Max users") {
+		t.Fatalf("expected max users validation error, got %v", err)
+	}
+}
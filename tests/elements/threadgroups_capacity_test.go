@@ -0,0 +1,175 @@
+package elements_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"perfolizer/pkg/core"
+	"perfolizer/pkg/elements"
+)
+
+// capacityRunner stands in for StatsRunner in front of a system that keeps its p95
+// latency low up to capacity requests per second and degrades above it. Only samples
+// tagged with a thread group count towards that group's series.
+type capacityRunner struct {
+	mu          sync.Mutex
+	capacity    float64
+	recent      []time.Time
+	total       map[string]int
+	failed      map[string]int
+	sustainable []float64
+}
+
+func (r *capacityRunner) ReportResult(result *core.SampleResult) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.total == nil {
+		r.total, r.failed = make(map[string]int), make(map[string]int)
+	}
+	r.recent = append(r.recent, time.Now())
+	r.total[result.ThreadGroup]++
+	if !result.Success || result.Error != nil {
+		r.failed[result.ThreadGroup]++
+	}
+}
+
+func (r *capacityRunner) Snapshot() map[string]core.Metric {
+	return map[string]core.Metric{"Total": {}}
+}
+
+func (r *capacityRunner) GroupSnapshot(group string) map[string]core.Metric {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// Rate over the last 100ms decides the latency the fake system answers with
+	cutoff := time.Now().Add(-100 * time.Millisecond)
+	kept := r.recent[:0]
+	for _, at := range r.recent {
+		if at.After(cutoff) {
+			kept = append(kept, at)
+		}
+	}
+	r.recent = kept
+
+	p95 := 10.0
+	if float64(len(kept))*10 > r.capacity {
+		p95 = 1000
+	}
+	if r.total[group] == 0 {
+		return map[string]core.Metric{}
+	}
+	return map[string]core.Metric{
+		"Work": {TotalRequests: r.total[group], TotalErrors: r.failed[group], P95Latency: p95},
+	}
+}
+
+func (r *capacityRunner) SetSustainableRPS(group string, rps float64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sustainable = append(r.sustainable, rps)
+}
+
+func newCapacityGroup(t *testing.T, status int) *elements.CapacityThreadGroup {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+
+	tg := elements.NewCapacityThreadGroup("Capacity")
+	tg.Users = 4
+	tg.StartRPS = 50
+	tg.StepRPS = 50
+	tg.MaxRPS = 400
+	tg.StepDuration = 250 * time.Millisecond
+	tg.MaxP95Latency = 100 * time.Millisecond
+	tg.MaxErrorPercent = 5
+	tg.AddChild(&elements.HttpSampler{BaseElement: core.NewBaseElement("Work"), Method: "GET", Url: server.URL})
+	return tg
+}
+
+func TestCapacityThreadGroupStepsUpUntilTheSLOBreaksAndBacksOff(t *testing.T) {
+	tg := newCapacityGroup(t, http.StatusOK)
+	runner := &capacityRunner{capacity: 125}
+
+	start := time.Now()
+	tg.Start(context.Background(), runner)
+	elapsed := time.Since(start)
+
+	// 50 and 100 hold, 150 breaches, 100 is confirmed
+	if len(runner.sustainable) != 1 || runner.sustainable[0] != 100 {
+		t.Fatalf("expected a sustainable rate of 100 RPS, got %v", runner.sustainable)
+	}
+	if elapsed < 600*time.Millisecond || elapsed > 2*time.Second {
+		t.Fatalf("expected three full steps and one breached step, ran %v", elapsed)
+	}
+}
+
+func TestCapacityThreadGroupReportsMaxRPSWhenItHolds(t *testing.T) {
+	tg := newCapacityGroup(t, http.StatusOK)
+	tg.MaxRPS = 80
+	runner := &capacityRunner{capacity: 1000}
+
+	tg.Start(context.Background(), runner)
+
+	if len(runner.sustainable) != 1 || runner.sustainable[0] != 80 {
+		t.Fatalf("expected max RPS to be reported as sustainable, got %v", runner.sustainable)
+	}
+}
+
+func TestCapacityThreadGroupTreatsErrorRateAsBreach(t *testing.T) {
+	tg := newCapacityGroup(t, http.StatusInternalServerError)
+	runner := &capacityRunner{capacity: 1000}
+
+	tg.Start(context.Background(), runner)
+
+	if len(runner.sustainable) != 1 || runner.sustainable[0] != 0 {
+		t.Fatalf("expected no sustainable rate with failing requests, got %v", runner.sustainable)
+	}
+}
+
+func TestCapacityThreadGroupNeedsLiveStats(t *testing.T) {
+	tg := elements.NewCapacityThreadGroup("Capacity")
+	step := newCountingElement("Step")
+	tg.AddChild(step)
+
+	start := time.Now()
+	tg.Start(context.Background(), noopRunner{})
+	if elapsed := time.Since(start); elapsed > time.Second || step.Count() != 0 {
+		t.Fatalf("expected the group not to run without live stats, ran %v with %d iterations", elapsed, step.Count())
+	}
+}
+
+func TestCapacityThreadGroupPersistsAndValidates(t *testing.T) {
+	tg := elements.NewCapacityThreadGroup("Capacity")
+	tg.StartRPS = 20
+	tg.StepRPS = 5
+	tg.MaxRPS = 300
+	tg.StepDuration = time.Minute
+	tg.MaxP95Latency = 250 * time.Millisecond
+	tg.MaxErrorPercent = 0.5
+
+	loaded, ok := roundTripElement(t, tg).(*elements.CapacityThreadGroup)
+	if !ok {
+		t.Fatalf("expected CapacityThreadGroup, got %T", roundTripElement(t, tg))
+	}
+	if loaded.StartRPS != 20 || loaded.StepRPS != 5 || loaded.MaxRPS != 300 || loaded.StepDuration != time.Minute ||
+		loaded.MaxP95Latency != 250*time.Millisecond || loaded.MaxErrorPercent != 0.5 {
+		t.Fatalf("unexpected round-trip result %+v", loaded)
+	}
+
+	loaded.MaxRPS = 10
+	if err := loaded.Validate(); err == nil || !strings.Contains(err.Error(), "Max RPS must be greater than or equal to start RPS") {
+		t.Fatalf("expected max RPS validation error, got %v", err)
+	}
+	loaded.MaxRPS = 300
+	loaded.MaxP95Latency = 0
+	if err := loaded.Validate(); err == nil || !strings.Contains(err.Error(), "Max p95 latency") {
+		t.Fatalf("expected p95 SLO validation error, got %v", err)
+	}
+}
@@ -0,0 +1,115 @@
+package elements_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"perfolizer/pkg/core"
+	"perfolizer/pkg/elements"
+)
+
+// newOnSampleErrorGroup builds a group whose second of three iterations fails at the
+// login request, followed by a downstream request that must not run for a broken login.
+func newOnSampleErrorGroup(t *testing.T, policy string) (*elements.SimpleThreadGroup, *countingElement, *countingElement) {
+	t.Helper()
+
+	var calls int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 2 {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(server.Close)
+
+	tg := elements.NewSimpleThreadGroup("Users", 1, 3)
+	tg.OnSampleError = policy
+
+	tx := elements.NewTransactionController("Login flow")
+	tx.AddChild(&elements.HttpSampler{BaseElement: core.NewBaseElement("Login"), Method: "GET", Url: server.URL})
+	afterLogin := newCountingElement("Inside transaction")
+	tx.AddChild(afterLogin)
+	tg.AddChild(tx)
+
+	downstream := newCountingElement("Downstream")
+	tg.AddChild(downstream)
+	return tg, afterLogin, downstream
+}
+
+func TestThreadGroupOnSampleErrorPolicies(t *testing.T) {
+	tests := []struct {
+		policy             string
+		expectedAfterLogin int
+		expectedDownstream int
+	}{
+		{elements.OnSampleErrorContinue, 3, 3},
+		{elements.OnSampleErrorStartNextIteration, 2, 2},
+		{elements.OnSampleErrorStopThread, 1, 1},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.policy, func(t *testing.T) {
+			tg, afterLogin, downstream := newOnSampleErrorGroup(t, tc.policy)
+			runner := &collectingRunner{}
+			tg.Start(context.Background(), runner)
+
+			if afterLogin.Count() != tc.expectedAfterLogin {
+				t.Fatalf("expected %d executions after login, got %d", tc.expectedAfterLogin, afterLogin.Count())
+			}
+			if downstream.Count() != tc.expectedDownstream {
+				t.Fatalf("expected %d downstream executions, got %d", tc.expectedDownstream, downstream.Count())
+			}
+			if failed := runner.byName("Login flow"); len(failed) < 2 || failed[1].Success {
+				t.Fatalf("expected failed transaction sample to be reported, got %+v", failed)
+			}
+		})
+	}
+}
+
+func TestThreadGroupOnSampleErrorStopTestCancelsRun(t *testing.T) {
+	failing := elements.NewSimpleThreadGroup("Failing", 1, 1)
+	failing.OnSampleError = elements.OnSampleErrorStopTest
+	failing.AddChild(&elements.HttpSampler{BaseElement: core.NewBaseElement("Broken"), Method: "GET", Url: "http://127.0.0.1:1/"})
+
+	other := elements.NewSimpleThreadGroup("Other", 1, -1)
+	other.AddChild(newCountingElement("Busy"))
+	other.AddChild(elements.NewRuntimeController("Idle", 10*time.Millisecond))
+
+	runCtx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	runCtx = core.WithTestStopper(runCtx, cancel)
+
+	done := make(chan struct{})
+	go func() {
+		other.Start(runCtx, noopRunner{})
+		close(done)
+	}()
+	failing.Start(runCtx, noopRunner{})
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected stop-test policy to stop the other thread group")
+	}
+}
+
+func TestThreadGroupOnSampleErrorIsPersistedAndValidated(t *testing.T) {
+	rps := elements.NewRPSThreadGroup("RPS", 5)
+	rps.OnSampleError = elements.OnSampleErrorStopThread
+	loaded, ok := roundTripElement(t, rps).(*elements.RPSThreadGroup)
+	if !ok || loaded.OnSampleError != elements.OnSampleErrorStopThread {
+		t.Fatalf("unexpected round-trip result %+v", loaded)
+	}
+
+	simple := elements.NewSimpleThreadGroup("Simple", 1, 1)
+	simple.OnSampleError = "Retry"
+	if err := simple.Validate(); err == nil || !strings.Contains(err.Error(), "On sample error") {
+		t.Fatalf("expected invalid policy error, got %v", err)
+	}
+}
@@ -3,6 +3,7 @@ package elements_test
 import (
 	"context"
 	"net/http"
+	"sync"
 	"testing"
 	"time"
 
@@ -102,3 +103,172 @@ func TestRPSThreadGroupInjectsHTTPRuntimeIntoChildren(t *testing.T) {
 		t.Fatal("expected child execution to observe RPS thread-group runtime")
 	}
 }
+
+// threadTimeline records when each thread ran its first and last iteration.
+type threadTimeline struct {
+	mu    sync.Mutex
+	first map[int]time.Time
+	last  map[int]time.Time
+}
+
+func (tl *threadTimeline) element(took time.Duration) *countingElement {
+	tl.first = make(map[int]time.Time)
+	tl.last = make(map[int]time.Time)
+	el := newCountingElement("Step")
+	el.onRun = func(ctx *core.Context) error {
+		now := time.Now()
+		tl.mu.Lock()
+		if _, ok := tl.first[ctx.ThreadID]; !ok {
+			tl.first[ctx.ThreadID] = now
+		}
+		tl.last[ctx.ThreadID] = now
+		tl.mu.Unlock()
+		time.Sleep(took)
+		return nil
+	}
+	return el
+}
+
+func TestSimpleThreadGroupRampsUpHoldsAndRampsDown(t *testing.T) {
+	tg := elements.NewSimpleThreadGroup("Shaped", 3, -1)
+	tg.RampUp = 100 * time.Millisecond
+	tg.Hold = 100 * time.Millisecond
+	tg.RampDown = 150 * time.Millisecond
+
+	timeline := &threadTimeline{}
+	tg.AddChild(timeline.element(5 * time.Millisecond))
+
+	start := time.Now()
+	tg.Start(context.Background(), noopRunner{})
+	elapsed := time.Since(start)
+
+	if elapsed < 350*time.Millisecond || elapsed > 600*time.Millisecond {
+		t.Fatalf("expected ramp-up + hold + ramp-down of about 350ms, ran %v", elapsed)
+	}
+	if len(timeline.first) != 3 {
+		t.Fatalf("expected 3 threads, got %d", len(timeline.first))
+	}
+
+	// Ramp-up starts threads 50ms apart
+	if gap := timeline.first[2].Sub(timeline.first[0]); gap < 90*time.Millisecond {
+		t.Fatalf("expected starts spread over ramp-up, first and last start %v apart", gap)
+	}
+	// Ramp-down stops the last thread started first, 50ms before the next one
+	if timeline.last[2].After(timeline.last[1]) || timeline.last[1].After(timeline.last[0]) {
+		t.Fatalf("expected threads to stop in reverse start order, got %v", timeline.last)
+	}
+	if gap := timeline.last[0].Sub(timeline.last[2]); gap < 80*time.Millisecond {
+		t.Fatalf("expected stops spread over ramp-down, first and last stop %v apart", gap)
+	}
+}
+
+func TestSimpleThreadGroupIterationsStillLimitTimedRun(t *testing.T) {
+	tg := elements.NewSimpleThreadGroup("Timed", 2, 3)
+	tg.Hold = time.Minute
+
+	step := newCountingElement("Step")
+	tg.AddChild(step)
+
+	start := time.Now()
+	tg.Start(context.Background(), noopRunner{})
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("expected iterations to end the run before the hold, ran %v", elapsed)
+	}
+	if step.Count() != 6 {
+		t.Fatalf("expected 3 iterations per thread, got %d", step.Count())
+	}
+}
+
+func TestThreadGroupsWaitForStartDelayAndStopAfterDuration(t *testing.T) {
+	simple := elements.NewSimpleThreadGroup("Simple", 1, -1)
+	rps := elements.NewRPSThreadGroup("RPS", 100)
+	rps.Users = 1
+	rps.ProfileBlocks = []elements.RPSProfileBlock{{StepDuration: time.Hour, ProfilePercent: 100}}
+	arrival := elements.NewArrivalRateThreadGroup("Arrival", 100)
+	arrival.ProfileBlocks = []elements.RPSProfileBlock{{StepDuration: time.Hour, ProfilePercent: 100}}
+	ultimate := elements.NewUltimateThreadGroup("Ultimate")
+	ultimate.Schedule = []elements.UserScheduleRow{{Users: 1, Hold: time.Hour}}
+
+	groups := []struct {
+		tg         core.ThreadGroup
+		startDelay *time.Duration
+		duration   *time.Duration
+		add        func(core.TestElement)
+	}{
+		{simple, &simple.StartDelay, &simple.Duration, simple.AddChild},
+		{rps, &rps.StartDelay, &rps.Duration, rps.AddChild},
+		{arrival, &arrival.StartDelay, &arrival.Duration, arrival.AddChild},
+		{ultimate, &ultimate.StartDelay, &ultimate.Duration, ultimate.AddChild},
+	}
+	for _, group := range groups {
+		*group.startDelay = 60 * time.Millisecond
+		*group.duration = 100 * time.Millisecond
+		timeline := &threadTimeline{}
+		group.add(timeline.element(2 * time.Millisecond))
+
+		start := time.Now()
+		group.tg.Start(context.Background(), noopRunner{})
+		elapsed := time.Since(start)
+
+		name := group.tg.(core.TestElement).Name()
+		if elapsed < 160*time.Millisecond || elapsed > 500*time.Millisecond {
+			t.Fatalf("%s: expected start delay plus duration of about 160ms, ran %v", name, elapsed)
+		}
+		if first := timeline.first[0].Sub(start); first < 60*time.Millisecond {
+			t.Fatalf("%s: expected the first iteration after the start delay, got %v", name, first)
+		}
+	}
+}
+
+func TestThreadGroupDurationPersistsApartFromProfileLength(t *testing.T) {
+	rps := elements.NewRPSThreadGroup("RPS", 100)
+	rps.ProfileBlocks = nil
+	rps.Duration = 5 * time.Second
+	arrival := elements.NewArrivalRateThreadGroup("Arrival", 100)
+	arrival.ProfileBlocks = nil
+	arrival.Duration = 5 * time.Second
+
+	for _, tg := range []core.TestElement{rps, arrival} {
+		loaded := roundTripElement(t, tg)
+		var duration time.Duration
+		var blocks []elements.RPSProfileBlock
+		switch g := loaded.(type) {
+		case *elements.RPSThreadGroup:
+			duration, blocks = g.Duration, g.ProfileBlocks
+		case *elements.ArrivalRateThreadGroup:
+			duration, blocks = g.Duration, g.ProfileBlocks
+		default:
+			t.Fatalf("unexpected type %T", loaded)
+		}
+		if duration != 5*time.Second || len(blocks) != 0 {
+			t.Fatalf("%s: expected the duration to stay the group's window, got %v with profile %+v", tg.Name(), duration, blocks)
+		}
+	}
+
+	legacy, err := core.UnmarshalTestPlan([]byte(`{"type":"RPSThreadGroup","name":"Legacy","props":{"RPS":10,"DurationMS":2000}}`))
+	if err != nil {
+		t.Fatalf("UnmarshalTestPlan failed: %v", err)
+	}
+	if g := legacy.(*elements.RPSThreadGroup); g.Duration != 0 || len(g.ProfileBlocks) != 1 || g.ProfileBlocks[0].StepDuration != 2*time.Second {
+		t.Fatalf("expected the legacy duration to remain the profile length, got %v with profile %+v", g.Duration, g.ProfileBlocks)
+	}
+}
+
+func TestThreadGroupStartDelayIsCancellable(t *testing.T) {
+	tg := elements.NewSimpleThreadGroup("Delayed", 1, 1)
+	tg.StartDelay = time.Hour
+	step := newCountingElement("Step")
+	tg.AddChild(step)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	tg.Start(ctx, noopRunner{})
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("expected cancellation to end the start delay, took %v", elapsed)
+	}
+	if step.Count() != 0 {
+		t.Fatalf("expected no iterations, got %d", step.Count())
+	}
+}
@@ -0,0 +1,133 @@
+package elements_test
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"perfolizer/pkg/elements"
+)
+
+// gaugeRunner records every active-users update it receives.
+type gaugeRunner struct {
+	noopRunner
+	mu     sync.Mutex
+	values []int
+}
+
+func (r *gaugeRunner) SetActiveUsers(group string, users int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.values = append(r.values, users)
+}
+
+func (r *gaugeRunner) peak() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	peak := 0
+	for _, v := range r.values {
+		if v > peak {
+			peak = v
+		}
+	}
+	return peak
+}
+
+func (r *gaugeRunner) last() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.values) == 0 {
+		return -1
+	}
+	return r.values[len(r.values)-1]
+}
+
+func TestUltimateThreadGroupFollowsOverlappingSchedule(t *testing.T) {
+	tg := elements.NewUltimateThreadGroup("Steps")
+	tg.Schedule = []elements.UserScheduleRow{
+		{Users: 2, RampUp: 40 * time.Millisecond, Hold: 200 * time.Millisecond},
+		{StartDelay: 100 * time.Millisecond, Users: 3, Hold: 50 * time.Millisecond, RampDown: 60 * time.Millisecond},
+	}
+
+	probe := &concurrencyProbe{}
+	timeline := &threadTimeline{}
+	step := timeline.element(0)
+	tg.AddChild(probe.element("Work", 5*time.Millisecond))
+	tg.AddChild(step)
+
+	runner := &gaugeRunner{}
+	start := time.Now()
+	tg.Start(context.Background(), runner)
+	elapsed := time.Since(start)
+
+	if elapsed < 240*time.Millisecond || elapsed > 500*time.Millisecond {
+		t.Fatalf("expected the first row to bound the run at about 240ms, ran %v", elapsed)
+	}
+	if len(timeline.first) != 5 {
+		t.Fatalf("expected 5 users across both rows, got %d", len(timeline.first))
+	}
+	if late := timeline.first[2].Sub(start); late < 90*time.Millisecond {
+		t.Fatalf("expected the second row to start after its delay, started after %v", late)
+	}
+	if gap := timeline.last[2].Sub(timeline.last[4]); gap < 25*time.Millisecond {
+		t.Fatalf("expected the second row to ramp down last user first, stops %v apart", gap)
+	}
+	if timeline.last[4].After(timeline.last[0]) {
+		t.Fatal("expected the second row to finish before the first row")
+	}
+	if probe.peak != 5 || runner.peak() != 5 {
+		t.Fatalf("expected 5 concurrent users at the overlap, probe=%d gauge=%d", probe.peak, runner.peak())
+	}
+	if runner.last() != 0 {
+		t.Fatalf("expected the gauge to return to 0, got %d", runner.last())
+	}
+}
+
+func TestUltimateThreadGroupStopsOnCancellation(t *testing.T) {
+	tg := elements.NewUltimateThreadGroup("Long")
+	tg.Schedule = []elements.UserScheduleRow{
+		{Users: 2, Hold: time.Hour},
+		{StartDelay: time.Hour, Users: 2, Hold: time.Hour},
+	}
+	tg.AddChild(newCountingElement("Work"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	tg.Start(ctx, noopRunner{})
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("expected prompt stop on cancellation, took %v", elapsed)
+	}
+}
+
+func TestUltimateThreadGroupPersistsAndValidatesSchedule(t *testing.T) {
+	tg := elements.NewUltimateThreadGroup("Steps")
+	tg.Schedule = []elements.UserScheduleRow{
+		{Users: 5, RampUp: time.Second, Hold: time.Minute, RampDown: 2 * time.Second},
+		{StartDelay: 30 * time.Second, Users: 10, Hold: 10 * time.Second},
+	}
+	tg.OnSampleError = elements.OnSampleErrorStartNextIteration
+
+	loaded, ok := roundTripElement(t, tg).(*elements.UltimateThreadGroup)
+	if !ok {
+		t.Fatalf("expected UltimateThreadGroup, got %T", roundTripElement(t, tg))
+	}
+	if len(loaded.Schedule) != 2 || loaded.Schedule[0] != tg.Schedule[0] || loaded.Schedule[1] != tg.Schedule[1] {
+		t.Fatalf("expected schedule to round-trip, got %+v", loaded.Schedule)
+	}
+	if loaded.OnSampleError != elements.OnSampleErrorStartNextIteration {
+		t.Fatalf("expected policy to round-trip, got %q", loaded.OnSampleError)
+	}
+
+	loaded.Schedule[1].Hold = 0
+	if err := loaded.Validate(); err == nil || !strings.Contains(err.Error(), "Schedule row 2 hold must be greater than 0 ms") {
+		t.Fatalf("expected hold validation error, got %v", err)
+	}
+	loaded.Schedule = nil
+	if err := loaded.Validate(); err == nil || !strings.Contains(err.Error(), "at least one row") {
+		t.Fatalf("expected empty schedule validation error, got %v", err)
+	}
+}
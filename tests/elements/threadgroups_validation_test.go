@@ -40,3 +40,27 @@ func TestRPSThreadGroupStartWithInvalidUsersDoesNotPanic(t *testing.T) {
 
 	tg.Start(context.Background(), noopRunner{})
 }
+
+func TestEveryThreadGroupTakesPlanParameters(t *testing.T) {
+	params := []core.Parameter{{Name: "host", Type: core.ParamTypeStatic, Value: "example.com"}}
+	groups := []core.TestElement{
+		elements.NewSimpleThreadGroup("Simple", 1, 1),
+		elements.NewSetupThreadGroup("Setup"),
+		elements.NewTeardownThreadGroup("Teardown"),
+		elements.NewRPSThreadGroup("RPS", 1),
+		elements.NewArrivalRateThreadGroup("Arrival", 1),
+		elements.NewUltimateThreadGroup("Ultimate"),
+		elements.NewCapacityThreadGroup("Capacity"),
+	}
+	for _, group := range groups {
+		tg, ok := group.(core.ParameterizedThreadGroup)
+		if !ok {
+			t.Fatalf("expected %s to take plan parameters", group.Name())
+		}
+		tg.SetParameters(params)
+		got, ok := tg.(core.Serializable).GetProps()["Parameters"].([]core.Parameter)
+		if !ok || len(got) != 1 || got[0].Name != "host" {
+			t.Fatalf("expected %s to persist the bound parameters, got %v", group.Name(), got)
+		}
+	}
+}
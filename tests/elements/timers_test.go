@@ -0,0 +1,366 @@
+package elements_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"perfolizer/pkg/core"
+	"perfolizer/pkg/elements"
+)
+
+func TestTimersApplyBeforeEachSamplerInScope(t *testing.T) {
+	var mu sync.Mutex
+	arrivals := make(map[string]time.Time)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		arrivals[r.URL.Path] = time.Now()
+		mu.Unlock()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	tg := elements.NewSimpleThreadGroup("Users", 1, 1)
+	tg.AddChild(elements.NewConstantTimer("Think time", 40*time.Millisecond))
+
+	first := &elements.HttpSampler{BaseElement: core.NewBaseElement("First"), Method: "GET", Url: server.URL + "/first"}
+	second := &elements.HttpSampler{BaseElement: core.NewBaseElement("Second"), Method: "GET", Url: server.URL + "/second"}
+	second.AddChild(elements.NewConstantTimer("Extra", 60*time.Millisecond))
+	disabled := elements.NewConstantTimer("Disabled", time.Hour)
+	disabled.SetEnabled(false)
+	second.AddChild(disabled)
+	tg.AddChild(first)
+	tg.AddChild(second)
+
+	start := time.Now()
+	tg.Start(context.Background(), noopRunner{})
+
+	mu.Lock()
+	defer mu.Unlock()
+	if got := arrivals["/first"].Sub(start); got < 40*time.Millisecond {
+		t.Fatalf("expected thread group timer before first sampler, got %v", got)
+	}
+	gap := arrivals["/second"].Sub(arrivals["/first"])
+	if gap < 100*time.Millisecond || gap > time.Second {
+		t.Fatalf("expected group and sampler timers before second sampler, got %v", gap)
+	}
+}
+
+func TestTimerIsCancellable(t *testing.T) {
+	base, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	timer := elements.NewConstantTimer("Long", time.Hour)
+	start := time.Now()
+	if err := timer.BeforeSample(core.NewContext(base, 1)); err == nil {
+		t.Fatal("expected cancellation error")
+	}
+	if time.Since(start) > time.Second {
+		t.Fatal("expected timer to return promptly when cancelled")
+	}
+}
+
+func TestRandomTimerDistributions(t *testing.T) {
+	const n = 2000
+	uniform := elements.NewUniformRandomTimer("Uniform", 100*time.Millisecond, 50*time.Millisecond)
+	gaussian := elements.NewGaussianRandomTimer("Gaussian", 200*time.Millisecond, 20*time.Millisecond)
+	poisson := elements.NewPoissonRandomTimer("Poisson", 10*time.Millisecond, 30*time.Millisecond)
+
+	var gaussianSum, poissonSum time.Duration
+	for i := 0; i < n; i++ {
+		if d := uniform.NextDelay(); d < 100*time.Millisecond || d >= 150*time.Millisecond {
+			t.Fatalf("uniform delay %v out of range", d)
+		}
+		d := gaussian.NextDelay()
+		if d < 0 {
+			t.Fatalf("gaussian delay must not be negative, got %v", d)
+		}
+		gaussianSum += d
+		d = poisson.NextDelay()
+		if d < 10*time.Millisecond || d%time.Millisecond != 0 {
+			t.Fatalf("poisson delay %v must be offset plus whole milliseconds", d)
+		}
+		poissonSum += d
+	}
+
+	if mean := gaussianSum / n; mean < 195*time.Millisecond || mean > 205*time.Millisecond {
+		t.Fatalf("expected gaussian mean near 200ms, got %v", mean)
+	}
+	if mean := poissonSum / n; mean < 38*time.Millisecond || mean > 42*time.Millisecond {
+		t.Fatalf("expected poisson mean near 40ms, got %v", mean)
+	}
+}
+
+func TestTimersRoundTripWithParameters(t *testing.T) {
+	uniform, ok := roundTripElement(t, elements.NewUniformRandomTimer("U", 10*time.Millisecond, 20*time.Millisecond)).(*elements.UniformRandomTimer)
+	if !ok || uniform.Delay != 10*time.Millisecond || uniform.Range != 20*time.Millisecond {
+		t.Fatalf("unexpected uniform timer %+v", uniform)
+	}
+	gaussian, ok := roundTripElement(t, elements.NewGaussianRandomTimer("G", 30*time.Millisecond, 5*time.Millisecond)).(*elements.GaussianRandomTimer)
+	if !ok || gaussian.Delay != 30*time.Millisecond || gaussian.Deviation != 5*time.Millisecond {
+		t.Fatalf("unexpected gaussian timer %+v", gaussian)
+	}
+	poisson, ok := roundTripElement(t, elements.NewPoissonRandomTimer("P", 1*time.Millisecond, 7*time.Millisecond)).(*elements.PoissonRandomTimer)
+	if !ok || poisson.Delay != time.Millisecond || poisson.Lambda != 7*time.Millisecond {
+		t.Fatalf("unexpected poisson timer %+v", poisson)
+	}
+	constant, ok := roundTripElement(t, elements.NewConstantTimer("C", 15*time.Millisecond)).(*elements.ConstantTimer)
+	if !ok || constant.Delay != 15*time.Millisecond {
+		t.Fatalf("unexpected constant timer %+v", constant)
+	}
+
+	constant.Delay = -time.Millisecond
+	if err := constant.Validate(); err == nil {
+		t.Fatal("expected negative delay to be rejected")
+	}
+}
+
+func TestConstantThroughputTimerPacesPerThreadOrShared(t *testing.T) {
+	paceSamples := func(timer *elements.ConstantThroughputTimer, threads, samples int) time.Duration {
+		start := time.Now()
+		var wg sync.WaitGroup
+		for threadID := 0; threadID < threads; threadID++ {
+			wg.Add(1)
+			go func(threadID int) {
+				defer wg.Done()
+				ctx := core.NewContext(context.Background(), threadID)
+				for i := 0; i < samples; i++ {
+					if err := timer.BeforeSample(ctx); err != nil {
+						t.Errorf("BeforeSample returned error: %v", err)
+						return
+					}
+				}
+			}(threadID)
+		}
+		wg.Wait()
+		return time.Since(start)
+	}
+
+	// 3000 samples per minute = one sample every 20ms
+	perThread := elements.NewConstantThroughputTimer("Per thread", 3000)
+	if elapsed := paceSamples(perThread, 2, 4); elapsed < 55*time.Millisecond || elapsed > 110*time.Millisecond {
+		t.Fatalf("expected each thread paced independently (~60ms), took %v", elapsed)
+	}
+
+	shared := elements.NewConstantThroughputTimer("Shared", 3000)
+	shared.Shared = true
+	if elapsed := paceSamples(shared, 2, 4); elapsed < 135*time.Millisecond {
+		t.Fatalf("expected threads paced together (~140ms), took %v", elapsed)
+	}
+
+	loaded, ok := roundTripElement(t, shared).(*elements.ConstantThroughputTimer)
+	if !ok || loaded.SamplesPerMinute != 3000 || !loaded.Shared {
+		t.Fatalf("unexpected round-trip result %+v", loaded)
+	}
+}
+
+func TestConstantThroughputTimerPacesSimpleThreadGroup(t *testing.T) {
+	var mu sync.Mutex
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests++
+		mu.Unlock()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	tg := elements.NewSimpleThreadGroup("Users", 2, -1)
+	timer := elements.NewConstantThroughputTimer("Pacing", 1200) // 20 samples per second
+	timer.Shared = true
+	tg.AddChild(timer)
+	tg.AddChild(&elements.HttpSampler{BaseElement: core.NewBaseElement("Request"), Method: "GET", Url: server.URL})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	tg.Start(ctx, noopRunner{})
+
+	mu.Lock()
+	defer mu.Unlock()
+	if requests < 8 || requests > 13 {
+		t.Fatalf("expected about 11 paced requests in 500ms, got %d", requests)
+	}
+}
+
+func TestConstantThroughputTimerPausesUntilTheDeadlineInsteadOfFailing(t *testing.T) {
+	timer := elements.NewConstantThroughputTimer("Pacing", 60) // one sample per second
+
+	runCtx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	ctx := core.NewContext(runCtx, 1)
+	if err := timer.BeforeSample(ctx); err != nil {
+		t.Fatalf("first BeforeSample returned error: %v", err)
+	}
+
+	// The next slot is past the deadline, so the thread waits for the run to end
+	err := timer.BeforeSample(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the run deadline, got %v", err)
+	}
+	if ctx.Err() == nil {
+		t.Fatal("expected BeforeSample to return once the run had ended")
+	}
+}
+
+func TestSyncTimerReleasesGroupTogether(t *testing.T) {
+	timer := elements.NewSyncTimer("Spike", 3, 0)
+
+	releases := make(chan time.Time, 3)
+	for i := 0; i < 3; i++ {
+		go func(threadID int) {
+			time.Sleep(time.Duration(threadID) * 20 * time.Millisecond)
+			if err := timer.BeforeSample(core.NewContext(context.Background(), threadID)); err != nil {
+				t.Errorf("BeforeSample returned error: %v", err)
+			}
+			releases <- time.Now()
+		}(i)
+	}
+
+	var first, last time.Time
+	for i := 0; i < 3; i++ {
+		select {
+		case at := <-releases:
+			if first.IsZero() || at.Before(first) {
+				first = at
+			}
+			if at.After(last) {
+				last = at
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatal("expected the group to be released")
+		}
+	}
+	if spread := last.Sub(first); spread > 10*time.Millisecond {
+		t.Fatalf("expected users to be released together, spread was %v", spread)
+	}
+}
+
+func TestSyncTimerReleasesIncompleteGroupOnTimeout(t *testing.T) {
+	timer := elements.NewSyncTimer("Spike", 5, 50*time.Millisecond)
+
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func(threadID int) {
+			defer wg.Done()
+			if err := timer.BeforeSample(core.NewContext(context.Background(), threadID)); err != nil {
+				t.Errorf("BeforeSample returned error: %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if elapsed := time.Since(start); elapsed < 45*time.Millisecond || elapsed > time.Second {
+		t.Fatalf("expected release after the 50ms timeout, took %v", elapsed)
+	}
+}
+
+func TestSyncTimerCancelledUserLeavesGroup(t *testing.T) {
+	timer := elements.NewSyncTimer("Spike", 3, 0)
+
+	cancelledCtx, cancel := context.WithCancel(context.Background())
+	cancelled := make(chan error, 1)
+	go func() {
+		cancelled <- timer.BeforeSample(core.NewContext(cancelledCtx, 0))
+	}()
+	time.Sleep(10 * time.Millisecond)
+	cancel()
+	select {
+	case err := <-cancelled:
+		if err == nil {
+			t.Fatal("expected cancellation error")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected cancelled user to stop waiting")
+	}
+
+	released := make(chan struct{}, 3)
+	arrive := func(threadID int) {
+		if err := timer.BeforeSample(core.NewContext(context.Background(), threadID)); err != nil {
+			t.Errorf("BeforeSample returned error: %v", err)
+		}
+		released <- struct{}{}
+	}
+	go arrive(1)
+	go arrive(2)
+
+	select {
+	case <-released:
+		t.Fatal("expected two users to keep waiting once the cancelled user left")
+	case <-time.After(40 * time.Millisecond):
+	}
+
+	go arrive(3)
+	for i := 0; i < 3; i++ {
+		select {
+		case <-released:
+		case <-time.After(time.Second):
+			t.Fatal("expected the completed group to be released")
+		}
+	}
+
+	loaded, ok := roundTripElement(t, elements.NewSyncTimer("Persisted", 7, 250*time.Millisecond)).(*elements.SyncTimer)
+	if !ok || loaded.GroupSize != 7 || loaded.Timeout != 250*time.Millisecond {
+		t.Fatalf("unexpected round-trip result %+v", loaded)
+	}
+	loaded.GroupSize = 0
+	if err := loaded.Validate(); err == nil {
+		t.Fatal("expected zero group size to be rejected")
+	}
+}
+
+func TestSyncTimerReleasesWaitingUsersWhenTheRestOfTheGroupFinishes(t *testing.T) {
+	var mu sync.Mutex
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests++
+		mu.Unlock()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	tg := elements.NewSimpleThreadGroup("Users", 3, 1)
+	tg.AddChild(elements.NewSyncTimer("Pairs", 2, 0))
+	tg.AddChild(&elements.HttpSampler{BaseElement: core.NewBaseElement("Request"), Method: "GET", Url: server.URL})
+
+	done := make(chan struct{})
+	go func() {
+		tg.Start(context.Background(), noopRunner{})
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected the odd user out to be released once the others finished")
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if requests != 3 {
+		t.Fatalf("expected every user to send its request, got %d", requests)
+	}
+}
+
+func TestSyncTimerGroupSizeMustNotExceedTheThreadGroupUsers(t *testing.T) {
+	tg := elements.NewSimpleThreadGroup("Users", 3, 1)
+	tx := elements.NewTransactionController("Checkout")
+	tx.AddChild(elements.NewSyncTimer("Spike", 4, 0))
+	tg.AddChild(tx)
+
+	if err := tg.Validate(); err == nil || !strings.Contains(err.Error(), `Sync Timer "Spike" group size must be less than or equal to Users (3)`) {
+		t.Fatalf("expected group size validation error, got %v", err)
+	}
+
+	arrival := elements.NewArrivalRateThreadGroup("Open", 10)
+	arrival.MaxUsers = 2
+	arrival.AddChild(elements.NewSyncTimer("Spike", 3, 0))
+	if err := arrival.Validate(); err == nil || !strings.Contains(err.Error(), "Max users (2)") {
+		t.Fatalf("expected group size validation error against max users, got %v", err)
+	}
+}